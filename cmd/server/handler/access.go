@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+// forbidden - answers 403 to a principal reaching data of another dentist or patient
+func forbidden(ctx *gin.Context) {
+	web.BadResponse(ctx, http.StatusForbidden, "you aren't allowed to access this resource")
+}
+
+// canAccess - tells if the principal of the request reaches the appointment
+func canAccess(ctx *gin.Context, a domain.Appointment) bool {
+	principal, ok := web.GetPrincipal(ctx)
+	return ok && principal.CanAccess(a)
+}
+
+// visibleAppointments - keeps the appointments the principal of the request reaches
+func visibleAppointments(ctx *gin.Context, appointments []domain.AppointmentDTO) []domain.AppointmentDTO {
+	visible := []domain.AppointmentDTO{}
+	for _, a := range appointments {
+		if canAccess(ctx, a.Appointment) {
+			visible = append(visible, a)
+		}
+	}
+	return visible
+}
+
+// restrictAppointments - limits a list to the own appointments of a dentist or patient
+func restrictAppointments(ctx *gin.Context, opts *store.ListOptions) {
+	principal, _ := web.GetPrincipal(ctx)
+	switch principal.Role {
+	case domain.RoleDentist:
+		opts.Filters["dentist_license"] = principal.DentistLicense
+	case domain.RolePatient:
+		opts.Filters["patient_identity"] = principal.PatientIdentity
+	}
+}
+
+// ownsDentist - tells if the principal is an admin or the dentist of dentistID
+func ownsDentist(ctx *gin.Context, dentistID int) bool {
+	principal, ok := web.GetPrincipal(ctx)
+	return ok && (principal.Role == domain.RoleAdmin || principal.Role == domain.RoleDentist && principal.DentistId == dentistID)
+}
+
+// ownsPatient - tells if the principal is staff or the patient of patientID
+func ownsPatient(ctx *gin.Context, patientID int) bool {
+	principal, ok := web.GetPrincipal(ctx)
+	if !ok {
+		return false
+	}
+	if principal.Role == domain.RolePatient {
+		return principal.PatientId == patientID
+	}
+	return principal.HasRole(domain.RoleAdmin, domain.RoleReceptionist)
+}
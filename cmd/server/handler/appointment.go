@@ -1,15 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/mauriciogregory/esp_backIII_go/internal/appointment"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
 	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
-	"log"
+	"io"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -25,16 +25,30 @@ func NewAppointmentHandler(s appointment.Service) *appointmentHandler {
 
 func (h *appointmentHandler) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		response, err := h.s.GetAll()
+		opts, err := listOptions(ctx, "description", "dentist_license", "patient_identity")
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
-		if response == nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", "was not found appointments registered")
+		var ok bool
+		if opts.IncludeDeleted, ok = includeDeleted(ctx); !ok {
 			return
 		}
-		web.ResponseOK(ctx, http.StatusOK, response)
+		restrictAppointments(ctx, &opts)
+		if opts.From, err = parseDateFilter(ctx.Query("from"), false); err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		if opts.To, err = parseDateFilter(ctx.Query("to"), true); err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		page, err := h.s.List(ctx.Request.Context(), opts)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponsePage(ctx, http.StatusOK, page.Items, page.Total, opts.Limit, opts.Offset)
 	}
 }
 
@@ -43,14 +57,19 @@ func (h *appointmentHandler) GetByID() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
 			return
 		}
-		response, err := h.s.GetByID(id)
+		response, err := h.s.GetByID(ctx.Request.Context(), id)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		if !canAccess(ctx, response.Appointment) {
+			forbidden(ctx)
+			return
+		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -58,24 +77,24 @@ func (h *appointmentHandler) GetByID() gin.HandlerFunc {
 func (h *appointmentHandler) GetAllByIdentityNumber() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		idParam := ctx.Param("identity_number")
-		response, err := h.s.GetAllByIdentityNumber(idParam)
+		response, err := h.s.GetAllByIdentityNumber(ctx.Request.Context(), idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
-		web.ResponseOK(ctx, http.StatusOK, response)
+		web.ResponseOK(ctx, http.StatusOK, visibleAppointments(ctx, response))
 	}
 }
 
 func (h *appointmentHandler) GetAllByLicenseNumber() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		idParam := ctx.Param("license_number")
-		response, err := h.s.GetAllByLicenseNumber(idParam)
+		response, err := h.s.GetAllByLicenseNumber(ctx.Request.Context(), idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
-		web.ResponseOK(ctx, http.StatusOK, response)
+		web.ResponseOK(ctx, http.StatusOK, visibleAppointments(ctx, response))
 	}
 }
 
@@ -85,21 +104,68 @@ func (h *appointmentHandler) Post() gin.HandlerFunc {
 		var appointment domain.Appointment
 		err := ctx.ShouldBindJSON(&appointment)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid appointment data, please verify field(s): "+err.Error())
+			web.BindingResponse(ctx, "invalid appointment data", err)
 			return
 		}
 
 		isValid, err := isEmptyAppointment(&appointment)
 		if !isValid {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
-		response, err := h.s.Create(appointment)
+		response, err := h.s.Create(ctx.Request.Context(), appointment)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
-		web.ResponseOK(ctx, http.StatusOK, response)
+		web.SetETag(ctx, response.Version)
+		web.ResponseOK(ctx, http.StatusCreated, response)
+	}
+}
+
+// seriesRequest - the body of PostSeries, an appointment with its recurrence
+type seriesRequest struct {
+	domain.Appointment
+	Recurrence domain.Recurrence `json:"recurrence" binding:"required"`
+}
+
+// UnmarshalJSON - needed as the one of Appointment would be promoted and leave the
+// recurrence out
+func (r *seriesRequest) UnmarshalJSON(data []byte) error {
+	if err := r.Appointment.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	aux := struct {
+		Recurrence *domain.Recurrence `json:"recurrence"`
+	}{&r.Recurrence}
+	return json.Unmarshal(data, &aux)
+}
+
+// PostSeries - books the occurrences of a recurrence, the body is an appointment with
+// a recurrence: {"recurrence": {"frequency": "weekly", "interval": 4, "count": 13}}.
+// Answers 409 when none of the occurrences is available.
+func (h *appointmentHandler) PostSeries() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var r seriesRequest
+		if err := ctx.ShouldBindJSON(&r); err != nil {
+			web.BindingResponse(ctx, "invalid appointment series data", err)
+			return
+		}
+		isValid, err := isEmptyAppointment(&r.Appointment)
+		if !isValid {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		response, err := h.s.CreateSeries(ctx.Request.Context(), r.Appointment, r.Recurrence)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		if len(response.Appointments) == 0 {
+			web.ResponseOK(ctx, http.StatusConflict, response)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusCreated, response)
 	}
 }
 
@@ -108,27 +174,42 @@ func (h *appointmentHandler) Put() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		version, err := web.IfMatch(ctx)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 
 		var appointment domain.Appointment
 		err = ctx.ShouldBindJSON(&appointment)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid appointment data, verify the fields and try again")
+			web.BindingResponse(ctx, "invalid appointment data", err)
 			return
 		}
 
 		isValid, err := isEmptyAppointment(&appointment)
 		if !isValid {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		appointment.Version = version
+		scope, ok := seriesScope(ctx)
+		if !ok {
 			return
 		}
-		response, err := h.s.Update(id, appointment)
+		if scope != domain.ScopeThis {
+			h.updateSeries(ctx, id, appointment, scope)
+			return
+		}
+		response, err := h.s.Update(ctx.Request.Context(), id, appointment)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -136,10 +217,12 @@ func (h *appointmentHandler) Put() gin.HandlerFunc {
 // metodo patch
 func (h *appointmentHandler) Patch() gin.HandlerFunc {
 	type Request struct {
-		Description     string `json:"description,omitempty"`
-		DateAndTime     string `json:"date_and_time,omitempty"`
-		DentistLicense  string `json:"dentist_license,omitempty"`
-		PatientIdentity string `json:"patient_identity,omitempty"`
+		Description     string               `json:"description,omitempty"`
+		DateAndTime     string               `json:"date_and_time,omitempty"`
+		DentistLicense  string               `json:"dentist_license,omitempty"`
+		PatientIdentity string               `json:"patient_identity,omitempty"`
+		ProcedureType   domain.ProcedureType `json:"procedure_type,omitempty"`
+		DurationMinutes int                  `json:"duration_minutes,omitempty" binding:"min=0"`
 	}
 
 	return func(ctx *gin.Context) {
@@ -147,30 +230,46 @@ func (h *appointmentHandler) Patch() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		version, err := web.IfMatch(ctx)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		if err := ctx.ShouldBindJSON(&r); err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid request")
+			web.BindingResponse(ctx, "invalid request", err)
+			return
+		}
+		dateAndTime, err := optionalDateTime("date_and_time", r.DateAndTime)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		update := domain.Appointment{
 			Description:     r.Description,
-			DateAndTime:     r.DateAndTime,
+			DateAndTime:     dateAndTime,
 			DentistLicense:  r.DentistLicense,
 			PatientIdentity: r.PatientIdentity,
+			ProcedureType:   r.ProcedureType,
+			DurationMinutes: r.DurationMinutes,
+			Version:         version,
 		}
-		if update.DateAndTime != "" {
-			if !validateDateTime(update.DateAndTime) {
-				web.BadResponse(ctx, http.StatusBadRequest, "error", "please the appointment must be in format: 30/01/2023 23:59")
-				return
-			}
+		scope, ok := seriesScope(ctx)
+		if !ok {
+			return
+		}
+		if scope != domain.ScopeThis {
+			h.updateSeries(ctx, id, update, scope)
+			return
 		}
-		response, err := h.s.Update(id, update)
+		response, err := h.s.Update(ctx.Request.Context(), id, update)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -181,87 +280,158 @@ func (h *appointmentHandler) Delete() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
 			return
 		}
-		err = h.s.Delete(id)
+		err = h.s.Delete(ctx.Request.Context(), id)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		web.DeleteResponse(ctx, http.StatusOK, "appointment removed")
 	}
 }
 
-// Aux functions bellow->
-
-func isEmptyAppointment(appointment *domain.Appointment) (bool, error) {
-	dateTimeParsed, err := time.Parse("10/10/2023 20:00", appointment.DateAndTime)
-	if err != nil {
-		return false, err
-	}
-	switch {
-	case appointment.Description == "" || appointment.DentistLicense == "" || appointment.DateAndTime == "" || appointment.PatientIdentity == "":
-		return false, errors.New("fields can't be empty")
-	case !validateDateTime(appointment.DateAndTime):
-		return false, errors.New("please the appointment must be in format: 30/01/2023 23:59")
-	case dateTimeParsed.Local().Add(time.Hour * 3).Before(time.Now().Add(time.Hour)):
-		return false, errors.New("the appointment must be in +1 hour from now")
+// Restore - undoes the deletion of the appointment
+func (h *appointmentHandler) Restore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.Restore(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.SetETag(ctx, response.Version)
+		web.ResponseOK(ctx, http.StatusOK, response)
 	}
-	return true, nil
 }
 
-func validateDateTime(dateTime string) bool {
-	datesInit := strings.Split(dateTime, " ")
-	if len(datesInit) != 2 {
-		log.Printf("invalid time, must be in format: 30/01/2023 23:59")
-		return false
+// ChangeStatus - moves the appointment to status, the body may carry a reason
+// which is required when cancelling: {"reason": "patient is sick"}. The scope
+// query parameter reaches the other occurrences of a series.
+func (h *appointmentHandler) ChangeStatus(status domain.AppointmentStatus) gin.HandlerFunc {
+	type Request struct {
+		Reason string `json:"reason"`
 	}
-	breakDate := strings.Split(datesInit[0], "/")
-	if len(breakDate) != 3 {
-		log.Println("invalid time, must be in format: 30/01/2023 23:59 or 30/01/2023 23:59:59")
-		return false
+
+	return func(ctx *gin.Context) {
+		var r Request
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if err := ctx.ShouldBindJSON(&r); err != nil && !errors.Is(err, io.EOF) {
+			web.BindingResponse(ctx, "invalid request", err)
+			return
+		}
+		if status == domain.StatusCancelled && r.Reason == "" {
+			web.ErrorResponse(ctx, domain.NewValidationError("reason", "required", "a reason is required to cancel an appointment"))
+			return
+		}
+		if !h.authorize(ctx, id) {
+			return
+		}
+		scope, ok := seriesScope(ctx)
+		if !ok {
+			return
+		}
+		if scope != domain.ScopeThis {
+			response, err := h.s.ChangeSeriesStatus(ctx.Request.Context(), id, status, r.Reason, scope)
+			if err != nil {
+				web.ErrorResponse(ctx, err)
+				return
+			}
+			web.ResponseOK(ctx, http.StatusOK, response)
+			return
+		}
+		response, err := h.s.ChangeStatus(ctx.Request.Context(), id, status, r.Reason)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.SetETag(ctx, response.Version)
+		web.ResponseOK(ctx, http.StatusOK, response)
 	}
-	breakTime := strings.Split(datesInit[1], ":")
-	var listDate []int
-	var listTime []int
+}
 
-	for _, date := range breakDate {
-		number, err := strconv.Atoi(date)
+func (h *appointmentHandler) GetStatusHistory() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if !h.authorize(ctx, id) {
+			return
+		}
+		response, err := h.s.GetStatusHistory(ctx.Request.Context(), id)
 		if err != nil {
-			return false
+			web.ErrorResponse(ctx, err)
+			return
 		}
-		listDate = append(listDate, number)
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+// Aux functions bellow->
+
+// authorize - answers 404 or 403 and returns false when the appointment doesn't
+// exist or the principal can't reach it
+func (h *appointmentHandler) authorize(ctx *gin.Context, id int) bool {
+	current, err := h.s.GetByID(ctx.Request.Context(), id)
+	if err != nil {
+		web.ErrorResponse(ctx, err)
+		return false
 	}
-	condition := (listDate[0] < 1 || listDate[0] > 31) && (listDate[1] < 1 || listDate[1] > 12) && (listDate[2] < 1 || listDate[2] > 9999)
-	if condition {
-		log.Println("invalid time, must be between: 1 and 31/12/2023 23:59")
+	if !canAccess(ctx, current.Appointment) {
+		forbidden(ctx)
 		return false
 	}
+	return true
+}
 
-	for _, t := range breakTime {
-		clock, err := strconv.Atoi(t)
-		if err != nil {
-			log.Println("invalid time, must be in format 23:59 (hours and minutes)")
-			return false
-		}
-		listTime = append(listTime, clock)
+// updateSeries - answers an update of the occurrences of a series reached by scope
+func (h *appointmentHandler) updateSeries(ctx *gin.Context, id int, a domain.Appointment, scope domain.SeriesScope) {
+	response, err := h.s.UpdateSeries(ctx.Request.Context(), id, a, scope)
+	if err != nil {
+		web.ErrorResponse(ctx, err)
+		return
 	}
+	web.ResponseOK(ctx, http.StatusOK, response)
+}
 
-	if len(listTime) == 2 {
-		condition = (listTime[0] < 0 || listTime[0] > 23) && (listTime[1] < 0 || listTime[1] > 59)
-		if condition {
-			log.Println("invalid time, must be between: 00:00 and 23:59")
-			return false
-		}
+// seriesScope - reads the scope query parameter, this by default. Answers 400 and
+// returns false when it's invalid.
+func seriesScope(ctx *gin.Context) (domain.SeriesScope, bool) {
+	scope := domain.SeriesScope(ctx.DefaultQuery("scope", string(domain.ScopeThis)))
+	switch scope {
+	case domain.ScopeThis, domain.ScopeFollowing, domain.ScopeAll:
+		return scope, true
 	}
+	web.ErrorResponse(ctx, domain.NewValidationError("scope", "oneof", "must be one of: this, following, all"))
+	return "", false
+}
 
-	if len(listTime) == 3 {
-		condition = (listTime[0] < 0 || listTime[0] > 23) && (listTime[1] < 0 || listTime[1] > 59)
-		if condition {
-			log.Println("invalid time, must be between: 00:00 and 23:59")
-			return false
-		}
+// isEmptyAppointment - reports every empty field and a date_and_time less than one
+// hour from now
+func isEmptyAppointment(appointment *domain.Appointment) (bool, error) {
+	invalid := &domain.ValidationError{}
+	requireFields(invalid, map[string]string{
+		"description":      appointment.Description,
+		"date_and_time":    domain.FormatDateTime(appointment.DateAndTime),
+		"dentist_license":  appointment.DentistLicense,
+		"patient_identity": appointment.PatientIdentity,
+	})
+	if !appointment.DateAndTime.IsZero() && appointment.DateAndTime.Before(time.Now().Add(time.Hour)) {
+		invalid.Add("date_and_time", "future", "the appointment must be in +1 hour from now")
 	}
-	return true
+	if err := invalid.OrNil(); err != nil {
+		return false, err
+	}
+	return true, nil
 }
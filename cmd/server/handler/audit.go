@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/audit"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+type auditHandler struct {
+	s audit.Service
+}
+
+func NewAuditHandler(s audit.Service) *auditHandler {
+	return &auditHandler{
+		s: s,
+	}
+}
+
+// GetAll - browses the audit log, ?entity=appointments&id=42 gives the history of
+// one appointment
+func (h *auditHandler) GetAll() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		opts, err := listOptions(ctx, "entity", "actor_id", "operation")
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		if id := ctx.Query("id"); id != "" {
+			if _, err := strconv.Atoi(id); err != nil {
+				web.ErrorResponse(ctx, domain.NewValidationError("id", "number", "must be a number"))
+				return
+			}
+			opts.Filters["entity_id"] = id
+		}
+		if opts.From, err = parseDateFilter(ctx.Query("from"), false); err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		if opts.To, err = parseDateFilter(ctx.Query("to"), true); err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		page, err := h.s.List(ctx.Request.Context(), opts)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponsePage(ctx, http.StatusOK, page.Items, page.Total, opts.Limit, opts.Offset)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/auth"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+type authHandler struct {
+	s auth.Service
+}
+
+func NewAuthHandler(s auth.Service) *authHandler {
+	return &authHandler{
+		s: s,
+	}
+}
+
+// Login - exchanges an email and password for an access and a refresh token
+func (h *authHandler) Login() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c domain.Credentials
+		if err := ctx.ShouldBindJSON(&c); err != nil {
+			web.BindingResponse(ctx, "invalid credentials data", err)
+			return
+		}
+		response, err := h.s.Login(ctx.Request.Context(), c)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+// Refresh - exchanges a refresh token for a new pair of tokens
+func (h *authHandler) Refresh() gin.HandlerFunc {
+	type Request struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	return func(ctx *gin.Context) {
+		var r Request
+		if err := ctx.ShouldBindJSON(&r); err != nil {
+			web.BindingResponse(ctx, "invalid request", err)
+			return
+		}
+		response, err := h.s.Refresh(ctx.Request.Context(), r.RefreshToken)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+// Me - returns the principal of the access token
+func (h *authHandler) Me() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, _ := web.GetPrincipal(ctx)
+		web.ResponseOK(ctx, http.StatusOK, principal)
+	}
+}
+
+// PostUser - creates a user, the body is a user with its password:
+// {"email": "ana@clinic.com", "password": "...", "role": "dentist", "dentist_id": 1}
+func (h *authHandler) PostUser() gin.HandlerFunc {
+	type Request struct {
+		domain.User
+		Password string `json:"password" binding:"required"`
+	}
+
+	return func(ctx *gin.Context) {
+		var r Request
+		if err := ctx.ShouldBindJSON(&r); err != nil {
+			web.BindingResponse(ctx, "invalid user data", err)
+			return
+		}
+		response, err := h.s.CreateUser(ctx.Request.Context(), r.User, r.Password)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusCreated, response)
+	}
+}
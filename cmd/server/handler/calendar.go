@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/calendar"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+// calendarContentType - the media type of iCalendar files, RFC 5545 section 8.1
+const calendarContentType = "text/calendar; charset=utf-8"
+
+type calendarHandler struct {
+	s calendar.Service
+}
+
+func NewCalendarHandler(s calendar.Service) *calendarHandler {
+	return &calendarHandler{
+		s: s,
+	}
+}
+
+// GetDentistFeed - the :id parameter holds the license number of the dentist,
+// the token query parameter the secret of the feed
+func (h *calendarHandler) GetDentistFeed() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		feed, err := h.s.DentistFeed(ctx.Request.Context(), ctx.Param("id"), ctx.Query("token"))
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		ctx.Data(http.StatusOK, calendarContentType, feed)
+	}
+}
+
+// GetPatientFeed - the :id parameter holds the identity number of the patient,
+// the token query parameter the secret of the feed
+func (h *calendarHandler) GetPatientFeed() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		feed, err := h.s.PatientFeed(ctx.Request.Context(), ctx.Param("id"), ctx.Query("token"))
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		ctx.Data(http.StatusOK, calendarContentType, feed)
+	}
+}
+
+// PostDentistToken - creates a new secret for the feed of the dentist, the
+// previous URL stops working
+func (h *calendarHandler) PostDentistToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if !ownsDentist(ctx, id) {
+			forbidden(ctx)
+			return
+		}
+		response, err := h.s.NewDentistToken(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusCreated, response)
+	}
+}
+
+// PostPatientToken - creates a new secret for the feed of the patient, the
+// previous URL stops working
+func (h *calendarHandler) PostPatientToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if !ownsPatient(ctx, id) {
+			forbidden(ctx)
+			return
+		}
+		response, err := h.s.NewPatientToken(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusCreated, response)
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/dentist"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+type deactivationHandler struct {
+	s dentist.DeactivationService
+}
+
+func NewDeactivationHandler(s dentist.DeactivationService) *deactivationHandler {
+	return &deactivationHandler{
+		s: s,
+	}
+}
+
+// Deactivate - the dentist stops taking new bookings, the response lists the
+// future appointments to be reassigned or cancelled
+func (h *deactivationHandler) Deactivate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.Deactivate(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.SetETag(ctx, response.Dentist.Version)
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+// Reactivate - the dentist takes new bookings again
+func (h *deactivationHandler) Reactivate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.Reactivate(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.SetETag(ctx, response.Version)
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+func (h *deactivationHandler) GetFutureAppointments() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.GetFutureAppointments(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+// Reassign - moves the future appointments of the dentist, or the ones listed, to
+// the dentist of dentist_license
+func (h *deactivationHandler) Reassign() gin.HandlerFunc {
+	type Request struct {
+		DentistLicense string `json:"dentist_license" binding:"required"`
+		AppointmentIds []int  `json:"appointment_ids"`
+	}
+
+	return func(ctx *gin.Context) {
+		var r Request
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if err := ctx.ShouldBindJSON(&r); err != nil {
+			web.BindingResponse(ctx, "invalid request", err)
+			return
+		}
+		response, err := h.s.Reassign(ctx.Request.Context(), id, r.DentistLicense, r.AppointmentIds)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+// CancelAppointments - cancels the future appointments of the dentist, or the
+// ones listed, with the reason
+func (h *deactivationHandler) CancelAppointments() gin.HandlerFunc {
+	type Request struct {
+		Reason         string `json:"reason"`
+		AppointmentIds []int  `json:"appointment_ids"`
+	}
+
+	return func(ctx *gin.Context) {
+		var r Request
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if err := ctx.ShouldBindJSON(&r); err != nil && !errors.Is(err, io.EOF) {
+			web.BindingResponse(ctx, "invalid request", err)
+			return
+		}
+		if r.Reason == "" {
+			web.ErrorResponse(ctx, domain.NewValidationError("reason", "required", "a reason is required to cancel an appointment"))
+			return
+		}
+		response, err := h.s.CancelAppointments(ctx.Request.Context(), id, r.Reason, r.AppointmentIds)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"errors"
 	"net/http"
 	"strconv"
 
@@ -23,12 +22,21 @@ func NewDentistHandler(s dentist.Service) *dentistHandler {
 
 func (h *dentistHandler) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		response, err := h.s.GetAll()
+		opts, err := listOptions(ctx, "surname", "name", "license_number")
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
-		web.ResponseOK(ctx, http.StatusOK, response)
+		var ok bool
+		if opts.IncludeDeleted, ok = includeDeleted(ctx); !ok {
+			return
+		}
+		page, err := h.s.List(ctx.Request.Context(), opts)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponsePage(ctx, http.StatusOK, page.Items, page.Total, opts.Limit, opts.Offset)
 	}
 }
 
@@ -37,15 +45,16 @@ func (h *dentistHandler) GetByID() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
 			return
 		}
 
-		response, err := h.s.GetByID(id)
+		response, err := h.s.GetByID(ctx.Request.Context(), id)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", "dentist not found")
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -55,21 +64,22 @@ func (h *dentistHandler) Post() gin.HandlerFunc {
 		var dentist domain.Dentist
 		err := ctx.ShouldBindJSON(&dentist)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid dentist")
+			web.BindingResponse(ctx, "invalid dentist data", err)
 			return
 		}
 
 		isValid, err := isEmptyDentist(&dentist)
 		if !isValid {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 
-		response, err := h.s.Create(dentist)
+		response, err := h.s.Create(ctx.Request.Context(), dentist)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusCreated, response)
 	}
 }
@@ -79,26 +89,34 @@ func (h *dentistHandler) Put() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id")
+			return
+		}
+		version, err := web.IfMatch(ctx)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		var dentist domain.Dentist
 		err = ctx.ShouldBindJSON(&dentist)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid dentist data")
+			web.BindingResponse(ctx, "invalid dentist data", err)
+			return
 		}
 
 		isValid, err := isEmptyDentist(&dentist)
 		if !isValid {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 
-		response, err := h.s.Update(id, dentist)
+		dentist.Version = version
+		response, err := h.s.Update(ctx.Request.Context(), id, dentist)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusConflict, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -114,24 +132,31 @@ func (h *dentistHandler) Patch() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		version, err := web.IfMatch(ctx)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		if err := ctx.ShouldBindJSON(&r); err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid request")
+			web.BindingResponse(ctx, "invalid request", err)
 			return
 		}
 		update := domain.Dentist{
 			Surname:       r.Surname,
 			Name:          r.Name,
 			LicenseNumber: r.LicenseNumber,
+			Version:       version,
 		}
 
-		updated, err := h.s.Update(id, update)
+		updated, err := h.s.Update(ctx.Request.Context(), id, update)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, updated.Version)
 		web.ResponseOK(ctx, http.StatusOK, updated)
 	}
 }
@@ -141,22 +166,45 @@ func (h *dentistHandler) Delete() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
 			return
 		}
-		err = h.s.Delete(id)
+		err = h.s.Delete(ctx.Request.Context(), id)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		web.DeleteResponse(ctx, http.StatusOK, "dentist deleted")
 	}
 }
 
+// Restore - undoes the deletion of the dentist
+func (h *dentistHandler) Restore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.Restore(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.SetETag(ctx, response.Version)
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
 func isEmptyDentist(dentist *domain.Dentist) (bool, error) {
-	switch {
-	case dentist.Surname == "" || dentist.Name == "" || dentist.LicenseNumber == "":
-		return false, errors.New("fields can't be empty")
+	invalid := &domain.ValidationError{}
+	requireFields(invalid, map[string]string{
+		"surname":        dentist.Surname,
+		"name":           dentist.Name,
+		"license_number": dentist.LicenseNumber,
+	})
+	if err := invalid.OrNil(); err != nil {
+		return false, err
 	}
 	return true, nil
 }
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+// pingTimeout - how long a database has to answer the readiness ping
+const pingTimeout = 2 * time.Second
+
+type healthHandler struct {
+	databases map[string]store.Database
+}
+
+// NewHealthHandler - databases are the pools pinged by the readiness check, by
+// name, none with the memory store
+func NewHealthHandler(databases map[string]store.Database) *healthHandler {
+	return &healthHandler{
+		databases: databases,
+	}
+}
+
+type health struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Live - the process is up and answering, the databases aren't checked so an
+// outage of theirs doesn't get the server restarted
+func (h *healthHandler) Live() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		web.ResponseOK(ctx, http.StatusOK, health{Status: "ok"})
+	}
+}
+
+// Ready - the server takes requests when every database answers a ping. The
+// cause of a failed ping is logged, not answered.
+func (h *healthHandler) Ready() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		response := health{Status: "ready", Checks: map[string]string{}}
+		status := http.StatusOK
+		for name, database := range h.databases {
+			pingCtx, cancel := context.WithTimeout(ctx.Request.Context(), pingTimeout)
+			err := database.PingContext(pingCtx)
+			cancel()
+			if err != nil {
+				web.Logger(ctx).WarnContext(ctx.Request.Context(), "database not ready", "database", name, "error", err)
+				response.Checks[name] = "unavailable"
+				response.Status, status = "unavailable", http.StatusServiceUnavailable
+				continue
+			}
+			response.Checks[name] = "ok"
+		}
+		web.ResponseOK(ctx, status, response)
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// listOptions - reads limit, offset, sort and the filters accepted by an endpoint
+// from the query string
+func listOptions(ctx *gin.Context, filters ...string) (store.ListOptions, error) {
+	opts := store.ListOptions{
+		Limit:   defaultPageLimit,
+		Sort:    ctx.Query("sort"),
+		Filters: map[string]string{},
+	}
+
+	var err error
+	if limit := ctx.Query("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit < 1 || opts.Limit > maxPageLimit {
+			return store.ListOptions{}, domain.NewValidationError("limit", "range", "must be a number between 1 and "+strconv.Itoa(maxPageLimit))
+		}
+	}
+	if offset := ctx.Query("offset"); offset != "" {
+		if opts.Offset, err = strconv.Atoi(offset); err != nil || opts.Offset < 0 {
+			return store.ListOptions{}, domain.NewValidationError("offset", "min", "must be a positive number")
+		}
+	}
+	for _, filter := range filters {
+		if value := ctx.Query(filter); value != "" {
+			opts.Filters[filter] = value
+		}
+	}
+	return opts, nil
+}
+
+// includeDeleted - reads the include_deleted query parameter, which only admins can
+// set. Answers 400 or 403 and returns false when it can't be used.
+func includeDeleted(ctx *gin.Context) (bool, bool) {
+	value := ctx.Query("include_deleted")
+	if value == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		web.ErrorResponse(ctx, domain.NewValidationError("include_deleted", "boolean", "must be true or false"))
+		return false, false
+	}
+	if principal, _ := web.GetPrincipal(ctx); include && principal.Role != domain.RoleAdmin {
+		forbidden(ctx)
+		return false, false
+	}
+	return include, true
+}
+
+// parseDateFilter - parses the from and to query parameters, which accept a date
+// (2023-01-30) or a date and time (2023-01-30T23:59:00-03:00), the legacy formats
+// 30/01/2023 and 30/01/2023 23:59 included. Dates alone are days of the clinic.
+func parseDateFilter(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := domain.ParseDateTime(value); err == nil {
+		return t, nil
+	}
+	t, err := domain.ParseDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: dates must be in format: %s, or dates and times: %s", domain.ErrInvalidDate, domain.DateFormats, domain.DateTimeFormats)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return t, nil
+}
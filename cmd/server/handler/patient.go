@@ -1,13 +1,13 @@
 package handler
 
 import (
-	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
 	"github.com/mauriciogregory/esp_backIII_go/internal/patient"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
 	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
 )
 
@@ -23,12 +23,25 @@ func NewPatientHandler(s patient.Service) *patientHandler {
 
 func (h *patientHandler) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		patients, err := h.s.GetAll()
+		opts, err := listOptions(ctx, "surname", "name", "identity_number")
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
-		web.ResponseOK(ctx, http.StatusOK, patients)
+		var ok bool
+		if opts.IncludeDeleted, ok = includeDeleted(ctx); !ok {
+			return
+		}
+		// dentists only see the patients they have appointments with
+		if principal, _ := web.GetPrincipal(ctx); principal.Role == domain.RoleDentist {
+			opts.Filters["dentist_license"] = principal.DentistLicense
+		}
+		page, err := h.s.List(ctx.Request.Context(), opts)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponsePage(ctx, http.StatusOK, page.Items, page.Total, opts.Limit, opts.Offset)
 	}
 }
 
@@ -37,15 +50,20 @@ func (h *patientHandler) GetByID() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
 			return
 		}
 
-		patient, err := h.s.GetByID(id)
+		patient, err := h.s.GetByID(ctx.Request.Context(), id)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", "patient not found")
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		if !h.canSee(ctx, patient) {
+			forbidden(ctx)
 			return
 		}
+		web.SetETag(ctx, patient.Version)
 		web.ResponseOK(ctx, http.StatusOK, patient)
 	}
 }
@@ -55,22 +73,23 @@ func (h *patientHandler) Post() gin.HandlerFunc {
 		var patient domain.Patient
 		err := ctx.ShouldBindJSON(&patient)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid patient")
+			web.BindingResponse(ctx, "invalid patient data", err)
 			return
 		}
 
 		isValid, err := isEmptyPatient(&patient)
 		if !isValid {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 
-		response, err := h.s.Create(patient)
+		response, err := h.s.Create(ctx.Request.Context(), patient)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusCreated, response)
 	}
 }
@@ -80,26 +99,34 @@ func (h *patientHandler) Put() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid patient id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid patient id provided")
+			return
+		}
+		version, err := web.IfMatch(ctx)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		var patient domain.Patient
 		err = ctx.ShouldBindJSON(&patient)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid patient data")
+			web.BindingResponse(ctx, "invalid patient data", err)
+			return
 		}
 
 		isValid, err := isEmptyPatient(&patient)
 		if !isValid {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 
-		response, err := h.s.Update(id, patient)
+		patient.Version = version
+		response, err := h.s.Update(ctx.Request.Context(), id, patient)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusConflict, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -117,30 +144,36 @@ func (h *patientHandler) Patch() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		version, err := web.IfMatch(ctx)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		if err := ctx.ShouldBindJSON(&r); err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid request")
+			web.BindingResponse(ctx, "invalid request", err)
+			return
+		}
+		createdAt, err := optionalDateTime("created_at", r.CreatedAt)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		update := domain.Patient{
 			Surname:        r.Surname,
 			Name:           r.Name,
 			IdentityNumber: r.IdentityNumber,
-			CreatedAt:      r.CreatedAt,
-		}
-		if update.CreatedAt != "" {
-			if !validateDateTime(update.CreatedAt) {
-				web.BadResponse(ctx, http.StatusBadRequest, "error", "please the patient created_at field must be in format: 30/01/2023 23:59 or 30/01/2023 23:59:59")
-				return
-			}
+			CreatedAt:      createdAt,
+			Version:        version,
 		}
-		response, err := h.s.Update(id, update)
+		response, err := h.s.Update(ctx.Request.Context(), id, update)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
+		web.SetETag(ctx, response.Version)
 		web.ResponseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -150,24 +183,60 @@ func (h *patientHandler) Delete() gin.HandlerFunc {
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusBadRequest, "error", "invalid id provided")
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
 			return
 		}
-		err = h.s.Delete(id)
+		err = h.s.Delete(ctx.Request.Context(), id)
 		if err != nil {
-			web.BadResponse(ctx, http.StatusNotFound, "error", err.Error())
+			web.ErrorResponse(ctx, err)
 			return
 		}
 		web.DeleteResponse(ctx, http.StatusOK, "patient deleted")
 	}
 }
 
+// Restore - undoes the deletion of the patient
+func (h *patientHandler) Restore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.Restore(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.SetETag(ctx, response.Version)
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
 func isEmptyPatient(patient *domain.Patient) (bool, error) {
-	switch {
-	case patient.Surname == "" || patient.Name == "" || patient.CreatedAt == "" || patient.IdentityNumber == "":
-		return false, errors.New("patient fields can't be empty")
-	case !validateDateTime(patient.CreatedAt):
-		return false, errors.New("please the patient created_at field must be in format: 30/01/2023 23:59 or 30/01/2023 23:59:59")
+	invalid := &domain.ValidationError{}
+	requireFields(invalid, map[string]string{
+		"surname":         patient.Surname,
+		"name":            patient.Name,
+		"identity_number": patient.IdentityNumber,
+		"created_at":      domain.FormatDateTime(patient.CreatedAt),
+	})
+	if err := invalid.OrNil(); err != nil {
+		return false, err
 	}
 	return true, nil
 }
+
+// canSee - dentists only see the patients they have appointments with and
+// patients only themselves
+func (h *patientHandler) canSee(ctx *gin.Context, p domain.Patient) bool {
+	principal, _ := web.GetPrincipal(ctx)
+	if principal.Role != domain.RoleDentist {
+		return ownsPatient(ctx, p.Id)
+	}
+	page, err := h.s.List(ctx.Request.Context(), store.ListOptions{Limit: 1, Filters: map[string]string{
+		"identity_number": p.IdentityNumber,
+		"dentist_license": principal.DentistLicense,
+	}})
+	return err == nil && page.Total > 0
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/internal/schedule"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+// defaultSlotDuration - used by GetAvailability when duration isn't provided, in minutes
+const defaultSlotDuration = 60
+
+type scheduleHandler struct {
+	s schedule.Service
+}
+
+func NewScheduleHandler(s schedule.Service) *scheduleHandler {
+	return &scheduleHandler{
+		s: s,
+	}
+}
+
+func (h *scheduleHandler) GetSchedule() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.GetSchedule(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+func (h *scheduleHandler) PutSchedule() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if !ownsDentist(ctx, id) {
+			forbidden(ctx)
+			return
+		}
+		var s domain.Schedule
+		if err := ctx.ShouldBindJSON(&s); err != nil {
+			web.BindingResponse(ctx, "invalid schedule data", err)
+			return
+		}
+		s.DentistId = id
+		response, err := h.s.UpdateSchedule(ctx.Request.Context(), s)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+func (h *scheduleHandler) GetAllTimeOff() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		response, err := h.s.GetAllTimeOff(ctx.Request.Context(), id)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
+
+func (h *scheduleHandler) PostTimeOff() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if !ownsDentist(ctx, id) {
+			forbidden(ctx)
+			return
+		}
+		var t domain.TimeOff
+		if err := ctx.ShouldBindJSON(&t); err != nil {
+			web.BindingResponse(ctx, "invalid time off data", err)
+			return
+		}
+		t.DentistId = id
+		response, err := h.s.CreateTimeOff(ctx.Request.Context(), t)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusCreated, response)
+	}
+}
+
+func (h *scheduleHandler) DeleteTimeOff() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if !ownsDentist(ctx, id) {
+			forbidden(ctx)
+			return
+		}
+		timeOffID, err := strconv.Atoi(ctx.Param("time_off_id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid time off id provided")
+			return
+		}
+		if err := h.s.DeleteTimeOff(ctx.Request.Context(), id, timeOffID); err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.DeleteResponse(ctx, http.StatusOK, "time off removed")
+	}
+}
+
+// GetAvailability - lists the free slots of a dentist, from and to accept the
+// formats of parseDateFilter, duration is in minutes
+func (h *scheduleHandler) GetAvailability() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.BadResponse(ctx, http.StatusBadRequest, "invalid id provided")
+			return
+		}
+		if ctx.Query("from") == "" || ctx.Query("to") == "" {
+			web.BadResponse(ctx, http.StatusBadRequest, "from and to are required")
+			return
+		}
+		from, err := parseDateFilter(ctx.Query("from"), false)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		to, err := parseDateFilter(ctx.Query("to"), true)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		duration := defaultSlotDuration
+		if ctx.Query("duration") != "" {
+			if duration, err = strconv.Atoi(ctx.Query("duration")); err != nil {
+				web.ErrorResponse(ctx, domain.NewValidationError("duration", "number", "must be a number of minutes"))
+				return
+			}
+		}
+
+		response, err := h.s.GetAvailability(ctx.Request.Context(), id, from, to, time.Duration(duration)*time.Minute)
+		if err != nil {
+			web.ErrorResponse(ctx, err)
+			return
+		}
+		web.ResponseOK(ctx, http.StatusOK, response)
+	}
+}
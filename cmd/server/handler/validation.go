@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// requireFields - reports the empty fields, in the order of their names
+func requireFields(invalid *domain.ValidationError, fields map[string]string) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fields[name] == "" {
+			invalid.Add(name, "required", "can't be empty")
+		}
+	}
+}
+
+// optionalDateTime - parses the date and time of a field which may be left out,
+// an empty value is the zero time
+func optionalDateTime(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := domain.ParseDateTime(value)
+	if err != nil {
+		return time.Time{}, domain.InvalidDate(field, domain.DateTimeFormats)
+	}
+	return t, nil
+}
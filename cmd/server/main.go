@@ -1,74 +1,273 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"github.com/mauriciogregory/esp_backIII_go/cmd/server/handler"
+	"github.com/mauriciogregory/esp_backIII_go/cmd/server/middleware"
+	"github.com/mauriciogregory/esp_backIII_go/config"
 	"github.com/mauriciogregory/esp_backIII_go/internal/appointment"
+	"github.com/mauriciogregory/esp_backIII_go/internal/audit"
+	"github.com/mauriciogregory/esp_backIII_go/internal/auth"
+	"github.com/mauriciogregory/esp_backIII_go/internal/calendar"
 	"github.com/mauriciogregory/esp_backIII_go/internal/dentist"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
 	"github.com/mauriciogregory/esp_backIII_go/internal/patient"
+	"github.com/mauriciogregory/esp_backIII_go/internal/purge"
+	"github.com/mauriciogregory/esp_backIII_go/internal/schedule"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/logging"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/metrics"
 	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
 )
 
-func init() {
-	time.Local, _ = time.LoadLocation("America/Sao Paulo")
-}
-
 func main() {
-	err := godotenv.Load()
+	// every log line is a JSON object, the ones of a request hold its request_id.
+	// The level is the configured one once the configuration is read.
+	logger := logging.New(os.Stdout, slog.LevelInfo)
+	slog.SetDefault(logger)
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalln("Error loading .env file", err.Error())
+		fatal(logger, "loading the configuration failed", err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := cfg.Database.Validate(); err != nil {
+			fatal(logger, "invalid configuration", err)
+		}
+		db, err := config.ConnectDatabase(cfg.Database)
+		if err != nil {
+			fatal(logger, "connecting to the database failed", err)
+		}
+		err = runMigrations(db, args[1:])
+		db.Close()
+		if err != nil {
+			fatal(logger, "running the migrations failed", err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal(logger, "invalid configuration", err)
 	}
+	logLevel, _ := logging.ParseLevel(cfg.LogLevel)
+	logger = logging.New(os.Stdout, logLevel)
+	slog.SetDefault(logger)
 
-	sqlStore := store.NewSQLStore()
-	apStore := store.NewSQLAp()
-	appRepo := appointment.NewRepository(apStore)
-	appService := appointment.NewService(appRepo)
+	clinicLocation, _ := time.LoadLocation(cfg.ClinicTimezone)
+	// dates without offset are read and every date is answered at the clinic zone
+	domain.SetClinicLocation(clinicLocation)
+
+	// the metrics are served at /metrics for Prometheus to scrape
+	serverMetrics := metrics.New()
+
+	var sqlStore store.Store
+	var apStore store.ApStore
+	// the pool shared by the SQL stores, checked by /readyz and closed at shutdown
+	databases := map[string]store.Database{}
+	switch cfg.StoreDriver {
+	case "memory":
+		memoryStore := store.NewMemoryStore()
+		sqlStore, apStore = memoryStore, memoryStore.Appointments()
+	case "mysql":
+		db, err := config.ConnectDatabase(cfg.Database)
+		if err != nil {
+			fatal(logger, "connecting to the database failed", err)
+		}
+		logger.Info("connected to the database", "host", cfg.Database.Host, "name", cfg.Database.Name,
+			"max_open_conns", cfg.Database.MaxOpenConns, "max_idle_conns", cfg.Database.MaxIdleConns)
+		if err := serverMetrics.RegisterDB("mysql", db); err != nil {
+			fatal(logger, "registering the metrics of the database failed", err)
+		}
+		if cfg.Migrate {
+			if err := applyPendingMigrations(db); err != nil {
+				fatal(logger, "applying the migrations failed", err)
+			}
+		}
+		sqlStore, apStore = store.NewSQLStore(db, logger, serverMetrics), store.NewSQLAp(db, logger, serverMetrics)
+		databases["mysql"] = db
+	}
+	// every change of the dentists, patients and appointments is recorded in the audit log
+	auditedStore := store.NewAuditedStore(sqlStore, apStore)
+	sqlStore, apStore = auditedStore, auditedStore.Appointments()
+	scheduleRepo := schedule.NewRepository(sqlStore.Schedules(), sqlStore.Dentists(), apStore)
+	scheduleService := schedule.NewService(scheduleRepo, clinicLocation)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService)
+	appRepo := appointment.NewRepository(apStore, sqlStore)
+	appService := appointment.NewService(appRepo, scheduleService, serverMetrics)
 	appHandler := handler.NewAppointmentHandler(appService)
-	dentistRepo := dentist.NewRepository(sqlStore)
+	dentistRepo := dentist.NewRepository(sqlStore.Dentists())
 	dentistService := dentist.NewService(dentistRepo)
 	dentistHandler := handler.NewDentistHandler(dentistService)
-	patientRepo := patient.NewRepository(sqlStore)
+	deactivationHandler := handler.NewDeactivationHandler(dentist.NewDeactivationService(dentistRepo, appService, scheduleService))
+	patientRepo := patient.NewRepository(sqlStore.Patients())
 	patientService := patient.NewService(patientRepo)
 	patientHandler := handler.NewPatientHandler(patientService)
 
+	auditHandler := handler.NewAuditHandler(audit.NewService(audit.NewRepository(sqlStore.Audit())))
+
+	calendarRepo := calendar.NewRepository(sqlStore.Dentists(), sqlStore.Patients(), apStore, sqlStore.CalendarTokens())
+	calendarService := calendar.NewService(calendarRepo, clinicLocation)
+	calendarHandler := handler.NewCalendarHandler(calendarService)
+
+	authRepo := auth.NewRepository(sqlStore.Users(), sqlStore.Dentists(), sqlStore.Patients())
+	authService, err := auth.NewService(authRepo, auth.Config{
+		Secret:     []byte(cfg.Auth.JWTSecret),
+		AccessTTL:  cfg.Auth.AccessTTL,
+		RefreshTTL: cfg.Auth.RefreshTTL,
+	})
+	if err != nil {
+		fatal(logger, "invalid JWT_SECRET", err)
+	}
+	if cfg.Auth.AdminEmail != "" {
+		if err := authService.EnsureAdmin(context.Background(), cfg.Auth.AdminEmail, cfg.Auth.AdminPassword); err != nil {
+			fatal(logger, "creating the admin user failed", err)
+		}
+	}
+	authHandler := handler.NewAuthHandler(authService)
+
+	// SIGTERM and SIGINT stop the background jobs and shut the server down
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var jobs sync.WaitGroup
+	// the deleted records are kept forever when no retention is set
+	if cfg.Purge.Retention > 0 {
+		job := purge.NewJob(cfg.Purge.Retention, cfg.Purge.Interval, apStore, sqlStore.Patients(), sqlStore.Dentists(), logger)
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job.Run(ctx)
+		}()
+	}
+	healthHandler := handler.NewHealthHandler(databases)
+
+	authenticated := middleware.Authenticate(authService)
+	admin := middleware.RequireRole(domain.RoleAdmin)
+	staff := middleware.RequireRole(domain.RoleAdmin, domain.RoleReceptionist)
+
 	r := gin.New()
+	r.Use(middleware.RequestLog(logger), middleware.Metrics(serverMetrics), middleware.Recover(), middleware.Timeout(cfg.RequestTimeout))
+	r.GET("/metrics", gin.WrapH(serverMetrics.Handler()))
+	r.GET("/healthz", healthHandler.Live())
+	r.GET("/readyz", healthHandler.Ready())
 	api := r.Group("/")
 	{
-		appointments := api.Group("/appointments")
+		authRoutes := api.Group("/auth")
+		{
+			authRoutes.POST("/login", authHandler.Login())
+			authRoutes.POST("/refresh", authHandler.Refresh())
+			authRoutes.GET("/me", authenticated, authHandler.Me())
+		}
+		api.POST("/users", authenticated, admin, authHandler.PostUser())
+		api.GET("/audit", authenticated, admin, auditHandler.GetAll())
+
+		appointments := api.Group("/appointments", authenticated)
 		{
 			appointments.GET("", appHandler.GetAll())
 			appointments.GET(":id", appHandler.GetByID())
 			appointments.GET("/patient/:identity_number", appHandler.GetAllByIdentityNumber())
 			appointments.GET("/dentist/:license_number", appHandler.GetAllByLicenseNumber())
-			appointments.POST("", appHandler.Post())
-			appointments.PUT(":id", appHandler.Put())
-			appointments.PATCH(":id", appHandler.Patch())
-			appointments.DELETE(":id", appHandler.Delete())
+			appointments.POST("", staff, appHandler.Post())
+			appointments.POST("/series", staff, appHandler.PostSeries())
+			appointments.PUT(":id", staff, appHandler.Put())
+			appointments.PATCH(":id", staff, appHandler.Patch())
+			appointments.DELETE(":id", staff, appHandler.Delete())
+			appointments.POST(":id/restore", staff, appHandler.Restore())
+			appointments.GET(":id/history", appHandler.GetStatusHistory())
+			// patients confirm and cancel and dentists start and complete their own appointments
+			patientOrStaff := middleware.RequireRole(domain.RoleAdmin, domain.RoleReceptionist, domain.RolePatient)
+			dentistOrStaff := middleware.RequireRole(domain.RoleAdmin, domain.RoleReceptionist, domain.RoleDentist)
+			appointments.POST(":id/confirm", patientOrStaff, appHandler.ChangeStatus(domain.StatusConfirmed))
+			appointments.POST(":id/check-in", staff, appHandler.ChangeStatus(domain.StatusCheckedIn))
+			appointments.POST(":id/start", dentistOrStaff, appHandler.ChangeStatus(domain.StatusInProgress))
+			appointments.POST(":id/complete", dentistOrStaff, appHandler.ChangeStatus(domain.StatusCompleted))
+			appointments.POST(":id/cancel", patientOrStaff, appHandler.ChangeStatus(domain.StatusCancelled))
+			appointments.POST(":id/no-show", staff, appHandler.ChangeStatus(domain.StatusNoShow))
 		}
-		dentists := api.Group("/dentists")
+		// the feeds are protected by their own token so calendar applications can read them
+		api.GET("/dentists/:id/calendar.ics", calendarHandler.GetDentistFeed())
+		api.GET("/patients/:id/calendar.ics", calendarHandler.GetPatientFeed())
+
+		dentists := api.Group("/dentists", authenticated)
 		{
+			dentistOrAdmin := middleware.RequireRole(domain.RoleAdmin, domain.RoleDentist)
 			dentists.GET("", dentistHandler.GetAll())
 			dentists.GET(":id", dentistHandler.GetByID())
-			dentists.POST("", dentistHandler.Post())
-			dentists.PUT(":id", dentistHandler.Put())
-			dentists.PATCH(":id", dentistHandler.Patch())
-			dentists.DELETE(":id", dentistHandler.Delete())
+			dentists.POST("", admin, dentistHandler.Post())
+			dentists.PUT(":id", admin, dentistHandler.Put())
+			dentists.PATCH(":id", admin, dentistHandler.Patch())
+			dentists.DELETE(":id", admin, dentistHandler.Delete())
+			dentists.POST(":id/restore", admin, dentistHandler.Restore())
+			dentists.POST(":id/deactivate", admin, deactivationHandler.Deactivate())
+			dentists.POST(":id/reactivate", admin, deactivationHandler.Reactivate())
+			dentists.GET(":id/future-appointments", staff, deactivationHandler.GetFutureAppointments())
+			dentists.POST(":id/reassign", admin, deactivationHandler.Reassign())
+			dentists.POST(":id/cancel-appointments", admin, deactivationHandler.CancelAppointments())
+			dentists.GET(":id/schedule", scheduleHandler.GetSchedule())
+			dentists.PUT(":id/schedule", dentistOrAdmin, scheduleHandler.PutSchedule())
+			dentists.GET(":id/time-off", scheduleHandler.GetAllTimeOff())
+			dentists.POST(":id/time-off", dentistOrAdmin, scheduleHandler.PostTimeOff())
+			dentists.DELETE(":id/time-off/:time_off_id", dentistOrAdmin, scheduleHandler.DeleteTimeOff())
+			dentists.GET(":id/availability", scheduleHandler.GetAvailability())
+			dentists.POST(":id/calendar-token", dentistOrAdmin, calendarHandler.PostDentistToken())
 		}
-		patients := api.Group("/patients")
+		patients := api.Group("/patients", authenticated)
 		{
-			patients.GET("", patientHandler.GetAll())
+			patients.GET("", middleware.RequireRole(domain.RoleAdmin, domain.RoleReceptionist, domain.RoleDentist), patientHandler.GetAll())
 			patients.GET(":id", patientHandler.GetByID())
-			patients.POST("", patientHandler.Post())
-			patients.PUT(":id", patientHandler.Put())
-			patients.PATCH(":id", patientHandler.Patch())
-			patients.DELETE(":id", patientHandler.Delete())
+			patients.POST("", staff, patientHandler.Post())
+			patients.PUT(":id", staff, patientHandler.Put())
+			patients.PATCH(":id", staff, patientHandler.Patch())
+			patients.DELETE(":id", staff, patientHandler.Delete())
+			patients.POST(":id/restore", staff, patientHandler.Restore())
+			patients.POST(":id/calendar-token", middleware.RequireRole(domain.RoleAdmin, domain.RoleReceptionist, domain.RolePatient), calendarHandler.PostPatientToken())
 		}
 	}
 
-	r.Run("127.0.0.1:8000")
+	server := &http.Server{Addr: cfg.Host, Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	logger.Info("listening", "address", server.Addr)
+	select {
+	case err := <-serverErr:
+		fatal(logger, "the server stopped", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// no new connection is accepted, the requests in flight have the shutdown
+	// timeout to finish before the databases are closed
+	logger.Info("shutting down", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("draining the requests failed", "error", err)
+	}
+	jobs.Wait()
+	for name, database := range databases {
+		if err := database.Close(); err != nil {
+			logger.Error("closing the database failed", "database", name, "error", err)
+		}
+	}
+	logger.Info("server stopped")
+}
+
+// fatal - logs the error which keeps the server from running and exits
+func fatal(logger *slog.Logger, message string, err error) {
+	logger.Error(message, "error", err)
+	os.Exit(1)
 }
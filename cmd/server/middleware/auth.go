@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/auth"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+// Authenticate - requires a valid access token in the Authorization header,
+// "Bearer <token>", and stores its principal in the context
+func Authenticate(s auth.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scheme, token, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			web.BadResponse(ctx, http.StatusUnauthorized, "a bearer access token is required")
+			ctx.Abort()
+			return
+		}
+		principal, err := s.Authenticate(token)
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			web.ErrorResponse(ctx, err)
+			ctx.Abort()
+			return
+		}
+		web.SetPrincipal(ctx, principal)
+		ctx.Next()
+	}
+}
+
+// RequireRole - answers 403 unless the principal has one of roles, must run
+// after Authenticate
+func RequireRole(roles ...domain.Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, ok := web.GetPrincipal(ctx)
+		if !ok || !principal.HasRole(roles...) {
+			web.BadResponse(ctx, http.StatusForbidden, "you aren't allowed to perform this operation")
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/auth"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// tokenService - accepts the tokens named after a role as a principal of that role
+type tokenService struct {
+	auth.Service
+}
+
+func (tokenService) Authenticate(token string) (domain.Principal, error) {
+	role := domain.Role(token)
+	if !role.IsValid() {
+		return domain.Principal{}, domain.ErrInvalidToken
+	}
+	return domain.Principal{UserId: 1, Role: role}, nil
+}
+
+func TestAuthenticateAndRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/staff", Authenticate(tokenService{}), RequireRole(domain.RoleAdmin, domain.RoleReceptionist), func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"another scheme", "Basic admin", http.StatusUnauthorized},
+		{"no token", "Bearer ", http.StatusUnauthorized},
+		{"invalid token", "Bearer nobody", http.StatusUnauthorized},
+		{"admin", "Bearer admin", http.StatusNoContent},
+		{"receptionist, lower case scheme", "bearer receptionist", http.StatusNoContent},
+		{"dentist", "Bearer dentist", http.StatusForbidden},
+		{"patient", "Bearer patient", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/staff", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+// TestRequireRoleWithoutAuthenticate - a route without a principal is forbidden
+func TestRequireRoleWithoutAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin", RequireRole(domain.RoleAdmin), func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status %d, want 403", w.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+// Recover - answers a panic of the next handlers as a 500 problem whose correlation
+// id is logged with the stack, so one bad request doesn't go without answer
+func Recover() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// the handler asked to abort the response, net/http handles it
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			err := fmt.Errorf("panic: %v\n%s", recovered, debug.Stack())
+			if ctx.Writer.Written() {
+				web.Logger(ctx).ErrorContext(ctx.Request.Context(), "panic after the answer was written", "method", ctx.Request.Method, "path", ctx.Request.URL.Path, "error", err)
+				ctx.Abort()
+				return
+			}
+			web.ErrorResponse(ctx, err)
+			ctx.Abort()
+		}()
+		ctx.Next()
+	}
+}
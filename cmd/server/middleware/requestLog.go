@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/logging"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/web"
+)
+
+// requestIDHeader - the header carrying the id of a request, in both directions
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength - longer ids sent by clients are replaced
+const maxRequestIDLength = 128
+
+// RequestLog - gives the request an id, the X-Request-ID sent by the client when
+// it's valid, which is answered in the same header and held by every log line of
+// the request. Once answered the request is logged with its method, path, status,
+// latency and principal, a 5xx as an error.
+func RequestLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		id := ctx.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		ctx.Header(requestIDHeader, id)
+		ctx.Request = ctx.Request.WithContext(logging.WithRequestID(ctx.Request.Context(), id))
+		web.SetLogger(ctx, logger)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if principal, ok := web.GetPrincipal(ctx); ok {
+			attrs = append(attrs, slog.Group("principal",
+				slog.Int("user_id", principal.UserId),
+				slog.String("role", string(principal.Role))))
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// validRequestID - an id a client can choose: letters, digits, '-', '_', '.' and ':'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/mauriciogregory/esp_backIII_go/pkg/migration"
+)
+
+const migrateUsage = "usage: server migrate up | down [steps] | status"
+
+// runMigrations - handles the migrate subcommand: up applies every pending
+// migration, down reverts the last one (or the last steps ones) and status
+// lists them all
+func runMigrations(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	runner, err := migration.NewRunner(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up()
+		for _, m := range applied {
+			slog.Info("migration applied", "version", m.Version, "name", m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			slog.Info("no pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New("steps must be a positive number")
+			}
+		}
+		reverted, err := runner.Down(steps)
+		for _, m := range reverted {
+			slog.Info("migration reverted", "version", m.Version, "name", m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := runner.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// applyPendingMigrations - used by the -migrate flag before starting the server
+func applyPendingMigrations(db *sql.DB) error {
+	return runMigrations(db, []string{"up"})
+}
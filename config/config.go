@@ -0,0 +1,340 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Config - the settings of the server. Each one is read, from the lowest to the
+// highest precedence, from its default, the YAML file, the environment and the
+// command line.
+type Config struct {
+	// Host - the address the server listens at, host:port
+	Host           string        `yaml:"host"`
+	LogLevel       string        `yaml:"log_level"`
+	ClinicTimezone string        `yaml:"clinic_timezone"`
+	StoreDriver    string        `yaml:"store_driver"`
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// ShutdownTimeout - how long the requests in flight have to finish at shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// Migrate - applies the pending migrations before starting the server
+	Migrate  bool           `yaml:"migrate"`
+	Database DatabaseConfig `yaml:"database"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Purge    PurgeConfig    `yaml:"purge"`
+}
+
+// DatabaseConfig - the MySQL database of the SQL stores and the pool of
+// connections they share
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// MaxOpenConns - the connections open at most, in use or idle, unlimited when 0
+	MaxOpenConns int `yaml:"max_open_conns"`
+	// MaxIdleConns - the idle connections kept open, none when 0
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// ConnMaxLifetime and ConnMaxIdleTime - how long a connection is reused and
+	// kept idle before it's closed, forever when 0
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// DialTimeout, ReadTimeout and WriteTimeout - the timeouts of the driver
+	// connecting, reading and writing, none when 0
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// Params - other parameters of the DSN as a query string, such as
+	// charset=utf8mb4&tls=true
+	Params string `yaml:"params"`
+}
+
+// AuthConfig - the signing of the tokens and the admin created at startup
+type AuthConfig struct {
+	JWTSecret  string        `yaml:"jwt_secret"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+	// AdminEmail - when set an admin is created with AdminPassword if missing
+	AdminEmail    string `yaml:"admin_email"`
+	AdminPassword string `yaml:"admin_password"`
+}
+
+// PurgeConfig - the deleted records are kept for Retention, forever when zero
+type PurgeConfig struct {
+	Retention time.Duration `yaml:"retention"`
+	Interval  time.Duration `yaml:"interval"`
+}
+
+// Default - the settings when nothing else is given
+func Default() Config {
+	return Config{
+		Host:            "127.0.0.1:8000",
+		LogLevel:        "info",
+		ClinicTimezone:  "America/Sao_Paulo",
+		StoreDriver:     "mysql",
+		RequestTimeout:  10 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+		Database: DatabaseConfig{
+			Host:            "127.0.0.1",
+			Port:            3306,
+			Name:            "clinica_odonto",
+			User:            "root",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			DialTimeout:     5 * time.Second,
+		},
+		Auth: AuthConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Purge: PurgeConfig{
+			Interval: 24 * time.Hour,
+		},
+	}
+}
+
+// option - a setting with the names it's given by in the environment and the
+// command line, secrets have no flag so they don't show in the process list
+type option struct {
+	env   string
+	flag  string
+	usage string
+	value func(c *Config) interface{}
+}
+
+var options = []option{
+	{"HOST", "host", "address the server listens at, host:port", func(c *Config) interface{} { return &c.Host }},
+	{"LOG_LEVEL", "log-level", "debug, info, warn or error", func(c *Config) interface{} { return &c.LogLevel }},
+	{"CLINIC_TIMEZONE", "clinic-timezone", "IANA zone of the clinic", func(c *Config) interface{} { return &c.ClinicTimezone }},
+	{"STORE_DRIVER", "store-driver", "mysql or memory", func(c *Config) interface{} { return &c.StoreDriver }},
+	{"REQUEST_TIMEOUT", "request-timeout", "longest time a request can take", func(c *Config) interface{} { return &c.RequestTimeout }},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "longest time the requests in flight have to finish at shutdown", func(c *Config) interface{} { return &c.ShutdownTimeout }},
+	{"MIGRATE", "migrate", "apply pending database migrations before starting the server", func(c *Config) interface{} { return &c.Migrate }},
+	{"DATABASE_URL", "db-host", "host of the MySQL database", func(c *Config) interface{} { return &c.Database.Host }},
+	{"DATABASE_PORT", "db-port", "port of the MySQL database", func(c *Config) interface{} { return &c.Database.Port }},
+	{"DATABASE_NAME", "db-name", "name of the MySQL database", func(c *Config) interface{} { return &c.Database.Name }},
+	{"MYSQL_USER", "db-user", "user of the MySQL database", func(c *Config) interface{} { return &c.Database.User }},
+	{"MYSQL_PASSWORD", "", "", func(c *Config) interface{} { return &c.Database.Password }},
+	{"DATABASE_MAX_OPEN_CONNS", "db-max-open-conns", "connections open at most, unlimited when 0", func(c *Config) interface{} { return &c.Database.MaxOpenConns }},
+	{"DATABASE_MAX_IDLE_CONNS", "db-max-idle-conns", "idle connections kept open", func(c *Config) interface{} { return &c.Database.MaxIdleConns }},
+	{"DATABASE_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "how long a connection is reused, forever when 0", func(c *Config) interface{} { return &c.Database.ConnMaxLifetime }},
+	{"DATABASE_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "how long a connection is kept idle, forever when 0", func(c *Config) interface{} { return &c.Database.ConnMaxIdleTime }},
+	{"DATABASE_DIAL_TIMEOUT", "db-dial-timeout", "timeout of connecting to the database", func(c *Config) interface{} { return &c.Database.DialTimeout }},
+	{"DATABASE_READ_TIMEOUT", "db-read-timeout", "timeout of the reads of a connection, none when 0", func(c *Config) interface{} { return &c.Database.ReadTimeout }},
+	{"DATABASE_WRITE_TIMEOUT", "db-write-timeout", "timeout of the writes of a connection, none when 0", func(c *Config) interface{} { return &c.Database.WriteTimeout }},
+	{"DATABASE_PARAMS", "db-params", "other DSN parameters, such as charset=utf8mb4&tls=true", func(c *Config) interface{} { return &c.Database.Params }},
+	{"JWT_SECRET", "", "", func(c *Config) interface{} { return &c.Auth.JWTSecret }},
+	{"JWT_ACCESS_TTL", "jwt-access-ttl", "lifetime of the access tokens", func(c *Config) interface{} { return &c.Auth.AccessTTL }},
+	{"JWT_REFRESH_TTL", "jwt-refresh-ttl", "lifetime of the refresh tokens", func(c *Config) interface{} { return &c.Auth.RefreshTTL }},
+	{"ADMIN_EMAIL", "admin-email", "email of the admin created at startup", func(c *Config) interface{} { return &c.Auth.AdminEmail }},
+	{"ADMIN_PASSWORD", "", "", func(c *Config) interface{} { return &c.Auth.AdminPassword }},
+	{"PURGE_RETENTION", "purge-retention", "how long the deleted records are kept, forever when 0", func(c *Config) interface{} { return &c.Purge.Retention }},
+	{"PURGE_INTERVAL", "purge-interval", "how often the deleted records are purged", func(c *Config) interface{} { return &c.Purge.Interval }},
+}
+
+// Load - reads the configuration from the .env file, when there's one, the YAML
+// file of -config or CONFIG_FILE, the environment and args, the command line
+// without the program name. It returns the arguments left after the flags, such
+// as a subcommand; the configuration is validated by the caller, which knows the
+// settings it needs.
+func Load(args []string) (Config, []string, error) {
+	// the .env variables don't replace the ones of the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil, fmt.Errorf("loading the .env file: %w", err)
+	}
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML file with the configuration")
+	values := map[string]*flagValue{}
+	for _, o := range options {
+		if o.flag == "" {
+			continue
+		}
+		_, isBool := o.value(&Config{}).(*bool)
+		values[o.flag] = &flagValue{isBool: isBool}
+		flags.Var(values[o.flag], o.flag, o.usage+" ("+o.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.readFile(*file); err != nil {
+			return Config{}, nil, err
+		}
+	}
+	for _, o := range options {
+		if value, ok := os.LookupEnv(o.env); ok && value != "" {
+			if err := setValue(o.value(&cfg), value); err != nil {
+				return Config{}, nil, fmt.Errorf("%s: %w", o.env, err)
+			}
+		}
+	}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, o := range options {
+			if o.flag == f.Name && err == nil {
+				if setErr := setValue(o.value(&cfg), values[o.flag].value); setErr != nil {
+					err = fmt.Errorf("-%s: %w", o.flag, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// readFile - the settings of the file replace the ones of cfg, the others are kept
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading the configuration file: %w", err)
+	}
+	defer f.Close()
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading the configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate - lists every invalid setting
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Host); err != nil {
+		invalid("host must be host:port: %v", err)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		invalid("log_level must be debug, info, warn or error")
+	}
+	if _, err := time.LoadLocation(c.ClinicTimezone); err != nil {
+		invalid("clinic_timezone: %v", err)
+	}
+	switch c.StoreDriver {
+	case "memory":
+	case "mysql":
+		if err := c.Database.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		invalid("store_driver must be mysql or memory")
+	}
+	if c.RequestTimeout <= 0 {
+		invalid("request_timeout must be positive")
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("shutdown_timeout must be positive")
+	}
+	if len(c.Auth.JWTSecret) < 32 {
+		invalid("jwt_secret is required and must have at least 32 bytes")
+	}
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+		invalid("access_ttl and refresh_ttl must be positive")
+	}
+	if c.Auth.AdminEmail != "" && c.Auth.AdminPassword == "" {
+		invalid("admin_password is required along with admin_email")
+	}
+	if c.Purge.Retention < 0 {
+		invalid("purge retention can't be negative")
+	}
+	if c.Purge.Retention > 0 && c.Purge.Interval <= 0 {
+		invalid("purge interval must be positive")
+	}
+	return errors.Join(errs...)
+}
+
+// Validate - the settings required to connect to the database
+func (d DatabaseConfig) Validate() error {
+	var errs []error
+	if d.Host == "" || d.Name == "" || d.User == "" {
+		errs = append(errs, errors.New("database host, name and user are required by the mysql store"))
+	}
+	if d.Port < 1 || d.Port > 65535 {
+		errs = append(errs, errors.New("database port must be between 1 and 65535"))
+	}
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database max_open_conns and max_idle_conns can't be negative"))
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, errors.New("database max_idle_conns can't be more than max_open_conns"))
+	}
+	if d.ConnMaxLifetime < 0 || d.ConnMaxIdleTime < 0 || d.DialTimeout < 0 || d.ReadTimeout < 0 || d.WriteTimeout < 0 {
+		errs = append(errs, errors.New("database lifetimes and timeouts can't be negative"))
+	}
+	if err := d.checkParams(); err != nil {
+		errs = append(errs, fmt.Errorf("database params: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// flagValue - the text of a flag, applied once the file and the environment are
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// setValue - parses value into the setting target points to
+func setValue(target interface{}, value string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q isn't a number", value)
+		}
+		*t = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q isn't true or false", value)
+		}
+		*t = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q isn't a duration such as 15m or 168h", value)
+		}
+		*t = d
+	default:
+		return fmt.Errorf("unsupported setting %T", target)
+	}
+	return nil
+}
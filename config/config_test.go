@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv - empties the variables of the settings, the empty ones are ignored
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, o := range options {
+		t.Setenv(o.env, "")
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	cfg, args, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != Default() {
+		t.Errorf("got %+v, want the defaults %+v", cfg, Default())
+	}
+	if len(args) != 0 {
+		t.Errorf("args %v, want none", args)
+	}
+}
+
+// TestLoadPrecedence - the file replaces the defaults, the environment the file
+// and the flags the environment
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, `
+host: 0.0.0.0:9000
+log_level: debug
+request_timeout: 20s
+database:
+  port: 3307
+  name: from_file
+purge:
+  retention: 720h
+`)
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("DATABASE_NAME", "from_env")
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+
+	cfg, args, err := Load([]string{"-config", path, "-db-name", "from_flag", "-migrate", "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		setting   string
+		got, want interface{}
+	}{
+		{"host from the file", cfg.Host, "0.0.0.0:9000"},
+		{"request_timeout from the file", cfg.RequestTimeout, 20 * time.Second},
+		{"database port from the file", cfg.Database.Port, 3307},
+		{"purge retention from the file", cfg.Purge.Retention, 720 * time.Hour},
+		{"log_level from the environment", cfg.LogLevel, "warn"},
+		{"jwt_secret from the environment", cfg.Auth.JWTSecret, "0123456789abcdef0123456789abcdef"},
+		{"database name from the flag", cfg.Database.Name, "from_flag"},
+		{"migrate from the flag", cfg.Migrate, true},
+		{"database user by default", cfg.Database.User, "root"},
+		{"purge interval by default", cfg.Purge.Interval, 24 * time.Hour},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.setting, c.got, c.want)
+		}
+	}
+	if strings.Join(args, " ") != "migrate up" {
+		t.Errorf("args %v, want the subcommand", args)
+	}
+
+	t.Setenv("CONFIG_FILE", path)
+	if cfg, _, err := Load(nil); err != nil || cfg.Host != "0.0.0.0:9000" {
+		t.Errorf("file of CONFIG_FILE: host %s, %v", cfg.Host, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		args []string
+		want string
+	}{
+		{"unknown setting in the file", nil, "hots: 0.0.0.0:9000\n", nil, "hots"},
+		{"wrong type in the file", nil, "database:\n  port: many\n", nil, "many"},
+		{"duration of the environment", map[string]string{"REQUEST_TIMEOUT": "10"}, "", nil, "REQUEST_TIMEOUT"},
+		{"number of a flag", nil, "", []string{"-db-port", "many"}, "-db-port"},
+		{"unknown flag", nil, "", []string{"-jwt-secret", "abc"}, "jwt-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+			_, _, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error about %s, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func validConfig() Config {
+	cfg := Default()
+	cfg.Auth.JWTSecret = "0123456789abcdef0123456789abcdef"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("the defaults with a secret: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"host without port", func(c *Config) { c.Host = "localhost" }, "host must be host:port"},
+		{"log level", func(c *Config) { c.LogLevel = "verbose" }, "log_level"},
+		{"time zone", func(c *Config) { c.ClinicTimezone = "Mars/Olympus" }, "clinic_timezone"},
+		{"store driver", func(c *Config) { c.StoreDriver = "postgres" }, "store_driver"},
+		{"request timeout", func(c *Config) { c.RequestTimeout = 0 }, "request_timeout"},
+		{"short secret", func(c *Config) { c.Auth.JWTSecret = "secret" }, "jwt_secret"},
+		{"admin without password", func(c *Config) { c.Auth.AdminEmail = "admin@clinic.com" }, "admin_password"},
+		{"purge without interval", func(c *Config) { c.Purge.Retention, c.Purge.Interval = time.Hour, 0 }, "purge interval"},
+		{"database name", func(c *Config) { c.Database.Name = "" }, "database host, name and user"},
+		{"database port", func(c *Config) { c.Database.Port = 70000 }, "database port"},
+		{"idle over open connections", func(c *Config) { c.Database.MaxIdleConns = 30 }, "max_idle_conns"},
+		{"negative timeout", func(c *Config) { c.Database.ReadTimeout = -time.Second }, "can't be negative"},
+		{"params changing the location", func(c *Config) { c.Database.Params = "loc=Local" }, "loc can't be changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.change(&cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error about %s, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// the database isn't needed by the memory store
+	cfg := validConfig()
+	cfg.StoreDriver, cfg.Database.Name = "memory", ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("memory store without database: %v", err)
+	}
+	// every invalid setting is reported
+	cfg.RequestTimeout, cfg.ShutdownTimeout = 0, 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "request_timeout") || !strings.Contains(err.Error(), "shutdown_timeout") {
+		t.Errorf("expected both timeouts reported, got %v", err)
+	}
+}
+
+func TestDSN(t *testing.T) {
+	cfg := Default().Database
+	cfg.Password = "p@ss"
+	cfg.Params = "charset=utf8mb4"
+
+	dsn := cfg.DSN()
+	for _, want := range []string{"root:p@ss@tcp(127.0.0.1:3306)/clinica_odonto?", "parseTime=true", "timeout=5s", "&charset=utf8mb4"} {
+		if !strings.Contains(dsn, want) {
+			t.Errorf("dsn %s doesn't hold %s", dsn, want)
+		}
+	}
+	if strings.Contains(dsn, "loc=") {
+		t.Errorf("dsn %s sets the location, UTC is the default of the driver", dsn)
+	}
+}
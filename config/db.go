@@ -3,32 +3,67 @@ package config
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/go-sql-driver/mysql"
 )
 
-var URLDbConnection = ""
+// DSN - the data source name of the MySQL driver, the dates are stored in UTC and
+// scanned as time.Time. UTC is the default loc of the driver, so it isn't written.
+func (d DatabaseConfig) DSN() string {
+	cfg := mysql.NewConfig()
+	cfg.User = d.User
+	cfg.Passwd = d.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+	cfg.DBName = d.Name
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.Timeout = d.DialTimeout
+	cfg.ReadTimeout = d.ReadTimeout
+	cfg.WriteTimeout = d.WriteTimeout
 
-// LoadConfig - load info about db connection from env variables
-func LoadConfig() {
-	var err error
-	if err = godotenv.Load(); err != nil {
-		log.Fatalln(err)
+	dsn := cfg.FormatDSN()
+	if d.Params == "" {
+		return dsn
 	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + d.Params
+	}
+	return dsn + "?" + d.Params
+}
 
-	URLDbConnection = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-		os.Getenv("MYSQL_USER"), os.Getenv("MYSQL_PASSWORD"), os.Getenv("DATABASE_URL"),
-		os.Getenv("DATABASE_PORT"), os.Getenv("DATABASE_NAME"))
+// checkParams - Params must be a query string the driver accepts, without the
+// parameters the stores rely on
+func (d DatabaseConfig) checkParams() error {
+	params, err := url.ParseQuery(d.Params)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"parseTime", "loc"} {
+		if params.Has(name) {
+			return fmt.Errorf("%s can't be changed", name)
+		}
+	}
+	_, err = mysql.ParseDSN(d.DSN())
+	return err
 }
 
-// ConnectDatabase - perform a sql.Open() to connect to database
-func ConnectDatabase() (*sql.DB, error) {
-	db, err := sql.Open("mysql", URLDbConnection)
+// ConnectDatabase - opens the pool of connections shared by the stores, with the
+// limits of cfg, and checks the database is reachable
+func ConnectDatabase(cfg DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	if err = db.Ping(); err != nil {
 		db.Close()
 		return nil, err
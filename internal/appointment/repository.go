@@ -1,156 +1,127 @@
 package appointment
 
 import (
-	"errors"
+	"context"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/internal/schedule"
 	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
-	"log"
 	"time"
 )
 
-var table = store.AP
+// errTooSoon - appointments must be booked at least an hour in advance
+var errTooSoon = domain.NewValidationError("date_and_time", "future", "must be at least one hour from now")
 
 type Repository interface {
-	GetAll() (interface{}, error)
-	GetByID(entityId int) (interface{}, error)
-	GetAllByIdentityNumber(identityNumber string) (interface{}, error)
-	GetAllByLicenseNumber(licenseNumber string) (interface{}, error)
-	Create(a domain.Appointment) (interface{}, error)
-	Update(entityId int, a domain.Appointment) (interface{}, error)
-	Delete(entityId int) error
+	GetAll(ctx context.Context) ([]domain.AppointmentDTO, error)
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AppointmentDTO], error)
+	GetByID(ctx context.Context, entityId int) (domain.AppointmentDTO, error)
+	GetAllByIdentityNumber(ctx context.Context, identityNumber string) ([]domain.AppointmentDTO, error)
+	GetAllByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error)
+	Create(ctx context.Context, a domain.Appointment) (domain.AppointmentDTO, error)
+	Update(ctx context.Context, entityId int, a domain.Appointment) (domain.AppointmentDTO, error)
+	Delete(ctx context.Context, entityId int) error
+	Restore(ctx context.Context, entityId int) (domain.AppointmentDTO, error)
+	ChangeStatus(ctx context.Context, change domain.StatusChange) (domain.AppointmentDTO, error)
+	GetStatusHistory(ctx context.Context, entityId int) ([]domain.StatusChange, error)
+	CreateSeries(ctx context.Context, series domain.AppointmentSeries) (domain.AppointmentSeries, error)
+	GetAllBySeries(ctx context.Context, seriesId int) ([]domain.AppointmentDTO, error)
+	// Schedules - the schedules of the dentists, read in the transaction of WithTx
+	// by its repository
+	Schedules() schedule.Repository
+	// WithTx - runs fn with a repository whose reads and writes happen in one
+	// transaction, committed when fn returns nil
+	WithTx(ctx context.Context, fn func(r Repository) error) error
 }
 
 type repository struct {
-	store store.ApStore
+	store     store.ApStore
+	schedules schedule.Repository
+	stores    store.Store
 }
 
-func NewRepository(store store.ApStore) Repository {
-	return &repository{store}
+// NewRepository - stores gives the schedules and dentists and runs the units of
+// work of WithTx
+func NewRepository(appointments store.ApStore, stores store.Store) Repository {
+	return &repository{appointments, schedule.NewRepository(stores.Schedules(), stores.Dentists(), appointments), stores}
 }
 
-func (r *repository) GetAll() (interface{}, error) {
-	return r.store.GetAll(table)
+func (r *repository) GetAll(ctx context.Context) ([]domain.AppointmentDTO, error) {
+	return r.store.GetAll(ctx)
 }
 
-func (r *repository) GetByID(entityId int) (interface{}, error) {
-	return r.store.GetByID(entityId, table)
+// List - returns a page of appointments filtered and sorted by opts
+func (r *repository) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AppointmentDTO], error) {
+	return r.store.List(ctx, opts)
 }
 
-func (r *repository) GetAllByIdentityNumber(identityNumber string) (interface{}, error) {
-	return r.store.GetAllAppointmentsByPatientIdentify(identityNumber)
+func (r *repository) GetByID(ctx context.Context, entityId int) (domain.AppointmentDTO, error) {
+	return r.store.GetByID(ctx, entityId)
 }
 
-func (r *repository) GetAllByLicenseNumber(licenseNumber string) (interface{}, error) {
-	return r.store.GetAllAppointmentsByDentistsLicense(licenseNumber)
+func (r *repository) GetAllByIdentityNumber(ctx context.Context, identityNumber string) ([]domain.AppointmentDTO, error) {
+	return r.store.GetAllAppointmentsByPatientIdentify(ctx, identityNumber)
 }
 
-func (r *repository) Create(a domain.Appointment) (interface{}, error) {
-	if !r.isValidDate(a) {
-		return nil, errors.New("some data is invalid")
-	}
-	if !r.isADateTimeAvailable(a.DateAndTime, a.DentistLicense, a.PatientIdentity) {
-		return nil, errors.New("the date and time select aren't available for dentist or patient")
+func (r *repository) GetAllByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error) {
+	return r.store.GetAllAppointmentsByDentistsLicense(ctx, licenseNumber)
+}
+
+func (r *repository) Create(ctx context.Context, a domain.Appointment) (domain.AppointmentDTO, error) {
+	if err := r.validateDate(a); err != nil {
+		return domain.AppointmentDTO{}, err
 	}
-	return r.store.Save(a, table)
+	return r.store.Save(ctx, domain.AppointmentDTO{Appointment: a})
 }
 
-func (r *repository) Update(entityId int, a domain.Appointment) (interface{}, error) {
-	aInterface, err := r.GetAll()
-	if err != nil {
-		return nil, err
+func (r *repository) Update(ctx context.Context, entityId int, a domain.Appointment) (domain.AppointmentDTO, error) {
+	if _, err := r.store.GetByID(ctx, entityId); err != nil {
+		return domain.AppointmentDTO{}, err
 	}
-	appointments, ok := aInterface.([]domain.AppointmentDTO)
-	if !ok {
-		return nil, err
+	if err := r.validateDate(a); err != nil {
+		return domain.AppointmentDTO{}, err
 	}
+	return r.store.Update(ctx, entityId, domain.AppointmentDTO{Appointment: a})
+}
 
-	for _, appointment := range appointments {
-		if appointment.Id == entityId {
-			if !r.isValidDate(a) {
-				return nil, errors.New("some data is invalid")
-			}
-			if !r.isADateTimeAvailable(a.DateAndTime, a.DentistLicense, a.PatientIdentity) {
-				return nil, errors.New("the date and time select aren't available for dentist or patient")
-			}
-			return r.store.Update(entityId, a, table)
-		}
-	}
-	return nil, errors.New("appointment not found")
+func (r *repository) Delete(ctx context.Context, entityId int) error {
+	return r.store.Delete(ctx, entityId)
 }
 
-func (r *repository) Delete(entityId int) error {
-	return r.store.Delete(entityId, table)
+func (r *repository) Restore(ctx context.Context, entityId int) (domain.AppointmentDTO, error) {
+	return r.store.Restore(ctx, entityId)
 }
 
-func (r *repository) isValidDate(a domain.Appointment) bool {
-	var appointments []domain.AppointmentDTO
-	aDateTimeToValidate, err := time.Parse("10/10/2023 20:00", a.DateAndTime)
-	if err != nil {
-		log.Fatalln("error while trying to validate date and time provided from request body ->", err.Error())
-		return false
-	}
+func (r *repository) ChangeStatus(ctx context.Context, change domain.StatusChange) (domain.AppointmentDTO, error) {
+	return r.store.ChangeStatus(ctx, change)
+}
 
-	appointmentsInterface, err := r.GetAll()
-	if err != nil {
-		log.Fatalln("error: ", err.Error())
-		return false
-	}
-	appointments, ok := appointmentsInterface.([]domain.AppointmentDTO)
-	if !ok {
-		log.Fatalln("error parsing interface data fetched from db")
-		return false
-	}
+func (r *repository) GetStatusHistory(ctx context.Context, entityId int) ([]domain.StatusChange, error) {
+	return r.store.GetStatusHistory(ctx, entityId)
+}
 
-	for _, appointment := range appointments {
-		if err != nil {
-			log.Println("error while trying to parse date and time when validating date_and_time provided")
-			return false
-		}
-		if a.Id == appointment.Id {
-			return aDateTimeToValidate.After(time.Now().Add(time.Hour))
-		}
-	}
-	return aDateTimeToValidate.After(time.Now().Add(time.Hour))
+func (r *repository) CreateSeries(ctx context.Context, series domain.AppointmentSeries) (domain.AppointmentSeries, error) {
+	return r.store.SaveSeries(ctx, series)
 }
 
-func (r *repository) isADateTimeAvailable(dateTime string, d string, p string) bool {
-	dateTimeToVerify, err := time.Parse("10/10/2023 20:00", dateTime)
-	if err != nil {
-		log.Println("error while trying to parse datetime provided at request body")
-		return false
-	}
-	dateTimeEnd := dateTimeToVerify.Add(time.Hour * 1)
-	appointmentsByDateTime, err := r.store.GetAllAppointmentsByDateTimeInterval(dateTimeToVerify.Local().Add(time.Hour*3).String(), dateTimeEnd.Local().Add(time.Hour*3).String())
-	if err != nil {
-		log.Println("an error occurred while trying to get appointments with same date to validation")
-		return false
-	}
+func (r *repository) GetAllBySeries(ctx context.Context, seriesId int) ([]domain.AppointmentDTO, error) {
+	return r.store.GetAllAppointmentsBySeries(ctx, seriesId)
+}
 
-	if len(appointmentsByDateTime) == 0 {
-		return true
-	}
-	for _, appointment := range appointmentsByDateTime {
-		appointmentDateTime, err := time.Parse("2006-01-02 15:04:05", appointment.DateAndTime)
-		if err != nil {
-			return false
-		}
-
-		if appointmentDateTime.Local().Add(time.Hour*3).String() == dateTimeToVerify.Local().Add(time.Hour*3).String() {
-			if d != appointment.DentistLicense && p != appointment.PatientIdentity {
-				return true
-			}
-			if d != appointment.DentistLicense {
-				if p == appointment.PatientIdentity {
-					return true
-				}
-			}
-			if d == appointment.DentistLicense {
-				if p != appointment.PatientIdentity {
-					return true
-				}
-			}
-		}
-		return true
+func (r *repository) Schedules() schedule.Repository {
+	return r.schedules
+}
+
+func (r *repository) WithTx(ctx context.Context, fn func(r Repository) error) error {
+	return r.stores.WithTx(ctx, func(tx store.Tx) error {
+		appointments := tx.Appointments()
+		return fn(&repository{appointments, schedule.NewRepository(tx.Schedules(), tx.Dentists(), appointments), r.stores})
+	})
+}
+
+// validateDate - the appointment must be at least an hour from now
+func (r *repository) validateDate(a domain.Appointment) error {
+	if !a.DateAndTime.After(time.Now().Add(time.Hour)) {
+		return errTooSoon
 	}
-	return false
+	return nil
 }
@@ -0,0 +1,249 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// CreateSeries - books an occurrence of a for every date of the recurrence. The
+// occurrences out of the dentist availability or overlapping other appointments are
+// left out and reported as conflicts, the series isn't created if none is available.
+// The availability is checked, and the series and its occurrences written, in one
+// transaction.
+func (s *service) CreateSeries(ctx context.Context, a domain.Appointment, recurrence domain.Recurrence) (domain.SeriesReport, error) {
+	a = newAppointment(a)
+	if err := validateDuration(a); err != nil {
+		return domain.SeriesReport{}, err
+	}
+	dates, err := recurrence.Occurrences(a.DateAndTime)
+	if err != nil {
+		return domain.SeriesReport{}, err
+	}
+	if recurrence.Interval == 0 {
+		recurrence.Interval = 1
+	}
+
+	report := domain.SeriesReport{Appointments: []domain.AppointmentDTO{}, Conflicts: []domain.SeriesConflict{}}
+	// the rejections of the transaction are counted once it's committed
+	var rejected []error
+	err = s.r.WithTx(ctx, func(r Repository) error {
+		var available []domain.Appointment
+		for _, date := range dates {
+			occurrence := a
+			occurrence.DateAndTime = date
+			if err := s.checkAvailability(ctx, r, occurrence); err != nil {
+				if !isOccurrenceConflict(err) {
+					return err
+				}
+				rejected = append(rejected, err)
+				report.Conflicts = append(report.Conflicts, domain.SeriesConflict{DateAndTime: occurrence.DateAndTime, Reason: err.Error()})
+				continue
+			}
+			available = append(available, occurrence)
+		}
+		if len(available) == 0 {
+			return nil
+		}
+
+		series, err := r.CreateSeries(ctx, domain.AppointmentSeries{Recurrence: recurrence})
+		if err != nil {
+			return err
+		}
+		report.SeriesId = series.Id
+		for _, occurrence := range available {
+			occurrence.SeriesId = series.Id
+			created, err := r.Create(ctx, occurrence)
+			if err != nil {
+				if !isOccurrenceConflict(err) {
+					return err
+				}
+				rejected = append(rejected, err)
+				report.Conflicts = append(report.Conflicts, domain.SeriesConflict{DateAndTime: occurrence.DateAndTime, Reason: err.Error()})
+				continue
+			}
+			report.Appointments = append(report.Appointments, created)
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SeriesReport{}, err
+	}
+	for _, err := range rejected {
+		s.countRejection(err)
+	}
+	s.metrics.AppointmentsCreated(len(report.Appointments))
+	return report, nil
+}
+
+// UpdateSeries - applies the changes of a to the occurrences reached by scope. A new
+// date_and_time moves every occurrence by the same amount as the one of id. The
+// version of a is the one of id, every other occurrence is updated at the version
+// it was read. The occurrences are read and updated in one transaction, which is
+// rolled back by any error but the conflicts of single occurrences.
+func (s *service) UpdateSeries(ctx context.Context, id int, a domain.Appointment, scope domain.SeriesScope) (domain.SeriesReport, error) {
+	report := domain.SeriesReport{Appointments: []domain.AppointmentDTO{}, Conflicts: []domain.SeriesConflict{}}
+	// the rejections of the transaction are counted once it's committed
+	var rejected []error
+	err := s.r.WithTx(ctx, func(r Repository) error {
+		target, occurrences, err := seriesOccurrences(ctx, r, id, scope)
+		if err != nil {
+			return err
+		}
+		if a.Version != 0 && a.Version != target.Version {
+			return fmt.Errorf("%w: appointment %d is at version %d", domain.ErrVersionMismatch, id, target.Version)
+		}
+		var shift time.Duration
+		if !a.DateAndTime.IsZero() {
+			shift = a.DateAndTime.Sub(target.DateAndTime)
+		}
+		report.SeriesId = target.SeriesId
+
+		for _, occurrence := range occurrences {
+			conflict := domain.SeriesConflict{AppointmentId: occurrence.Id, DateAndTime: occurrence.DateAndTime}
+			if occurrence.Status.IsFinal() {
+				conflict.Reason = fmt.Sprintf("a %s appointment can't be changed", occurrence.Status)
+				report.Conflicts = append(report.Conflicts, conflict)
+				continue
+			}
+			update := a
+			if !a.DateAndTime.IsZero() {
+				update.DateAndTime = occurrence.DateAndTime.Add(shift)
+			}
+			update.Version = 0
+			update = mergeUpdate(occurrence.Appointment, update)
+			if err := validateDuration(update); err != nil {
+				return err
+			}
+			if rescheduled(occurrence.Appointment, update) {
+				if err := s.checkAvailability(ctx, r, update); err != nil {
+					if !isOccurrenceConflict(err) {
+						return err
+					}
+					rejected = append(rejected, err)
+					conflict.Reason = err.Error()
+					report.Conflicts = append(report.Conflicts, conflict)
+					continue
+				}
+			}
+			updated, err := r.Update(ctx, occurrence.Id, update)
+			if err != nil {
+				if !isOccurrenceConflict(err) {
+					return err
+				}
+				rejected = append(rejected, err)
+				conflict.Reason = err.Error()
+				report.Conflicts = append(report.Conflicts, conflict)
+				continue
+			}
+			report.Appointments = append(report.Appointments, updated)
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SeriesReport{}, err
+	}
+	for _, err := range rejected {
+		s.countRejection(err)
+	}
+	return report, nil
+}
+
+// ChangeSeriesStatus - moves the occurrences reached by scope to status, the ones
+// the lifecycle doesn't allow are reported as conflicts. The occurrences are read
+// and changed in one transaction, which is rolled back by any other error.
+func (s *service) ChangeSeriesStatus(ctx context.Context, id int, status domain.AppointmentStatus, reason string, scope domain.SeriesScope) (domain.SeriesReport, error) {
+	if status == domain.StatusCancelled && reason == "" {
+		return domain.SeriesReport{}, domain.NewValidationError("reason", "required", "a reason is required to cancel an appointment")
+	}
+
+	report := domain.SeriesReport{Appointments: []domain.AppointmentDTO{}, Conflicts: []domain.SeriesConflict{}}
+	err := s.r.WithTx(ctx, func(r Repository) error {
+		target, occurrences, err := seriesOccurrences(ctx, r, id, scope)
+		if err != nil {
+			return err
+		}
+		report.SeriesId = target.SeriesId
+
+		for _, occurrence := range occurrences {
+			updated, err := changeStatus(ctx, r, occurrence.Id, status, reason)
+			if err != nil {
+				if !isOccurrenceConflict(err) {
+					return err
+				}
+				report.Conflicts = append(report.Conflicts, domain.SeriesConflict{
+					AppointmentId: occurrence.Id,
+					DateAndTime:   occurrence.DateAndTime,
+					Reason:        err.Error(),
+				})
+				continue
+			}
+			report.Appointments = append(report.Appointments, updated)
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SeriesReport{}, err
+	}
+	if status == domain.StatusCancelled {
+		s.metrics.AppointmentsCancelled(len(report.Appointments))
+	}
+	return report, nil
+}
+
+// seriesOccurrences - returns the appointment id and the occurrences of its series
+// reached by scope, ordered by date and time, as read by r
+func seriesOccurrences(ctx context.Context, r Repository, id int, scope domain.SeriesScope) (domain.AppointmentDTO, []domain.AppointmentDTO, error) {
+	target, err := r.GetByID(ctx, id)
+	if err != nil {
+		return domain.AppointmentDTO{}, nil, err
+	}
+	if target.SeriesId == 0 {
+		return domain.AppointmentDTO{}, nil, domain.ErrNotInSeries
+	}
+	all, err := r.GetAllBySeries(ctx, target.SeriesId)
+	if err != nil {
+		return domain.AppointmentDTO{}, nil, err
+	}
+
+	switch scope {
+	case domain.ScopeThis:
+		return target, []domain.AppointmentDTO{target}, nil
+	case domain.ScopeAll:
+		return target, all, nil
+	case domain.ScopeFollowing:
+		var following []domain.AppointmentDTO
+		for _, occurrence := range all {
+			if !occurrence.DateAndTime.Before(target.DateAndTime) {
+				following = append(following, occurrence)
+			}
+		}
+		return target, following, nil
+	}
+	return domain.AppointmentDTO{}, nil, domain.NewValidationError("scope", "oneof", "must be one of: this, following, all")
+}
+
+// isOccurrenceConflict - tells if err rejects a single occurrence of a series: the
+// slot taken or out of the schedule, the dentist inactive, the occurrence changed
+// meanwhile or its status. The other errors fail the whole series.
+func isOccurrenceConflict(err error) bool {
+	return errors.Is(err, domain.ErrSlotUnavailable) ||
+		errors.Is(err, domain.ErrDentistInactive) ||
+		errors.Is(err, domain.ErrVersionMismatch) ||
+		errors.Is(err, domain.ErrConcurrentUpdate) ||
+		errors.Is(err, domain.ErrInvalidTransition)
+}
+
+// checkAvailability - checks a against the working hours, breaks and time off of
+// its dentist, which must exist, as read by r
+func (s *service) checkAvailability(ctx context.Context, r Repository, a domain.Appointment) error {
+	start, end := a.Interval()
+	err := s.schedules.WithRepository(r.Schedules()).CheckAvailability(ctx, a.DentistLicense, start, end)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.NewValidationError("dentist_license", "exists", "doesn't reference a dentist")
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// createSeries - books three weekly occurrences of P1 with L1 from thursday at 08:00
+func createSeries(t *testing.T, s Service) domain.SeriesReport {
+	t.Helper()
+	report, err := s.CreateSeries(context.Background(), appointmentAt(thursday(8, 0), "L1", "P1"), domain.Recurrence{Frequency: domain.FrequencyWeekly, Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Appointments) != 3 || len(report.Conflicts) != 0 {
+		t.Fatalf("booked %d occurrences with conflicts %v, want 3", len(report.Appointments), report.Conflicts)
+	}
+	return report
+}
+
+func descriptions(t *testing.T, s Service, seriesID int) []string {
+	t.Helper()
+	var got []string
+	all, err := s.GetAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range all {
+		if a.SeriesId == seriesID {
+			got = append(got, a.Description)
+		}
+	}
+	return got
+}
+
+// TestCreateSeriesConflicts - the occurrences which can't be booked are reported,
+// the other ones are booked in the same series
+func TestCreateSeriesConflicts(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 0).AddDate(0, 0, 7), "L1", "P2")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.CreateSeries(ctx, appointmentAt(thursday(8, 0), "L1", "P1"), domain.Recurrence{Frequency: domain.FrequencyWeekly, Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Appointments) != 2 || len(report.Conflicts) != 1 {
+		t.Fatalf("booked %d occurrences with conflicts %v, want 2 and 1 conflict", len(report.Appointments), report.Conflicts)
+	}
+	if !report.Conflicts[0].DateAndTime.Equal(thursday(8, 0).AddDate(0, 0, 7)) {
+		t.Errorf("conflict at %v, want the second week", report.Conflicts[0].DateAndTime)
+	}
+	for _, a := range report.Appointments {
+		if a.SeriesId != report.SeriesId || report.SeriesId == 0 {
+			t.Errorf("appointment %d in series %d, want %d", a.Id, a.SeriesId, report.SeriesId)
+		}
+	}
+
+	if _, err := s.CreateSeries(ctx, appointmentAt(thursday(8, 0), "L1", "P9"), domain.Recurrence{Frequency: domain.FrequencyWeekly, Count: 3}); violatedField(err) != "patient_identity" {
+		t.Errorf("unknown patient: expected a violation of patient_identity, got %v", err)
+	}
+}
+
+func TestUpdateSeriesScope(t *testing.T) {
+	tests := []struct {
+		scope domain.SeriesScope
+		want  []string
+	}{
+		{domain.ScopeThis, []string{"checkup", "changed", "checkup"}},
+		{domain.ScopeFollowing, []string{"checkup", "changed", "changed"}},
+		{domain.ScopeAll, []string{"changed", "changed", "changed"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			s, _ := newTestService(t)
+			report := createSeries(t, s)
+			second := report.Appointments[1]
+
+			updated, err := s.UpdateSeries(context.Background(), second.Id, domain.Appointment{Description: "changed", Version: second.Version}, tt.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(updated.Conflicts) != 0 {
+				t.Errorf("conflicts %v, want none", updated.Conflicts)
+			}
+			if got := descriptions(t, s, report.SeriesId); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("descriptions %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUpdateSeriesShift - a new date moves every occurrence by the same amount,
+// the ones which don't fit are reported
+func TestUpdateSeriesShift(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	report := createSeries(t, s)
+	if _, err := s.Create(ctx, appointmentAt(thursday(10, 0).AddDate(0, 0, 14), "L1", "P2")); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := s.UpdateSeries(ctx, report.Appointments[0].Id, domain.Appointment{DateAndTime: thursday(10, 0)}, domain.ScopeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Appointments) != 2 || len(updated.Conflicts) != 1 {
+		t.Fatalf("moved %d occurrences with conflicts %v, want 2 and 1 conflict", len(updated.Appointments), updated.Conflicts)
+	}
+	for i, a := range updated.Appointments {
+		if want := thursday(10, 0).AddDate(0, 0, 7*i); !a.DateAndTime.Equal(want) {
+			t.Errorf("occurrence %d at %v, want %v", a.Id, a.DateAndTime, want)
+		}
+	}
+	if updated.Conflicts[0].AppointmentId != report.Appointments[2].Id {
+		t.Errorf("conflict of appointment %d, want %d", updated.Conflicts[0].AppointmentId, report.Appointments[2].Id)
+	}
+}
+
+// TestUpdateSeriesFailure - the errors which aren't conflicts of an occurrence
+// leave the series unchanged
+func TestUpdateSeriesFailure(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	report := createSeries(t, s)
+	first := report.Appointments[0]
+
+	if _, err := s.UpdateSeries(ctx, first.Id, domain.Appointment{Description: "changed", Version: first.Version + 1}, domain.ScopeAll); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("stale version: expected ErrVersionMismatch, got %v", err)
+	}
+	if _, err := s.UpdateSeries(ctx, first.Id, domain.Appointment{Description: "changed", PatientIdentity: "P9"}, domain.ScopeAll); violatedField(err) != "patient_identity" {
+		t.Errorf("unknown patient: expected a violation of patient_identity, got %v", err)
+	}
+	for _, description := range descriptions(t, s, report.SeriesId) {
+		if description != "checkup" {
+			t.Fatalf("the series was changed: %v", descriptions(t, s, report.SeriesId))
+		}
+	}
+
+	single, err := s.Create(ctx, appointmentAt(thursday(12, 0), "L1", "P1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateSeries(ctx, single.Id, domain.Appointment{Description: "changed"}, domain.ScopeAll); !errors.Is(err, domain.ErrNotInSeries) {
+		t.Errorf("appointment out of a series: expected ErrNotInSeries, got %v", err)
+	}
+}
+
+// TestChangeSeriesStatus - the occurrences the lifecycle doesn't allow to change
+// are reported
+func TestChangeSeriesStatus(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	report := createSeries(t, s)
+	if _, err := s.ChangeStatus(ctx, report.Appointments[2].Id, domain.StatusNoShow, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, err := s.ChangeSeriesStatus(ctx, report.Appointments[0].Id, domain.StatusCancelled, "moving away", domain.ScopeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled.Appointments) != 2 || len(cancelled.Conflicts) != 1 {
+		t.Fatalf("cancelled %d occurrences with conflicts %v, want 2 and 1 conflict", len(cancelled.Appointments), cancelled.Conflicts)
+	}
+	for _, a := range cancelled.Appointments {
+		if a.Status != domain.StatusCancelled {
+			t.Errorf("appointment %d is %s, want cancelled", a.Id, a.Status)
+		}
+	}
+	if _, err := s.ChangeSeriesStatus(ctx, report.Appointments[0].Id, domain.StatusConfirmed, "", "some"); violatedField(err) != "scope" {
+		t.Errorf("unknown scope: expected a violation of scope, got %v", err)
+	}
+}
+
+// failingStatus - a repository whose status changes of the appointment id fail,
+// in its units of work too
+type failingStatus struct {
+	Repository
+	id int
+}
+
+func (r failingStatus) ChangeStatus(ctx context.Context, change domain.StatusChange) (domain.AppointmentDTO, error) {
+	if change.AppointmentId == r.id {
+		return domain.AppointmentDTO{}, errors.New("connection lost")
+	}
+	return r.Repository.ChangeStatus(ctx, change)
+}
+
+func (r failingStatus) WithTx(ctx context.Context, fn func(r Repository) error) error {
+	return r.Repository.WithTx(ctx, func(tx Repository) error {
+		return fn(failingStatus{tx, r.id})
+	})
+}
+
+// TestChangeSeriesStatusFailure - an error other than a conflict leaves every
+// occurrence as it was
+func TestChangeSeriesStatusFailure(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	report := createSeries(t, s)
+	s.(*service).r = failingStatus{s.(*service).r, report.Appointments[2].Id}
+
+	if _, err := s.ChangeSeriesStatus(ctx, report.Appointments[0].Id, domain.StatusCancelled, "moving away", domain.ScopeAll); err == nil || isOccurrenceConflict(err) {
+		t.Fatalf("expected the error of the store, got %v", err)
+	}
+	for _, a := range report.Appointments {
+		current, err := s.GetByID(ctx, a.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Status != domain.StatusScheduled {
+			t.Errorf("appointment %d is %s, want it still scheduled", a.Id, current.Status)
+		}
+	}
+}
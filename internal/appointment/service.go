@@ -1,121 +1,256 @@
 package appointment
 
 import (
+	"context"
 	"errors"
+	"fmt"
+
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/internal/schedule"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/metrics"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
 )
 
 type Service interface {
-	GetAll() ([]domain.AppointmentDTO, error)
-	GetByID(id int) (domain.AppointmentDTO, error)
-	GetAllByIdentityNumber(identityNumber string) ([]domain.AppointmentDTO, error)
-	GetAllByLicenseNumber(licenseNumber string) ([]domain.AppointmentDTO, error)
-	Create(a domain.Appointment) (domain.AppointmentDTO, error)
-	Update(id int, a domain.Appointment) (domain.AppointmentDTO, error)
-	Delete(id int) error
+	GetAll(ctx context.Context) ([]domain.AppointmentDTO, error)
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AppointmentDTO], error)
+	GetByID(ctx context.Context, id int) (domain.AppointmentDTO, error)
+	GetAllByIdentityNumber(ctx context.Context, identityNumber string) ([]domain.AppointmentDTO, error)
+	GetAllByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error)
+	Create(ctx context.Context, a domain.Appointment) (domain.AppointmentDTO, error)
+	Update(ctx context.Context, id int, a domain.Appointment) (domain.AppointmentDTO, error)
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (domain.AppointmentDTO, error)
+	ChangeStatus(ctx context.Context, id int, status domain.AppointmentStatus, reason string) (domain.AppointmentDTO, error)
+	GetStatusHistory(ctx context.Context, id int) ([]domain.StatusChange, error)
+	CreateSeries(ctx context.Context, a domain.Appointment, recurrence domain.Recurrence) (domain.SeriesReport, error)
+	UpdateSeries(ctx context.Context, id int, a domain.Appointment, scope domain.SeriesScope) (domain.SeriesReport, error)
+	ChangeSeriesStatus(ctx context.Context, id int, status domain.AppointmentStatus, reason string, scope domain.SeriesScope) (domain.SeriesReport, error)
 }
 
 type service struct {
-	r Repository
+	r         Repository
+	schedules schedule.Service
+	metrics   *metrics.Metrics
 }
 
-func NewService(r Repository) Service {
-	return &service{r}
+// NewService - schedules is used to check the dentist availability of the
+// occurrences of a series, m counts the appointments booked, cancelled and
+// rejected
+func NewService(r Repository, schedules schedule.Service, m *metrics.Metrics) Service {
+	return &service{r, schedules, m}
 }
 
-func (s *service) GetAll() ([]domain.AppointmentDTO, error) {
-	list, err := s.r.GetAll()
-	if err != nil {
-		return nil, err
-	}
-	appointments, ok := list.([]domain.AppointmentDTO)
-	if !ok {
-		return nil, errors.New("an error occurred while trying to fetch data from database")
-	}
-	return appointments, nil
+func (s *service) GetAll(ctx context.Context) ([]domain.AppointmentDTO, error) {
+	return s.r.GetAll(ctx)
 }
 
-func (s *service) GetByID(id int) (domain.AppointmentDTO, error) {
-	aInterface, err := s.r.GetByID(id)
-	if err != nil {
+func (s *service) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AppointmentDTO], error) {
+	return s.r.List(ctx, opts)
+}
+
+func (s *service) GetByID(ctx context.Context, id int) (domain.AppointmentDTO, error) {
+	return s.r.GetByID(ctx, id)
+}
+
+func (s *service) GetAllByIdentityNumber(ctx context.Context, identityNumber string) ([]domain.AppointmentDTO, error) {
+	return s.r.GetAllByIdentityNumber(ctx, identityNumber)
+}
+
+func (s *service) GetAllByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error) {
+	return s.r.GetAllByLicenseNumber(ctx, licenseNumber)
+}
+
+// Create - new appointments always start as scheduled, the procedure defaults to a
+// consultation and the duration to the one of the procedure. Appointments out of
+// the dentist availability are rejected, and the store rejects the ones
+// overlapping another one of the same dentist or patient. The availability is
+// checked in the transaction of the booking.
+func (s *service) Create(ctx context.Context, a domain.Appointment) (domain.AppointmentDTO, error) {
+	a = newAppointment(a)
+	if err := validateDuration(a); err != nil {
 		return domain.AppointmentDTO{}, err
 	}
-	appointment, ok := aInterface.(domain.AppointmentDTO)
-	if !ok {
-		return appointment, errors.New("not found an appointment with id provided")
+	var created domain.AppointmentDTO
+	err := s.r.WithTx(ctx, func(r Repository) error {
+		if err := s.checkAvailability(ctx, r, a); err != nil {
+			return err
+		}
+		var err error
+		created, err = r.Create(ctx, a)
+		return err
+	})
+	if err != nil {
+		s.countRejection(err)
+		return domain.AppointmentDTO{}, err
 	}
-	return appointment, nil
+	s.metrics.AppointmentsCreated(1)
+	return created, nil
 }
 
-func (s *service) GetAllByIdentityNumber(identityNumber string) ([]domain.AppointmentDTO, error) {
-	list, err := s.r.GetAllByIdentityNumber(identityNumber)
+// Update - the appointment is read and updated in the same transaction, at the
+// version of a or at the one read when a has none. An appointment moved, resized or
+// given to another dentist must be inside the dentist availability.
+func (s *service) Update(ctx context.Context, id int, a domain.Appointment) (domain.AppointmentDTO, error) {
+	var updated domain.AppointmentDTO
+	err := s.r.WithTx(ctx, func(r Repository) error {
+		aUpdate, err := r.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if aUpdate.Status.IsFinal() {
+			return &domain.ConflictError{Code: "appointment_final", Message: fmt.Sprintf("a %s appointment can't be changed", aUpdate.Status)}
+		}
+
+		a = mergeUpdate(aUpdate.Appointment, a)
+		if err := validateDuration(a); err != nil {
+			return err
+		}
+		if rescheduled(aUpdate.Appointment, a) {
+			if err := s.checkAvailability(ctx, r, a); err != nil {
+				return err
+			}
+		}
+		updated, err = r.Update(ctx, id, a)
+		return err
+	})
 	if err != nil {
-		return nil, err
-	}
-	appointments, ok := list.([]domain.AppointmentDTO)
-	if !ok {
-		return nil, errors.New("an error occurred while trying to fetch data from database")
+		s.countRejection(err)
 	}
-	return appointments, nil
+	return updated, err
 }
 
-func (s *service) GetAllByLicenseNumber(licenseNumber string) ([]domain.AppointmentDTO, error) {
-	list, err := s.r.GetAllByLicenseNumber(licenseNumber)
-	if err != nil {
-		return nil, err
+func (s *service) Delete(ctx context.Context, id int) error {
+	return s.r.Delete(ctx, id)
+}
+
+// Restore - undoes the deletion of the appointment, which must not overlap the
+// ones booked meanwhile
+func (s *service) Restore(ctx context.Context, id int) (domain.AppointmentDTO, error) {
+	return s.r.Restore(ctx, id)
+}
+
+// ChangeStatus - moves the appointment to status if the lifecycle allows it,
+// cancelling requires a reason. The status is read and changed in the same
+// transaction.
+func (s *service) ChangeStatus(ctx context.Context, id int, status domain.AppointmentStatus, reason string) (domain.AppointmentDTO, error) {
+	if status == domain.StatusCancelled && reason == "" {
+		return domain.AppointmentDTO{}, domain.NewValidationError("reason", "required", "a reason is required to cancel an appointment")
 	}
-	appointments, ok := list.([]domain.AppointmentDTO)
-	if !ok {
-		return nil, errors.New("an error occurred while trying to fetch data from database")
+	var changed domain.AppointmentDTO
+	err := s.r.WithTx(ctx, func(r Repository) error {
+		var err error
+		changed, err = changeStatus(ctx, r, id, status, reason)
+		return err
+	})
+	if err == nil && status == domain.StatusCancelled {
+		s.metrics.AppointmentsCancelled(1)
 	}
-	return appointments, nil
+	return changed, err
 }
 
-func (s *service) Create(a domain.Appointment) (domain.AppointmentDTO, error) {
-	aSavedInterface, err := s.r.Create(a)
+// changeStatus - reads the appointment and moves it to status through r, if the
+// lifecycle allows it
+func changeStatus(ctx context.Context, r Repository, id int, status domain.AppointmentStatus, reason string) (domain.AppointmentDTO, error) {
+	a, err := r.GetByID(ctx, id)
 	if err != nil {
 		return domain.AppointmentDTO{}, err
 	}
-	apSaved, ok := aSavedInterface.(domain.AppointmentDTO)
-	if ok {
-		return apSaved, nil
+	if !a.Status.CanTransitionTo(status) {
+		return domain.AppointmentDTO{}, fmt.Errorf("%w: from %s to %s", domain.ErrInvalidTransition, a.Status, status)
 	}
+	return r.ChangeStatus(ctx, domain.StatusChange{
+		AppointmentId: id,
+		From:          a.Status,
+		To:            status,
+		Reason:        reason,
+	})
+}
 
-	return domain.AppointmentDTO{}, errors.New("failed to save a new appointment")
+func (s *service) GetStatusHistory(ctx context.Context, id int) ([]domain.StatusChange, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.r.GetStatusHistory(ctx, id)
 }
 
-func (s *service) Update(id int, a domain.Appointment) (domain.AppointmentDTO, error) {
-	aUpdate, err := s.GetByID(id)
-	if err != nil {
-		return domain.AppointmentDTO{}, err
+// newAppointment - new appointments always start as scheduled, without an id and
+// outside a series, the procedure defaults to a consultation and the duration to the
+// one of the procedure
+func newAppointment(a domain.Appointment) domain.Appointment {
+	a.Id = 0
+	a.Status = domain.StatusScheduled
+	a.SeriesId = 0
+	if a.ProcedureType == "" {
+		a.ProcedureType = domain.ProcedureConsultation
 	}
+	if a.DurationMinutes == 0 {
+		a.DurationMinutes = a.ProcedureType.DefaultDuration()
+	}
+	return a
+}
 
+// mergeUpdate - fills the empty fields of a with the ones of current, the status
+// and the series never change through an update. A zero version is the current one.
+func mergeUpdate(current, a domain.Appointment) domain.Appointment {
 	if a.Description == "" {
-		a.Description = aUpdate.Description
+		a.Description = current.Description
 	}
-	if a.DateAndTime == "" {
-		a.DateAndTime = aUpdate.DateAndTime
+	if a.DateAndTime.IsZero() {
+		a.DateAndTime = current.DateAndTime
 	}
 	if a.DentistLicense == "" {
-		a.DentistLicense = aUpdate.DentistLicense
+		a.DentistLicense = current.DentistLicense
 	}
 	if a.PatientIdentity == "" {
-		a.PatientIdentity = aUpdate.PatientIdentity
+		a.PatientIdentity = current.PatientIdentity
 	}
-	a.Id = aUpdate.Id
-
-	updated, err := s.r.Update(id, a)
-	if err != nil {
-		return domain.AppointmentDTO{}, err
+	if a.DurationMinutes == 0 {
+		// a new procedure without a duration takes the default one of the procedure
+		if a.ProcedureType != "" && a.ProcedureType != current.ProcedureType {
+			a.DurationMinutes = a.ProcedureType.DefaultDuration()
+		} else {
+			a.DurationMinutes = current.DurationMinutes
+		}
 	}
-	response, ok := updated.(domain.AppointmentDTO)
-	if !ok {
-		return domain.AppointmentDTO{}, errors.New("failed to update appointment")
+	if a.ProcedureType == "" {
+		a.ProcedureType = current.ProcedureType
 	}
+	if a.Version == 0 {
+		a.Version = current.Version
+	}
+	a.Id = current.Id
+	a.Status = current.Status
+	a.SeriesId = current.SeriesId
+	return a
+}
 
-	return response, nil
+// rescheduled - tells if a takes another interval or dentist than current, the
+// appointments kept where they are aren't checked against the availability again
+func rescheduled(current, a domain.Appointment) bool {
+	return !a.DateAndTime.Equal(current.DateAndTime) || a.DurationMinutes != current.DurationMinutes ||
+		a.DentistLicense != current.DentistLicense
 }
 
-func (s *service) Delete(id int) error {
-	return s.r.Delete(id)
+func validateDuration(a domain.Appointment) error {
+	invalid := &domain.ValidationError{}
+	if !a.ProcedureType.IsValid() {
+		invalid.Add("procedure_type", "oneof", fmt.Sprintf("unknown procedure type: %s", a.ProcedureType))
+	}
+	if a.DurationMinutes <= 0 || a.DurationMinutes > domain.MaxAppointmentDuration {
+		invalid.Add("duration_minutes", "range", fmt.Sprintf("the duration must be between 1 and %d minutes", domain.MaxAppointmentDuration))
+	}
+	return invalid.OrNil()
+}
+
+// countRejection - counts the bookings and reschedules rejected because the dentist
+// wasn't available, the slot being taken or out of the schedule or the dentist
+// inactive
+func (s *service) countRejection(err error) {
+	switch {
+	case errors.Is(err, domain.ErrSlotUnavailable):
+		s.metrics.AppointmentRejected("slot_unavailable")
+	case errors.Is(err, domain.ErrDentistInactive):
+		s.metrics.AppointmentRejected("dentist_inactive")
+	}
 }
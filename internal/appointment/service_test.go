@@ -0,0 +1,301 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/internal/schedule"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/metrics"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+// thursday - 2030-01-10 at hour:minute in UTC, the clinic zone of the tests
+func thursday(hour, minute int) time.Time {
+	return time.Date(2030, time.January, 10, hour, minute, 0, 0, time.UTC)
+}
+
+// newTestService - the dentists L1 and L2, working on thursdays from 08:00 to
+// 18:00, L3 without working hours, and the patients P1 and P2, in a memory store
+func newTestService(t *testing.T) (Service, store.Store) {
+	t.Helper()
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	for _, license := range []string{"L1", "L2"} {
+		dentist, err := m.Dentists().Save(ctx, domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: license})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Schedules().SaveSchedule(ctx, domain.Schedule{
+			DentistId:    dentist.Id,
+			WorkingHours: []domain.WeeklyInterval{{Weekday: 4, Start: "08:00", End: "18:00"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Dentists().Save(ctx, domain.Dentist{Surname: "Souza", Name: "Rui", LicenseNumber: "L3"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, identity := range []string{"P1", "P2"} {
+		if _, err := m.Patients().Save(ctx, domain.Patient{Surname: "Lima", Name: "João", IdentityNumber: identity, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ap := m.Appointments()
+	schedules := schedule.NewService(schedule.NewRepository(m.Schedules(), m.Dentists(), ap), time.UTC)
+	return NewService(NewRepository(ap, m), schedules, nil), m
+}
+
+func appointmentAt(start time.Time, dentist, patient string) domain.Appointment {
+	return domain.Appointment{Description: "checkup", DateAndTime: start, DentistLicense: dentist, PatientIdentity: patient}
+}
+
+func violatedField(err error) string {
+	var validation *domain.ValidationError
+	if !errors.As(err, &validation) {
+		return ""
+	}
+	return validation.Violations[0].Field
+}
+
+func TestCreateDefaults(t *testing.T) {
+	s, _ := newTestService(t)
+	a := appointmentAt(thursday(8, 0), "L1", "P1")
+	a.Status = domain.StatusCompleted
+
+	created, err := s.Create(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != domain.StatusScheduled {
+		t.Errorf("status %s, want scheduled", created.Status)
+	}
+	if created.ProcedureType != domain.ProcedureConsultation || created.DurationMinutes != 60 {
+		t.Errorf("procedure %s of %d minutes, want a consultation of 60", created.ProcedureType, created.DurationMinutes)
+	}
+	if !created.EndDateAndTime.Equal(thursday(9, 0)) {
+		t.Errorf("ends at %v, want 09:00", created.EndDateAndTime)
+	}
+
+	a.ProcedureType, a.DurationMinutes = domain.ProcedureRootCanal, 0
+	a.DateAndTime = thursday(10, 0)
+	created, err = s.Create(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.DurationMinutes != 90 {
+		t.Errorf("a root canal takes %d minutes, want 90", created.DurationMinutes)
+	}
+}
+
+func TestCreateDurationRules(t *testing.T) {
+	s, _ := newTestService(t)
+	tests := []struct {
+		name      string
+		procedure domain.ProcedureType
+		minutes   int
+		field     string
+	}{
+		{"unknown procedure", "massage", 30, "procedure_type"},
+		{"negative duration", domain.ProcedureCleaning, -15, "duration_minutes"},
+		{"longer than allowed", domain.ProcedureCleaning, domain.MaxAppointmentDuration + 1, "duration_minutes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := appointmentAt(thursday(8, 0), "L1", "P1")
+			a.ProcedureType, a.DurationMinutes = tt.procedure, tt.minutes
+			if _, err := s.Create(context.Background(), a); violatedField(err) != tt.field {
+				t.Errorf("expected a violation of %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+// TestCreateOverlap - the dentist and the patient can't be in two appointments at
+// once, the periods are half-open and the inactive appointments free their time
+func TestCreateOverlap(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	booked, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L1", "P1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 30), "L1", "P2")); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Errorf("same dentist: expected ErrSlotUnavailable, got %v", err)
+	}
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 30), "L2", "P1")); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Errorf("same patient: expected ErrSlotUnavailable, got %v", err)
+	}
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L2", "P2")); err != nil {
+		t.Errorf("other dentist and patient: %v", err)
+	}
+	if _, err := s.Create(ctx, appointmentAt(thursday(9, 0), "L1", "P1")); err != nil {
+		t.Errorf("right after the other one: %v", err)
+	}
+
+	if _, err := s.ChangeStatus(ctx, booked.Id, domain.StatusCancelled, "patient asked"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L1", "P1")); err != nil {
+		t.Errorf("in the time of a cancelled appointment: %v", err)
+	}
+}
+
+// TestCreateAvailability - the appointments must fit the dentist schedule
+func TestCreateAvailability(t *testing.T) {
+	ctx := context.Background()
+	s, m := newTestService(t)
+
+	if _, err := s.Create(ctx, appointmentAt(thursday(17, 30), "L1", "P1")); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Errorf("past the working hours: expected ErrSlotUnavailable, got %v", err)
+	}
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 0).AddDate(0, 0, 1), "L1", "P1")); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Errorf("on a day off: expected ErrSlotUnavailable, got %v", err)
+	}
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L9", "P1")); violatedField(err) != "dentist_license" {
+		t.Errorf("unknown dentist: expected a violation of dentist_license, got %v", err)
+	}
+
+	created, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L1", "P1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Update(ctx, created.Id, domain.Appointment{DateAndTime: thursday(17, 30)}); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Errorf("moved past the working hours: expected ErrSlotUnavailable, got %v", err)
+	}
+
+	// the time off taken after the booking doesn't prevent the changes keeping it in place
+	if _, err := m.Schedules().SaveTimeOff(ctx, domain.TimeOff{DentistId: created.Dentist.Id, From: thursday(8, 0), To: thursday(12, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Update(ctx, created.Id, domain.Appointment{Description: "bring the x-rays"}); err != nil {
+		t.Errorf("description changed: %v", err)
+	}
+	if _, err := s.Update(ctx, created.Id, domain.Appointment{DurationMinutes: 90}); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Errorf("longer on time off: expected ErrSlotUnavailable, got %v", err)
+	}
+
+	// a dentist without working hours takes bookings at any time
+	if _, err := s.Create(ctx, appointmentAt(thursday(20, 0).AddDate(0, 0, 1), "L3", "P2")); err != nil {
+		t.Errorf("dentist without working hours: %v", err)
+	}
+}
+
+// outsideTx - the repository of the tests whose schedules fail outside its units
+// of work
+type outsideTx struct {
+	Repository
+}
+
+func (outsideTx) Schedules() schedule.Repository {
+	return failingSchedules{}
+}
+
+type failingSchedules struct {
+	schedule.Repository
+}
+
+func (failingSchedules) GetDentistByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error) {
+	return domain.Dentist{}, errors.New("schedules read outside the transaction")
+}
+
+// TestCreateChecksAvailabilityInTx - the availability is read in the transaction
+// of the booking
+func TestCreateChecksAvailabilityInTx(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	if _, err := m.Dentists().Save(ctx, domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: "L1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Patients().Save(ctx, domain.Patient{Surname: "Lima", Name: "João", IdentityNumber: "P1", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	ap := m.Appointments()
+	schedules := schedule.NewService(schedule.NewRepository(m.Schedules(), m.Dentists(), ap), time.UTC)
+	s := NewService(outsideTx{NewRepository(ap, m)}, schedules, nil)
+
+	if _, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L1", "P1")); err != nil {
+		t.Errorf("Create: %v", err)
+	}
+	report, err := s.CreateSeries(ctx, appointmentAt(thursday(10, 0), "L1", "P1"), domain.Recurrence{Frequency: domain.FrequencyWeekly, Count: 2})
+	if err != nil || len(report.Appointments) != 2 {
+		t.Errorf("CreateSeries: %d appointments, %v", len(report.Appointments), err)
+	}
+}
+
+// TestRejectionsCounted - the bookings, reschedules and occurrences of series the
+// dentist isn't available for are counted
+func TestRejectionsCounted(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	m := metrics.New()
+	s.(*service).metrics = m
+
+	if _, err := s.Create(ctx, appointmentAt(thursday(17, 30), "L1", "P1")); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Fatalf("past the working hours: expected ErrSlotUnavailable, got %v", err)
+	}
+	created, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L1", "P1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Update(ctx, created.Id, domain.Appointment{DateAndTime: thursday(17, 30)}); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Fatalf("moved past the working hours: expected ErrSlotUnavailable, got %v", err)
+	}
+	report, err := s.CreateSeries(ctx, appointmentAt(thursday(10, 0), "L1", "P1"), domain.Recurrence{Frequency: domain.FrequencyWeekly, Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report, err = s.UpdateSeries(ctx, report.Appointments[0].Id, domain.Appointment{DateAndTime: thursday(17, 30)}, domain.ScopeAll); err != nil || len(report.Conflicts) != 2 {
+		t.Fatalf("series moved past the working hours: conflicts %v, %v", report.Conflicts, err)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `clinic_appointments_rejected_total{reason="slot_unavailable"} 4`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected %s in\n%s", want, w.Body)
+	}
+}
+
+func TestChangeStatus(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	created, err := s.Create(ctx, appointmentAt(thursday(8, 0), "L1", "P1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ChangeStatus(ctx, created.Id, domain.StatusCompleted, ""); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("scheduled to completed: expected ErrInvalidTransition, got %v", err)
+	}
+	if _, err := s.ChangeStatus(ctx, created.Id, domain.StatusCancelled, ""); violatedField(err) != "reason" {
+		t.Errorf("cancelled without a reason: expected a violation of reason, got %v", err)
+	}
+	for _, status := range []domain.AppointmentStatus{domain.StatusConfirmed, domain.StatusCheckedIn, domain.StatusInProgress, domain.StatusCompleted} {
+		changed, err := s.ChangeStatus(ctx, created.Id, status, "")
+		if err != nil {
+			t.Fatalf("to %s: %v", status, err)
+		}
+		if changed.Status != status {
+			t.Fatalf("status %s, want %s", changed.Status, status)
+		}
+	}
+
+	history, err := s.GetStatusHistory(ctx, created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 5 || history[0].From != "" || history[4].To != domain.StatusCompleted {
+		t.Errorf("history %v, want the creation and 4 changes", history)
+	}
+
+	var conflict *domain.ConflictError
+	if _, err := s.Update(ctx, created.Id, domain.Appointment{Description: "again"}); !errors.As(err, &conflict) || conflict.Code != "appointment_final" {
+		t.Errorf("update of a completed appointment: expected the appointment_final conflict, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+type Repository interface {
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AuditEntry], error)
+}
+
+type repository struct {
+	store store.AuditStore
+}
+
+func NewRepository(store store.AuditStore) Repository {
+	return &repository{store}
+}
+
+// List - returns a page of audit entries filtered and sorted by opts
+func (r *repository) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AuditEntry], error) {
+	return r.store.ListAuditEntries(ctx, opts)
+}
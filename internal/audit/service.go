@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+// entities - the entities recorded in the audit log
+var entities = []string{domain.AuditDentists, domain.AuditPatients, domain.AuditAppointments}
+
+type Service interface {
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AuditEntry], error)
+}
+
+type service struct {
+	r Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{r}
+}
+
+// List - the newest entries first unless opts sorts them otherwise. An entity_id
+// filter requires the entity it's an id of.
+func (s *service) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.AuditEntry], error) {
+	invalid := &domain.ValidationError{}
+	if entity, ok := opts.Filters["entity"]; ok && !isEntity(entity) {
+		invalid.Add("entity", "oneof", fmt.Sprintf("must be one of %v", entities))
+	}
+	if _, ok := opts.Filters["entity_id"]; ok && opts.Filters["entity"] == "" {
+		invalid.Add("entity", "required", "is required along with id")
+	}
+	if err := invalid.OrNil(); err != nil {
+		return store.Page[domain.AuditEntry]{}, err
+	}
+	if opts.Sort == "" {
+		opts.Sort = "-at"
+	}
+	return s.r.List(ctx, opts)
+}
+
+func isEntity(entity string) bool {
+	for _, e := range entities {
+		if e == entity {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+type Repository interface {
+	GetUserByID(ctx context.Context, userID int) (domain.User, error)
+	GetUserByEmail(ctx context.Context, email string) (domain.User, error)
+	CreateUser(ctx context.Context, user domain.User) (domain.User, error)
+	GetDentist(ctx context.Context, dentistID int) (domain.Dentist, error)
+	GetPatient(ctx context.Context, patientID int) (domain.Patient, error)
+}
+
+type repository struct {
+	users    store.UserStore
+	dentists store.Repository[domain.Dentist]
+	patients store.Repository[domain.Patient]
+}
+
+func NewRepository(users store.UserStore, dentists store.Repository[domain.Dentist], patients store.Repository[domain.Patient]) Repository {
+	return &repository{users, dentists, patients}
+}
+
+func (r *repository) GetUserByID(ctx context.Context, userID int) (domain.User, error) {
+	return r.users.GetUserByID(ctx, userID)
+}
+
+func (r *repository) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	return r.users.GetUserByEmail(ctx, email)
+}
+
+func (r *repository) CreateUser(ctx context.Context, user domain.User) (domain.User, error) {
+	return r.users.SaveUser(ctx, user)
+}
+
+func (r *repository) GetDentist(ctx context.Context, dentistID int) (domain.Dentist, error) {
+	return r.dentists.GetByID(ctx, dentistID)
+}
+
+func (r *repository) GetPatient(ctx context.Context, patientID int) (domain.Patient, error) {
+	return r.patients.GetByID(ctx, patientID)
+}
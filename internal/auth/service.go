@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength - the shortest password accepted by CreateUser
+const minPasswordLength = 8
+
+// Config - the HMAC secret signing the tokens and their lifetimes
+type Config struct {
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+type Service interface {
+	Login(ctx context.Context, c domain.Credentials) (domain.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (domain.TokenPair, error)
+	Authenticate(accessToken string) (domain.Principal, error)
+	CreateUser(ctx context.Context, u domain.User, password string) (domain.User, error)
+	EnsureAdmin(ctx context.Context, email, password string) error
+}
+
+type service struct {
+	r   Repository
+	cfg Config
+	// dummyHash - compared when the email doesn't exist, so the login takes the same time
+	dummyHash []byte
+}
+
+func NewService(r Repository, cfg Config) (Service, error) {
+	if len(cfg.Secret) < 32 {
+		return nil, errors.New("the token secret must have at least 32 bytes")
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &service{r: r, cfg: cfg, dummyHash: dummyHash}, nil
+}
+
+func (s *service) Login(ctx context.Context, c domain.Credentials) (domain.TokenPair, error) {
+	user, err := s.r.GetUserByEmail(ctx, c.Email)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			return domain.TokenPair{}, err
+		}
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(c.Password))
+		return domain.TokenPair{}, domain.ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(c.Password)); err != nil {
+		return domain.TokenPair{}, domain.ErrInvalidCredentials
+	}
+	return s.tokens(ctx, user)
+}
+
+// Refresh - issues a new pair from a refresh token, the user is loaded again so
+// changes of role are seen
+func (s *service) Refresh(ctx context.Context, token string) (domain.TokenPair, error) {
+	c, err := s.parse(refreshToken, token)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return domain.TokenPair{}, domain.ErrInvalidToken
+	}
+	user, err := s.r.GetUserByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.TokenPair{}, domain.ErrInvalidToken
+	}
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	return s.tokens(ctx, user)
+}
+
+// Authenticate - returns the principal of a valid access token
+func (s *service) Authenticate(token string) (domain.Principal, error) {
+	c, err := s.parse(accessToken, token)
+	if err != nil {
+		return domain.Principal{}, err
+	}
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return domain.Principal{}, domain.ErrInvalidToken
+	}
+	return domain.Principal{
+		UserId:          userID,
+		Email:           c.Email,
+		Role:            c.Role,
+		DentistId:       c.DentistId,
+		DentistLicense:  c.DentistLicense,
+		PatientId:       c.PatientId,
+		PatientIdentity: c.PatientIdentity,
+	}, nil
+}
+
+// CreateUser - dentists and patients users must reference their dentist or patient,
+// the other roles can't reference any
+func (s *service) CreateUser(ctx context.Context, u domain.User, password string) (domain.User, error) {
+	invalid := &domain.ValidationError{}
+	if !u.Role.IsValid() {
+		invalid.Add("role", "oneof", fmt.Sprintf("unknown role: %s", u.Role))
+	}
+	if len(password) < minPasswordLength {
+		invalid.Add("password", "min", fmt.Sprintf("the password must have at least %d characters", minPasswordLength))
+	}
+	switch {
+	case u.Role == domain.RoleDentist && (u.DentistId == 0 || u.PatientId != 0):
+		invalid.Add("dentist_id", "required", "a dentist user must reference only a dentist_id")
+	case u.Role == domain.RolePatient && (u.PatientId == 0 || u.DentistId != 0):
+		invalid.Add("patient_id", "required", "a patient user must reference only a patient_id")
+	case u.Role != domain.RoleDentist && u.Role != domain.RolePatient && (u.DentistId != 0 || u.PatientId != 0):
+		invalid.Add("role", "excluded", fmt.Sprintf("a %s user can't reference a dentist or patient", u.Role))
+	}
+	if err := invalid.OrNil(); err != nil {
+		return domain.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return domain.User{}, err
+	}
+	u.PasswordHash = string(hash)
+	return s.r.CreateUser(ctx, u)
+}
+
+// EnsureAdmin - creates the admin user if there's no user with email, so the first
+// users can be created through the API
+func (s *service) EnsureAdmin(ctx context.Context, email, password string) error {
+	if _, err := s.r.GetUserByEmail(ctx, email); !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+	_, err := s.CreateUser(ctx, domain.User{Email: email, Role: domain.RoleAdmin}, password)
+	return err
+}
+
+// tokens - issues the pair of a user, reading the numbers its appointments reference
+func (s *service) tokens(ctx context.Context, user domain.User) (domain.TokenPair, error) {
+	p := domain.Principal{
+		UserId:    user.Id,
+		Email:     user.Email,
+		Role:      user.Role,
+		DentistId: user.DentistId,
+		PatientId: user.PatientId,
+	}
+	if user.DentistId != 0 {
+		dentist, err := s.r.GetDentist(ctx, user.DentistId)
+		if err != nil {
+			return domain.TokenPair{}, err
+		}
+		p.DentistLicense = dentist.LicenseNumber
+	}
+	if user.PatientId != 0 {
+		patient, err := s.r.GetPatient(ctx, user.PatientId)
+		if err != nil {
+			return domain.TokenPair{}, err
+		}
+		p.PatientIdentity = patient.IdentityNumber
+	}
+
+	access, err := s.sign(accessToken, p, s.cfg.AccessTTL)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	refresh, err := s.sign(refreshToken, p, s.cfg.RefreshTTL)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	return domain.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.cfg.AccessTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+var testConfig = Config{
+	Secret:     []byte("0123456789abcdef0123456789abcdef"),
+	AccessTTL:  15 * time.Minute,
+	RefreshTTL: time.Hour,
+}
+
+// newTestService - the service over a memory store with the dentist L1
+func newTestService(t *testing.T) (Service, domain.Dentist) {
+	t.Helper()
+	m := store.NewMemoryStore()
+	dentist, err := m.Dentists().Save(context.Background(), domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: "L1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewService(NewRepository(m.Users(), m.Dentists(), m.Patients()), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dentist
+}
+
+func TestNewServiceShortSecret(t *testing.T) {
+	cfg := testConfig
+	cfg.Secret = []byte("short")
+	if _, err := NewService(nil, cfg); err == nil {
+		t.Error("expected a secret shorter than 32 bytes to be rejected")
+	}
+}
+
+func TestLoginAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	s, dentist := newTestService(t)
+	user, err := s.CreateUser(ctx, domain.User{Email: "ana@clinic.com", Role: domain.RoleDentist, DentistId: dentist.Id}, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Login(ctx, domain.Credentials{Email: "ana@clinic.com", Password: "wrong password"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Errorf("wrong password: expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := s.Login(ctx, domain.Credentials{Email: "nobody@clinic.com", Password: "password1"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Errorf("unknown email: expected ErrInvalidCredentials, got %v", err)
+	}
+
+	pair, err := s.Login(ctx, domain.Credentials{Email: "ana@clinic.com", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != int(testConfig.AccessTTL.Seconds()) {
+		t.Errorf("token type %s expiring in %d, want Bearer in %d", pair.TokenType, pair.ExpiresIn, int(testConfig.AccessTTL.Seconds()))
+	}
+	principal, err := s.Authenticate(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := domain.Principal{UserId: user.Id, Email: "ana@clinic.com", Role: domain.RoleDentist, DentistId: dentist.Id, DentistLicense: "L1"}
+	if principal != want {
+		t.Errorf("principal %+v, want %+v", principal, want)
+	}
+
+	if _, err := s.Authenticate(pair.RefreshToken); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("refresh token as access token: expected ErrInvalidToken, got %v", err)
+	}
+	if _, err := s.Refresh(ctx, pair.AccessToken); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("access token as refresh token: expected ErrInvalidToken, got %v", err)
+	}
+	refreshed, err := s.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal, err := s.Authenticate(refreshed.AccessToken); err != nil || principal != want {
+		t.Errorf("refreshed principal %+v, %v, want %+v", principal, err, want)
+	}
+}
+
+// TestAuthenticateRejects - tokens expired, signed with another secret or
+// algorithm, or altered aren't accepted
+func TestAuthenticateRejects(t *testing.T) {
+	s, _ := newTestService(t)
+	p := domain.Principal{UserId: 1, Email: "admin@clinic.com", Role: domain.RoleAdmin}
+	valid, err := s.(*service).sign(accessToken, p, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.(*service).sign(accessToken, p, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &service{cfg: Config{Secret: []byte("another secret of at least 32 bytes")}}
+	otherSecret, err := other.sign(accessToken, p, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		Type:             accessToken,
+		Role:             domain.RoleAdmin,
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the payload of a receptionist with the signature of the admin
+	receptionist, err := s.(*service).sign(accessToken, domain.Principal{UserId: 1, Role: domain.RoleReceptionist}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	altered := receptionist[:strings.LastIndex(receptionist, ".")] + valid[strings.LastIndex(valid, "."):]
+
+	tokens := map[string]string{
+		"expired":            expired,
+		"another secret":     otherSecret,
+		"no signature":       unsigned,
+		"altered":            altered,
+		"not a token":        "abc",
+		"the valid one, cut": valid[:len(valid)-2],
+	}
+	for name, token := range tokens {
+		if _, err := s.Authenticate(token); !errors.Is(err, domain.ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+	if _, err := s.Authenticate(valid); err != nil {
+		t.Errorf("valid token: %v", err)
+	}
+}
+
+func TestCreateUserRoles(t *testing.T) {
+	ctx := context.Background()
+	s, dentist := newTestService(t)
+	tests := []struct {
+		name  string
+		user  domain.User
+		field string
+	}{
+		{"unknown role", domain.User{Email: "a@clinic.com", Role: "owner"}, "role"},
+		{"dentist without dentist", domain.User{Email: "a@clinic.com", Role: domain.RoleDentist}, "dentist_id"},
+		{"patient with a dentist", domain.User{Email: "a@clinic.com", Role: domain.RolePatient, DentistId: dentist.Id}, "patient_id"},
+		{"receptionist with a dentist", domain.User{Email: "a@clinic.com", Role: domain.RoleReceptionist, DentistId: dentist.Id}, "role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateUser(ctx, tt.user, "password1")
+			var validation *domain.ValidationError
+			if !errors.As(err, &validation) || validation.Violations[0].Field != tt.field {
+				t.Errorf("expected a violation of %s, got %v", tt.field, err)
+			}
+		})
+	}
+
+	if _, err := s.CreateUser(ctx, domain.User{Email: "a@clinic.com", Role: domain.RoleAdmin}, "short"); err == nil {
+		t.Error("expected a short password to be rejected")
+	}
+	if _, err := s.CreateUser(ctx, domain.User{Email: "a@clinic.com", Role: domain.RoleAdmin}, "password1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateUser(ctx, domain.User{Email: "a@clinic.com", Role: domain.RoleReceptionist}, "password1"); !errors.Is(err, domain.ErrDuplicateEmail) {
+		t.Errorf("same email: expected ErrDuplicateEmail, got %v", err)
+	}
+}
+
+func TestPrincipalRoles(t *testing.T) {
+	dentist := domain.Principal{Role: domain.RoleDentist, DentistLicense: "L1"}
+	patient := domain.Principal{Role: domain.RolePatient, PatientIdentity: "P1"}
+	receptionist := domain.Principal{Role: domain.RoleReceptionist}
+	a := domain.Appointment{DentistLicense: "L1", PatientIdentity: "P2"}
+
+	if !dentist.HasRole(domain.RoleAdmin, domain.RoleDentist) || dentist.HasRole(domain.RoleAdmin, domain.RoleReceptionist) {
+		t.Error("HasRole: a dentist has the dentist role only")
+	}
+	if !dentist.CanAccess(a) || patient.CanAccess(a) || !receptionist.CanAccess(a) {
+		t.Error("CanAccess: dentists and patients reach their own appointments, the staff every one")
+	}
+}
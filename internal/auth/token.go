@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+const (
+	accessToken  = "access"
+	refreshToken = "refresh"
+)
+
+// claims - the payload of both tokens, the subject is the user id. Refresh tokens
+// only carry the registered claims as the principal is loaded again on refresh.
+type claims struct {
+	jwt.RegisteredClaims
+	Type            string      `json:"typ"`
+	Email           string      `json:"email,omitempty"`
+	Role            domain.Role `json:"role,omitempty"`
+	DentistId       int         `json:"dentist_id,omitempty"`
+	DentistLicense  string      `json:"dentist_license,omitempty"`
+	PatientId       int         `json:"patient_id,omitempty"`
+	PatientIdentity string      `json:"patient_identity,omitempty"`
+}
+
+// sign - issues a token of kind for the principal, valid for ttl
+func (s *service) sign(kind string, p domain.Principal, ttl time.Duration) (string, error) {
+	now := time.Now()
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(p.UserId),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type: kind,
+	}
+	if kind == accessToken {
+		c.Email = p.Email
+		c.Role = p.Role
+		c.DentistId = p.DentistId
+		c.DentistLicense = p.DentistLicense
+		c.PatientId = p.PatientId
+		c.PatientIdentity = p.PatientIdentity
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(s.cfg.Secret)
+}
+
+// parse - validates the signature, the expiration and the kind of a token
+func (s *service) parse(kind, token string) (claims, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (interface{}, error) {
+		return s.cfg.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || c.Type != kind {
+		return claims{}, domain.ErrInvalidToken
+	}
+	return c, nil
+}
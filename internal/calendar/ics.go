@@ -0,0 +1,202 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+const (
+	// uidDomain - the right hand side of the events UID, which must stay the same
+	// for an appointment so calendars update it instead of duplicating it
+	uidDomain = "esp-backiii-go"
+	// localLayout - the DATE-TIME form used with a TZID parameter
+	localLayout = "20060102T150405"
+	// utcLayout - the DATE-TIME form in UTC
+	utcLayout = "20060102T150405Z"
+	// maxLineOctets - longer content lines are folded, RFC 5545 section 3.1
+	maxLineOctets = 75
+)
+
+// feed - an iCalendar (RFC 5545) file with the appointments of a dentist or patient
+type feed struct {
+	name     string
+	location *time.Location
+	events   []event
+}
+
+// event - start and end are instants, written at the clinic wall clock
+type event struct {
+	uid         string
+	summary     string
+	description string
+	status      string
+	start       time.Time
+	end         time.Time
+}
+
+func (f *feed) add(a domain.AppointmentDTO, summary string) {
+	start, end := a.Interval()
+	f.events = append(f.events, event{
+		uid:         fmt.Sprintf("appointment-%d@%s", a.Id, uidDomain),
+		summary:     summary,
+		description: a.Description,
+		status:      eventStatus(a.Status),
+		start:       start,
+		end:         end,
+	})
+}
+
+// encode - writes the calendar with a VTIMEZONE covering every event
+func (f *feed) encode(now time.Time) []byte {
+	var b bytes.Buffer
+	write := func(name, value string) {
+		writeLine(&b, name+":"+value)
+	}
+
+	write("BEGIN", "VCALENDAR")
+	write("VERSION", "2.0")
+	write("PRODID", "-//"+uidDomain+"//clinic agenda//EN")
+	write("CALSCALE", "GREGORIAN")
+	write("METHOD", "PUBLISH")
+	write("X-WR-CALNAME", escapeText(f.name))
+	write("X-WR-TIMEZONE", f.location.String())
+	f.writeTimezone(write, now)
+
+	tzid := "TZID=" + f.location.String()
+	for _, e := range f.events {
+		write("BEGIN", "VEVENT")
+		write("UID", e.uid)
+		write("DTSTAMP", now.UTC().Format(utcLayout))
+		write("DTSTART;"+tzid, e.start.In(f.location).Format(localLayout))
+		write("DTEND;"+tzid, e.end.In(f.location).Format(localLayout))
+		write("SUMMARY", escapeText(e.summary))
+		if e.description != "" {
+			write("DESCRIPTION", escapeText(e.description))
+		}
+		write("STATUS", e.status)
+		write("END", "VEVENT")
+	}
+	write("END", "VCALENDAR")
+	return b.Bytes()
+}
+
+// writeTimezone - writes the offset in force at the first event and every change
+// of offset until the last one, so the events are read in the right offset
+// whatever the calendar application knows about the zone. The range starts at now
+// at the latest.
+func (f *feed) writeTimezone(write func(name, value string), now time.Time) {
+	from, to := now, now
+	for _, e := range f.events {
+		if e.start.Before(from) {
+			from = e.start
+		}
+		if e.end.After(to) {
+			to = e.end
+		}
+	}
+	from = from.AddDate(0, 0, -1).In(f.location)
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, f.location)
+	to = to.AddDate(0, 0, 1).In(f.location)
+
+	write("BEGIN", "VTIMEZONE")
+	write("TZID", f.location.String())
+	_, offset := from.Zone()
+	writeObservance(write, from, offset)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		if _, nextOffset := next.Zone(); nextOffset != offset {
+			change := transition(day, next)
+			writeObservance(write, change, offset)
+			offset = nextOffset
+		}
+	}
+	write("END", "VTIMEZONE")
+}
+
+// writeObservance - the offset starting at onset, whose DTSTART is written in the
+// offset in force before it
+func writeObservance(write func(name, value string), onset time.Time, offsetFrom int) {
+	kind := "STANDARD"
+	if onset.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	name, offsetTo := onset.Zone()
+	write("BEGIN", kind)
+	write("DTSTART", onset.In(time.FixedZone("", offsetFrom)).Format(localLayout))
+	write("TZOFFSETFROM", formatOffset(offsetFrom))
+	write("TZOFFSETTO", formatOffset(offsetTo))
+	write("TZNAME", escapeText(name))
+	write("END", kind)
+}
+
+// transition - finds by bisection the first second with the offset of to
+func transition(from, to time.Time) time.Time {
+	_, offset := to.Zone()
+	for to.Sub(from) > time.Second {
+		middle := from.Add(to.Sub(from) / 2)
+		if _, middleOffset := middle.Zone(); middleOffset == offset {
+			to = middle
+		} else {
+			from = middle
+		}
+	}
+	return to
+}
+
+func eventStatus(status domain.AppointmentStatus) string {
+	switch status {
+	case domain.StatusCancelled:
+		return "CANCELLED"
+	case domain.StatusScheduled:
+		return "TENTATIVE"
+	}
+	return "CONFIRMED"
+}
+
+// formatOffset - formats seconds east of UTC as the UTC-OFFSET value type, e.g. -0300
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	offset := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		offset += fmt.Sprintf("%02d", seconds%60)
+	}
+	return offset
+}
+
+// escapeText - escapes a TEXT value, RFC 5545 section 3.3.11
+func escapeText(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// writeLine - writes a content line ended by CRLF, folding it in lines of at most
+// 75 octets without splitting a UTF-8 character
+func writeLine(b *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space of the continuation counts in its length
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
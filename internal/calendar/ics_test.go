@@ -0,0 +1,189 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+// unfold - the content lines of a calendar, its folded lines joined again
+func unfold(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n ", ""), "\r\n"), "\r\n")
+}
+
+func hasLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFeedEncode(t *testing.T) {
+	location := loadLocation(t, "America/New_York")
+	start := time.Date(2030, time.March, 14, 10, 0, 0, 0, location)
+	f := feed{name: "Agenda - Dr. Ana Silva", location: location}
+	f.add(domain.AppointmentDTO{Appointment: domain.Appointment{
+		Id:              7,
+		Description:     "bring the x-rays; and the exams, please",
+		DateAndTime:     start,
+		DurationMinutes: 90,
+		Status:          domain.StatusConfirmed,
+	}}, "root_canal - João Lima")
+	f.add(domain.AppointmentDTO{Appointment: domain.Appointment{Id: 8, DateAndTime: start.AddDate(0, 0, 7), DurationMinutes: 30, Status: domain.StatusCancelled}}, "cleaning")
+	now := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	data := f.encode(now)
+	lines := unfold(data)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"X-WR-CALNAME:Agenda - Dr. Ana Silva",
+		"X-WR-TIMEZONE:America/New_York",
+		"TZID:America/New_York",
+		"UID:appointment-7@esp-backiii-go",
+		"DTSTAMP:20300101T120000Z",
+		"DTSTART;TZID=America/New_York:20300314T100000",
+		"DTEND;TZID=America/New_York:20300314T113000",
+		`SUMMARY:root_canal - João Lima`,
+		`DESCRIPTION:bring the x-rays\; and the exams\, please`,
+		"STATUS:CONFIRMED",
+		"UID:appointment-8@esp-backiii-go",
+		"STATUS:CANCELLED",
+		"END:VCALENDAR",
+	} {
+		if !hasLine(lines, want) {
+			t.Errorf("missing line %q in\n%s", want, data)
+		}
+	}
+	if strings.Count(string(data), "BEGIN:VEVENT") != 2 {
+		t.Errorf("expected 2 events in\n%s", data)
+	}
+	if !bytes.HasSuffix(data, []byte("END:VCALENDAR\r\n")) {
+		t.Error("the calendar doesn't end with END:VCALENDAR and CRLF")
+	}
+}
+
+// TestFeedTimezone - the daylight saving time starting on 2030-03-10 at 02:00 in
+// New York is described by the VTIMEZONE
+func TestFeedTimezone(t *testing.T) {
+	location := loadLocation(t, "America/New_York")
+	f := feed{name: "agenda", location: location}
+	f.add(domain.AppointmentDTO{Appointment: domain.Appointment{Id: 1, DateAndTime: time.Date(2030, time.March, 14, 10, 0, 0, 0, location), DurationMinutes: 60}}, "consultation")
+
+	lines := unfold(f.encode(time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)))
+	var daylight []string
+	for i, line := range lines {
+		if line == "DTSTART:20300310T020000" {
+			daylight = lines[i-1 : i+5]
+		}
+	}
+	want := []string{"BEGIN:DAYLIGHT", "DTSTART:20300310T020000", "TZOFFSETFROM:-0500", "TZOFFSETTO:-0400", "TZNAME:EDT", "END:DAYLIGHT"}
+	if strings.Join(daylight, "\n") != strings.Join(want, "\n") {
+		t.Errorf("observance %q, want %q", daylight, want)
+	}
+}
+
+func TestWriteLineFolds(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("ã", 60)
+	var b bytes.Buffer
+	writeLine(&b, line)
+
+	folded := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	if len(folded) < 2 {
+		t.Fatalf("expected the line folded, got %q", b.String())
+	}
+	for i, l := range folded {
+		if len(l) > maxLineOctets {
+			t.Errorf("line %d has %d octets", i, len(l))
+		}
+		if !utf8.ValidString(l) {
+			t.Errorf("line %d splits a character: %q", i, l)
+		}
+		if i > 0 && !strings.HasPrefix(l, " ") {
+			t.Errorf("continuation line %d doesn't start with a space", i)
+		}
+	}
+	if got := unfold(b.Bytes())[0]; got != line {
+		t.Errorf("unfolded %q, want %q", got, line)
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	tests := map[int]string{
+		-3 * 3600:      "-0300",
+		0:              "+0000",
+		5*3600 + 1800:  "+0530",
+		-(3*3600 + 45): "-030045",
+	}
+	for seconds, want := range tests {
+		if got := formatOffset(seconds); got != want {
+			t.Errorf("formatOffset(%d) = %s, want %s", seconds, got, want)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	if got, want := escapeText("a\\b;c,d\r\ne\nf"), `a\\b\;c\,d\ne\nf`; got != want {
+		t.Errorf("escapeText = %s, want %s", got, want)
+	}
+}
+
+// TestDentistFeedToken - the feed is only served with the last token created
+func TestDentistFeedToken(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	dentist, err := m.Dentists().Save(ctx, domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: "L1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(NewRepository(m.Dentists(), m.Patients(), m.Appointments(), m.CalendarTokens()), time.UTC)
+
+	if _, err := s.DentistFeed(ctx, "L1", ""); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("without a token: expected ErrInvalidToken, got %v", err)
+	}
+	if _, err := s.DentistFeed(ctx, "L9", "abc"); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("unknown dentist: expected ErrInvalidToken, got %v", err)
+	}
+	if _, err := s.PatientFeed(ctx, "P9", "abc"); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("unknown patient: expected ErrInvalidToken, got %v", err)
+	}
+	first, err := s.NewDentistToken(ctx, dentist.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.URL != "/dentists/L1/calendar.ics?token="+first.Token {
+		t.Errorf("url %s", first.URL)
+	}
+	second, err := s.NewDentistToken(ctx, dentist.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DentistFeed(ctx, "L1", first.Token); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("with a replaced token: expected ErrInvalidToken, got %v", err)
+	}
+	data, err := s.DentistFeed(ctx, "L1", second.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasLine(unfold(data), "X-WR-CALNAME:Agenda - Dr. Ana Silva") {
+		t.Errorf("unexpected feed\n%s", data)
+	}
+}
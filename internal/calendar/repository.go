@@ -0,0 +1,77 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+type Repository interface {
+	GetDentist(ctx context.Context, dentistID int) (domain.Dentist, error)
+	GetDentistByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error)
+	GetPatient(ctx context.Context, patientID int) (domain.Patient, error)
+	GetPatientByIdentity(ctx context.Context, identityNumber string) (domain.Patient, error)
+	GetAllAppointmentsByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error)
+	GetAllAppointmentsByIdentityNumber(ctx context.Context, identityNumber string) ([]domain.AppointmentDTO, error)
+	GetToken(ctx context.Context, owner domain.CalendarOwner, ownerID int) (string, error)
+	SaveToken(ctx context.Context, owner domain.CalendarOwner, ownerID int, token string) error
+}
+
+type repository struct {
+	dentists     store.Repository[domain.Dentist]
+	patients     store.Repository[domain.Patient]
+	appointments store.ApStore
+	tokens       store.CalendarTokenStore
+}
+
+func NewRepository(dentists store.Repository[domain.Dentist], patients store.Repository[domain.Patient], appointments store.ApStore, tokens store.CalendarTokenStore) Repository {
+	return &repository{dentists, patients, appointments, tokens}
+}
+
+func (r *repository) GetDentist(ctx context.Context, dentistID int) (domain.Dentist, error) {
+	return r.dentists.GetByID(ctx, dentistID)
+}
+
+func (r *repository) GetDentistByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error) {
+	page, err := r.dentists.List(ctx, store.ListOptions{Limit: 1, Filters: map[string]string{"license_number": licenseNumber}})
+	if err != nil {
+		return domain.Dentist{}, err
+	}
+	if len(page.Items) == 0 {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %s", domain.ErrNotFound, licenseNumber)
+	}
+	return page.Items[0], nil
+}
+
+func (r *repository) GetPatient(ctx context.Context, patientID int) (domain.Patient, error) {
+	return r.patients.GetByID(ctx, patientID)
+}
+
+func (r *repository) GetPatientByIdentity(ctx context.Context, identityNumber string) (domain.Patient, error) {
+	page, err := r.patients.List(ctx, store.ListOptions{Limit: 1, Filters: map[string]string{"identity_number": identityNumber}})
+	if err != nil {
+		return domain.Patient{}, err
+	}
+	if len(page.Items) == 0 {
+		return domain.Patient{}, fmt.Errorf("%w: patient %s", domain.ErrNotFound, identityNumber)
+	}
+	return page.Items[0], nil
+}
+
+func (r *repository) GetAllAppointmentsByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error) {
+	return r.appointments.GetAllAppointmentsByDentistsLicense(ctx, licenseNumber)
+}
+
+func (r *repository) GetAllAppointmentsByIdentityNumber(ctx context.Context, identityNumber string) ([]domain.AppointmentDTO, error) {
+	return r.appointments.GetAllAppointmentsByPatientIdentify(ctx, identityNumber)
+}
+
+func (r *repository) GetToken(ctx context.Context, owner domain.CalendarOwner, ownerID int) (string, error) {
+	return r.tokens.GetCalendarToken(ctx, owner, ownerID)
+}
+
+func (r *repository) SaveToken(ctx context.Context, owner domain.CalendarOwner, ownerID int, token string) error {
+	return r.tokens.SaveCalendarToken(ctx, owner, ownerID, token)
+}
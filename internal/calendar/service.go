@@ -0,0 +1,124 @@
+package calendar
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+type Service interface {
+	DentistFeed(ctx context.Context, licenseNumber, token string) ([]byte, error)
+	PatientFeed(ctx context.Context, identityNumber, token string) ([]byte, error)
+	NewDentistToken(ctx context.Context, dentistID int) (domain.CalendarToken, error)
+	NewPatientToken(ctx context.Context, patientID int) (domain.CalendarToken, error)
+}
+
+type service struct {
+	r        Repository
+	location *time.Location
+}
+
+// NewService - location is the time zone of the clinic, in which the events are
+// written
+func NewService(r Repository, location *time.Location) Service {
+	return &service{r, location}
+}
+
+// DentistFeed - returns the agenda of the dentist as an iCalendar file. An unknown
+// dentist is answered as a wrong token, so the feeds don't tell which exist.
+func (s *service) DentistFeed(ctx context.Context, licenseNumber, token string) ([]byte, error) {
+	dentist, err := s.r.GetDentistByLicense(ctx, licenseNumber)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkToken(ctx, domain.CalendarDentist, dentist.Id, token); err != nil {
+		return nil, err
+	}
+	appointments, err := s.r.GetAllAppointmentsByLicenseNumber(ctx, licenseNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	f := feed{name: fmt.Sprintf("Agenda - Dr. %s %s", dentist.Name, dentist.Surname), location: s.location}
+	for _, a := range appointments {
+		f.add(a, fmt.Sprintf("%s - %s %s", a.ProcedureType, a.Patient.Name, a.Patient.Surname))
+	}
+	return f.encode(time.Now()), nil
+}
+
+// PatientFeed - returns the appointments of the patient as an iCalendar file. An
+// unknown patient is answered as a wrong token, so the feeds don't tell which exist.
+func (s *service) PatientFeed(ctx context.Context, identityNumber, token string) ([]byte, error) {
+	patient, err := s.r.GetPatientByIdentity(ctx, identityNumber)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkToken(ctx, domain.CalendarPatient, patient.Id, token); err != nil {
+		return nil, err
+	}
+	appointments, err := s.r.GetAllAppointmentsByIdentityNumber(ctx, identityNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	f := feed{name: fmt.Sprintf("Dental appointments - %s %s", patient.Name, patient.Surname), location: s.location}
+	for _, a := range appointments {
+		f.add(a, fmt.Sprintf("%s with Dr. %s %s", a.ProcedureType, a.Dentist.Name, a.Dentist.Surname))
+	}
+	return f.encode(time.Now()), nil
+}
+
+// NewDentistToken - creates the secret of the dentist feed, a previous one stops working
+func (s *service) NewDentistToken(ctx context.Context, dentistID int) (domain.CalendarToken, error) {
+	dentist, err := s.r.GetDentist(ctx, dentistID)
+	if err != nil {
+		return domain.CalendarToken{}, err
+	}
+	return s.newToken(ctx, domain.CalendarDentist, dentist.Id, "/dentists/"+url.PathEscape(dentist.LicenseNumber)+"/calendar.ics")
+}
+
+// NewPatientToken - creates the secret of the patient feed, a previous one stops working
+func (s *service) NewPatientToken(ctx context.Context, patientID int) (domain.CalendarToken, error) {
+	patient, err := s.r.GetPatient(ctx, patientID)
+	if err != nil {
+		return domain.CalendarToken{}, err
+	}
+	return s.newToken(ctx, domain.CalendarPatient, patient.Id, "/patients/"+url.PathEscape(patient.IdentityNumber)+"/calendar.ics")
+}
+
+func (s *service) newToken(ctx context.Context, owner domain.CalendarOwner, ownerID int, path string) (domain.CalendarToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return domain.CalendarToken{}, err
+	}
+	token := hex.EncodeToString(secret)
+	if err := s.r.SaveToken(ctx, owner, ownerID, token); err != nil {
+		return domain.CalendarToken{}, err
+	}
+	return domain.CalendarToken{Token: token, URL: path + "?token=" + token}, nil
+}
+
+// checkToken - compares in constant time so the token can't be guessed by timing
+func (s *service) checkToken(ctx context.Context, owner domain.CalendarOwner, ownerID int, token string) error {
+	stored, err := s.r.GetToken(ctx, owner, ownerID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+	if err != nil || token == "" || subtle.ConstantTimeCompare([]byte(stored), []byte(token)) != 1 {
+		return domain.ErrInvalidToken
+	}
+	return nil
+}
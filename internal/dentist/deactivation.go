@@ -0,0 +1,183 @@
+package dentist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/appointment"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/internal/schedule"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+// DeactivationService - the flow of a dentist leaving the clinic. A deactivated
+// dentist takes no new bookings, and its future appointments are reassigned to
+// other dentists or cancelled until none is left and it can be deleted.
+type DeactivationService interface {
+	Deactivate(ctx context.Context, id int) (domain.DentistDeactivation, error)
+	Reactivate(ctx context.Context, id int) (domain.Dentist, error)
+	GetFutureAppointments(ctx context.Context, id int) ([]domain.AppointmentDTO, error)
+	Reassign(ctx context.Context, id int, licenseNumber string, appointmentIDs []int) (domain.ReassignmentReport, error)
+	CancelAppointments(ctx context.Context, id int, reason string, appointmentIDs []int) (domain.ReassignmentReport, error)
+}
+
+type deactivationService struct {
+	r            Repository
+	appointments appointment.Service
+	schedules    schedule.Service
+}
+
+// NewDeactivationService - schedules is used to check the availability of the
+// dentists the appointments are reassigned to
+func NewDeactivationService(r Repository, appointments appointment.Service, schedules schedule.Service) DeactivationService {
+	return &deactivationService{r, appointments, schedules}
+}
+
+// Deactivate - returns the dentist along with the future appointments still to be
+// reassigned or cancelled, deactivating a deactivated dentist changes nothing
+func (s *deactivationService) Deactivate(ctx context.Context, id int) (domain.DentistDeactivation, error) {
+	dentist, err := s.r.GetByID(ctx, id)
+	if err != nil {
+		return domain.DentistDeactivation{}, err
+	}
+	if dentist.IsActive() {
+		if dentist, err = s.r.SetDeactivated(ctx, id, time.Now()); err != nil {
+			return domain.DentistDeactivation{}, err
+		}
+	}
+	future, err := s.futureAppointments(ctx, dentist.LicenseNumber)
+	if err != nil {
+		return domain.DentistDeactivation{}, err
+	}
+	return domain.DentistDeactivation{Dentist: dentist, Appointments: future}, nil
+}
+
+// Reactivate - the dentist takes new bookings again
+func (s *deactivationService) Reactivate(ctx context.Context, id int) (domain.Dentist, error) {
+	dentist, err := s.r.GetByID(ctx, id)
+	if err != nil || dentist.IsActive() {
+		return dentist, err
+	}
+	return s.r.SetDeactivated(ctx, id, time.Time{})
+}
+
+func (s *deactivationService) GetFutureAppointments(ctx context.Context, id int) ([]domain.AppointmentDTO, error) {
+	dentist, err := s.r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.futureAppointments(ctx, dentist.LicenseNumber)
+}
+
+// Reassign - moves the future appointments of the dentist, all of them when no id
+// is given, to the dentist with the license number. An appointment is moved only
+// if the other dentist is available and free at its time, and at the version it
+// was listed, the others are reported as conflicts.
+func (s *deactivationService) Reassign(ctx context.Context, id int, licenseNumber string, appointmentIDs []int) (domain.ReassignmentReport, error) {
+	dentist, err := s.r.GetByID(ctx, id)
+	if err != nil {
+		return domain.ReassignmentReport{}, err
+	}
+	target, err := s.r.GetByLicense(ctx, licenseNumber)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ReassignmentReport{}, domain.NewValidationError("dentist_license", "exists", "doesn't reference a dentist")
+	}
+	if err != nil {
+		return domain.ReassignmentReport{}, err
+	}
+	if target.Id == dentist.Id {
+		return domain.ReassignmentReport{}, domain.NewValidationError("dentist_license", "other", "must be another dentist")
+	}
+	if !target.IsActive() {
+		return domain.ReassignmentReport{}, fmt.Errorf("%w: dentist %s", domain.ErrDentistInactive, target.LicenseNumber)
+	}
+
+	return s.forEachAppointment(ctx, dentist, appointmentIDs, func(a domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+		start, end := a.Interval()
+		if err := s.schedules.CheckAvailability(ctx, target.LicenseNumber, start, end); err != nil {
+			return domain.AppointmentDTO{}, err
+		}
+		return s.appointments.Update(ctx, a.Id, domain.Appointment{DentistLicense: target.LicenseNumber, Version: a.Version})
+	})
+}
+
+// CancelAppointments - cancels the future appointments of the dentist, all of them
+// when no id is given, with the reason
+func (s *deactivationService) CancelAppointments(ctx context.Context, id int, reason string, appointmentIDs []int) (domain.ReassignmentReport, error) {
+	if reason == "" {
+		return domain.ReassignmentReport{}, domain.NewValidationError("reason", "required", "a reason is required to cancel an appointment")
+	}
+	dentist, err := s.r.GetByID(ctx, id)
+	if err != nil {
+		return domain.ReassignmentReport{}, err
+	}
+	return s.forEachAppointment(ctx, dentist, appointmentIDs, func(a domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+		return s.appointments.ChangeStatus(ctx, a.Id, domain.StatusCancelled, reason)
+	})
+}
+
+// forEachAppointment - runs fn over the future appointments of the dentist named
+// by appointmentIDs, or all of them, and reports the ones fn or the selection
+// failed for as conflicts
+func (s *deactivationService) forEachAppointment(ctx context.Context, dentist domain.Dentist, appointmentIDs []int, fn func(a domain.AppointmentDTO) (domain.AppointmentDTO, error)) (domain.ReassignmentReport, error) {
+	future, err := s.futureAppointments(ctx, dentist.LicenseNumber)
+	if err != nil {
+		return domain.ReassignmentReport{}, err
+	}
+	report := domain.ReassignmentReport{Appointments: []domain.AppointmentDTO{}, Conflicts: []domain.SeriesConflict{}}
+	selected := future
+	if len(appointmentIDs) > 0 {
+		byID := make(map[int]domain.AppointmentDTO, len(future))
+		for _, a := range future {
+			byID[a.Id] = a
+		}
+		selected = nil
+		for _, appointmentID := range appointmentIDs {
+			a, ok := byID[appointmentID]
+			if !ok {
+				report.Conflicts = append(report.Conflicts, domain.SeriesConflict{
+					AppointmentId: appointmentID,
+					Reason:        fmt.Sprintf("not a future appointment of dentist %s", dentist.LicenseNumber),
+				})
+				continue
+			}
+			selected = append(selected, a)
+		}
+	}
+
+	for _, a := range selected {
+		changed, err := fn(a)
+		if err != nil {
+			report.Conflicts = append(report.Conflicts, domain.SeriesConflict{
+				AppointmentId: a.Id,
+				DateAndTime:   a.DateAndTime,
+				Reason:        err.Error(),
+			})
+			continue
+		}
+		report.Appointments = append(report.Appointments, changed)
+	}
+	return report, nil
+}
+
+// futureAppointments - the appointments of the dentist from now on which haven't
+// reached a final status, ordered by date and time
+func (s *deactivationService) futureAppointments(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error) {
+	page, err := s.appointments.List(ctx, store.ListOptions{
+		Sort:    "date_and_time",
+		Filters: map[string]string{"dentist_license": licenseNumber},
+		From:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	future := []domain.AppointmentDTO{}
+	for _, a := range page.Items {
+		if !a.Status.IsFinal() {
+			future = append(future, a)
+		}
+	}
+	return future, nil
+}
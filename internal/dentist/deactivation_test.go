@@ -0,0 +1,43 @@
+package dentist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+// timingOut - the dentists of a memory store whose lookups by license fail with
+// the deadline of the request
+type timingOut struct {
+	Repository
+}
+
+func (timingOut) GetByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error) {
+	return domain.Dentist{}, context.DeadlineExceeded
+}
+
+// TestReassignTarget - only a license number nobody has is a violation, the
+// failures of the lookup are returned as they are
+func TestReassignTarget(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	dentist, err := m.Dentists().Save(ctx, domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: "L1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRepository(m.Dentists())
+
+	_, err = NewDeactivationService(r, nil, nil).Reassign(ctx, dentist.Id, "L9", nil)
+	var validation *domain.ValidationError
+	if !errors.As(err, &validation) || validation.Violations[0].Field != "dentist_license" {
+		t.Errorf("unknown dentist: expected a violation of dentist_license, got %v", err)
+	}
+
+	_, err = NewDeactivationService(timingOut{r}, nil, nil).Reassign(ctx, dentist.Id, "L2", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("failing lookup: expected the deadline, got %v", err)
+	}
+}
@@ -1,88 +1,86 @@
 package dentist
 
 import (
-	"errors"
+	"context"
+	"fmt"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
 	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
-	"log"
+	"time"
 )
 
-var table = "dentists"
-
 type Repository interface {
-	GetAll() (interface{}, error)
-	GetByID(id int) (interface{}, error)
-	Create(d domain.Dentist) (interface{}, error)
-	Update(id int, d domain.Dentist) (interface{}, error)
-	Delete(id int) error
+	GetAll(ctx context.Context) ([]domain.Dentist, error)
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Dentist], error)
+	GetByID(ctx context.Context, id int) (domain.Dentist, error)
+	Create(ctx context.Context, d domain.Dentist) (domain.Dentist, error)
+	Update(ctx context.Context, id int, d domain.Dentist) (domain.Dentist, error)
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (domain.Dentist, error)
+	GetByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error)
+	SetDeactivated(ctx context.Context, id int, at time.Time) (domain.Dentist, error)
 }
 
 type repository struct {
-	store store.Store
+	store store.DentistStore
 }
 
-func NewRepository(store store.Store) Repository {
+func NewRepository(store store.DentistStore) Repository {
 	return &repository{store}
 }
 
 // GetAll - returns all dentists at database
-func (r *repository) GetAll() (interface{}, error) {
-	return r.store.GetAll(table)
+func (r *repository) GetAll(ctx context.Context) ([]domain.Dentist, error) {
+	return r.store.GetAll(ctx)
 }
 
-func (r *repository) GetByID(id int) (interface{}, error) {
-	return r.store.GetByID(id, table)
+// List - returns a page of dentists filtered and sorted by opts
+func (r *repository) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Dentist], error) {
+	return r.store.List(ctx, opts)
 }
 
-func (r *repository) Create(d domain.Dentist) (interface{}, error) {
-	if !r.validateLicenseNumber(d.LicenseNumber) {
-		return nil, errors.New("license number already exists at database")
-	}
-	return r.store.Save(d, table)
+func (r *repository) GetByID(ctx context.Context, id int) (domain.Dentist, error) {
+	return r.store.GetByID(ctx, id)
+}
+
+// Create - the unique license number is checked by the store
+func (r *repository) Create(ctx context.Context, d domain.Dentist) (domain.Dentist, error) {
+	return r.store.Save(ctx, d)
 }
 
-func (r *repository) Update(id int, d domain.Dentist) (interface{}, error) {
-	var dentists []domain.Dentist
-	dentistsInterface, err := r.GetAll()
+// Update - a zero version is the stored one, the deactivation is kept
+func (r *repository) Update(ctx context.Context, id int, d domain.Dentist) (domain.Dentist, error) {
+	dentist, err := r.GetByID(ctx, id)
 	if err != nil {
-		log.Fatalln("erro while trying to fetch data from db")
-		return nil, err
+		return domain.Dentist{}, err
 	}
-	dentists, ok := dentistsInterface.([]domain.Dentist)
-	if !ok {
-		return nil, err
+	if d.Version == 0 {
+		d.Version = dentist.Version
 	}
+	d.DeactivatedAt = dentist.DeactivatedAt
+	return r.store.Update(ctx, id, d)
+}
 
-	for _, dentist := range dentists {
-		if dentist.Id == id {
-			if !r.validateLicenseNumber(d.LicenseNumber) && d.LicenseNumber != dentist.LicenseNumber {
-				return nil, errors.New("license number already exists")
-			}
-			return r.store.Update(id, d, table)
-		}
-	}
-	return nil, errors.New("dentist not found")
+func (r *repository) Delete(ctx context.Context, id int) error {
+	return r.store.Delete(ctx, id)
 }
 
-func (r *repository) Delete(id int) error {
-	return r.store.Delete(id, table)
+func (r *repository) Restore(ctx context.Context, id int) (domain.Dentist, error) {
+	return r.store.Restore(ctx, id)
 }
 
-func (r *repository) validateLicenseNumber(licenseNumber string) bool {
-	var dentists []domain.Dentist
-	dentistsInterface, err := r.GetAll()
+// GetByLicense - returns the dentist with the license number
+func (r *repository) GetByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error) {
+	page, err := r.store.List(ctx, store.ListOptions{Limit: 1, Filters: map[string]string{"license_number": licenseNumber}})
 	if err != nil {
-		return false
+		return domain.Dentist{}, err
 	}
-	dentists, ok := dentistsInterface.([]domain.Dentist)
-	if !ok {
-		return false
+	if len(page.Items) == 0 {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %s", domain.ErrNotFound, licenseNumber)
 	}
+	return page.Items[0], nil
+}
 
-	for _, dentist := range dentists {
-		if dentist.LicenseNumber == licenseNumber {
-			return false
-		}
-	}
-	return true
+// SetDeactivated - the zero time reactivates the dentist
+func (r *repository) SetDeactivated(ctx context.Context, id int, at time.Time) (domain.Dentist, error) {
+	return r.store.SetDeactivated(ctx, id, at)
 }
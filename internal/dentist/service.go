@@ -1,16 +1,19 @@
 package dentist
 
 import (
-	"errors"
+	"context"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
 )
 
 type Service interface {
-	GetAll() ([]domain.Dentist, error)
-	GetByID(id int) (interface{}, error)
-	Create(d domain.Dentist) (domain.Dentist, error)
-	Update(id int, d domain.Dentist) (domain.Dentist, error)
-	Delete(id int) error
+	GetAll(ctx context.Context) ([]domain.Dentist, error)
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Dentist], error)
+	GetByID(ctx context.Context, id int) (domain.Dentist, error)
+	Create(ctx context.Context, d domain.Dentist) (domain.Dentist, error)
+	Update(ctx context.Context, id int, d domain.Dentist) (domain.Dentist, error)
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (domain.Dentist, error)
 }
 
 type service struct {
@@ -21,58 +24,31 @@ func NewService(r Repository) Service {
 	return &service{r}
 }
 
-func (s *service) GetAll() ([]domain.Dentist, error) {
-	list, err := s.r.GetAll()
-	if err != nil {
-		return nil, err
-	}
-
-	dentists, ok := list.([]domain.Dentist)
-	if !ok {
-		return nil, errors.New("not found a dentist with id provided")
-	}
-	return dentists, nil
+func (s *service) GetAll(ctx context.Context) ([]domain.Dentist, error) {
+	return s.r.GetAll(ctx)
 }
 
-func (s *service) GetByID(id int) (interface{}, error) {
-	dInterface, err := s.r.GetByID(id)
-	if err != nil {
-		return nil, err
-	}
-	dentist, ok := dInterface.(domain.Dentist)
-	if !ok {
-		return dentist, errors.New("an error occurred while trying to fetch data from db")
-	}
-	return dentist, nil
+func (s *service) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Dentist], error) {
+	return s.r.List(ctx, opts)
 }
 
-func (s *service) Create(d domain.Dentist) (domain.Dentist, error) {
-	dSavedInterface, err := s.r.Create(d)
-	if err != nil {
-		return domain.Dentist{}, err
-	}
-
-	dentistSaved, ok := dSavedInterface.(domain.Dentist)
-	if ok {
-		return dentistSaved, nil
-	}
+func (s *service) GetByID(ctx context.Context, id int) (domain.Dentist, error) {
+	return s.r.GetByID(ctx, id)
+}
 
-	return domain.Dentist{}, errors.New("failed to save a new dentist at db")
+func (s *service) Create(ctx context.Context, d domain.Dentist) (domain.Dentist, error) {
+	return s.r.Create(ctx, d)
 }
 
-func (s *service) Update(id int, d domain.Dentist) (domain.Dentist, error) {
-	dUpdatedInterface, err := s.r.Update(id, d)
-	if err != nil {
-		return domain.Dentist{}, err
-	}
-	dentistUpdated, ok := dUpdatedInterface.(domain.Dentist)
-	if ok {
-		return dentistUpdated, nil
-	}
+func (s *service) Update(ctx context.Context, id int, d domain.Dentist) (domain.Dentist, error) {
+	return s.r.Update(ctx, id, d)
+}
 
-	return domain.Dentist{}, errors.New("failed to update the dentist")
+func (s *service) Delete(ctx context.Context, id int) error {
+	return s.r.Delete(ctx, id)
 }
 
-func (s *service) Delete(id int) error {
-	return s.r.Delete(id)
+// Restore - undoes the deletion of the dentist
+func (s *service) Restore(ctx context.Context, id int) (domain.Dentist, error) {
+	return s.r.Restore(ctx, id)
 }
@@ -1,9 +1,70 @@
 package domain
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Appointment struct {
-	Id              int    `json:"id"`
-	Description     string `json:"description" binding:"required"`
-	DateAndTime     string `json:"date_and_time" binding:"required"`
-	DentistLicense  string `json:"dentist_license" binding:"required"`
-	PatientIdentity string `json:"patient_identity" binding:"required"`
+	Id              int               `json:"id"`
+	Description     string            `json:"description" binding:"required"`
+	DateAndTime     time.Time         `json:"date_and_time" binding:"required"`
+	DentistLicense  string            `json:"dentist_license" binding:"required"`
+	PatientIdentity string            `json:"patient_identity" binding:"required"`
+	Status          AppointmentStatus `json:"status"`
+	ProcedureType   ProcedureType     `json:"procedure_type"`
+	DurationMinutes int               `json:"duration_minutes" binding:"min=0"`
+	EndDateAndTime  time.Time         `json:"end_date_and_time"`
+	SeriesId        int               `json:"series_id,omitempty"`
+	// Version - incremented by every update and status change, sent as the ETag of
+	// the appointment
+	Version int `json:"version"`
+	// DeletedAt - when the appointment was deleted, zero while it's active
+	DeletedAt time.Time `json:"-"`
+}
+
+// Interval - returns when the appointment starts and ends
+func (a Appointment) Interval() (time.Time, time.Time) {
+	return a.DateAndTime, a.DateAndTime.Add(time.Duration(a.DurationMinutes) * time.Minute)
+}
+
+// appointmentFields - the fields of an appointment without its JSON methods
+type appointmentFields Appointment
+
+// appointmentJSON - an appointment with its dates as they are sent
+type appointmentJSON struct {
+	appointmentFields
+	DateAndTime    string `json:"date_and_time"`
+	EndDateAndTime string `json:"end_date_and_time"`
+	DeletedAt      string `json:"deleted_at,omitempty"`
+}
+
+func (a Appointment) toJSON() appointmentJSON {
+	return appointmentJSON{
+		appointmentFields: appointmentFields(a),
+		DateAndTime:       FormatDateTime(a.DateAndTime),
+		EndDateAndTime:    FormatDateTime(a.EndDateAndTime),
+		DeletedAt:         FormatDateTime(a.DeletedAt),
+	}
+}
+
+// MarshalJSON - the dates are written in RFC 3339 at the clinic zone
+func (a Appointment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.toJSON())
+}
+
+// UnmarshalJSON - date_and_time is read with ParseDateTime, end_date_and_time is
+// computed and deleted_at set by Delete, both are ignored
+func (a *Appointment) UnmarshalJSON(data []byte) error {
+	var aux appointmentJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	dateAndTime, err := dateTimeField("date_and_time", aux.DateAndTime)
+	if err != nil {
+		return err
+	}
+	*a = Appointment(aux.appointmentFields)
+	a.DateAndTime = dateAndTime
+	return nil
 }
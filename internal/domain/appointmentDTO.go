@@ -1,7 +1,31 @@
 package domain
 
+import "encoding/json"
+
 type AppointmentDTO struct {
 	Appointment
 	Dentist Dentist `json:"dentist" binding:"required"`
 	Patient Patient `json:"patient" binding:"required"`
 }
+
+// MarshalJSON - needed as the one of Appointment would be promoted and leave the
+// dentist and the patient out
+func (a AppointmentDTO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		appointmentJSON
+		Dentist Dentist `json:"dentist"`
+		Patient Patient `json:"patient"`
+	}{a.Appointment.toJSON(), a.Dentist, a.Patient})
+}
+
+// UnmarshalJSON - reads the appointment and then its dentist and patient
+func (a *AppointmentDTO) UnmarshalJSON(data []byte) error {
+	if err := a.Appointment.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	aux := struct {
+		Dentist *Dentist `json:"dentist"`
+		Patient *Patient `json:"patient"`
+	}{&a.Dentist, &a.Patient}
+	return json.Unmarshal(data, &aux)
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AppointmentStatus - the stage of an appointment lifecycle
+type AppointmentStatus string
+
+const (
+	StatusScheduled  AppointmentStatus = "scheduled"
+	StatusConfirmed  AppointmentStatus = "confirmed"
+	StatusCheckedIn  AppointmentStatus = "checked_in"
+	StatusInProgress AppointmentStatus = "in_progress"
+	StatusCompleted  AppointmentStatus = "completed"
+	StatusCancelled  AppointmentStatus = "cancelled"
+	StatusNoShow     AppointmentStatus = "no_show"
+)
+
+// appointmentTransitions - the statuses each status can move to, completed,
+// cancelled and no_show are final
+var appointmentTransitions = map[AppointmentStatus][]AppointmentStatus{
+	StatusScheduled:  {StatusConfirmed, StatusCheckedIn, StatusCancelled, StatusNoShow},
+	StatusConfirmed:  {StatusCheckedIn, StatusCancelled, StatusNoShow},
+	StatusCheckedIn:  {StatusInProgress, StatusCancelled},
+	StatusInProgress: {StatusCompleted},
+}
+
+// CanTransitionTo - tells if the lifecycle allows moving from s to next
+func (s AppointmentStatus) CanTransitionTo(next AppointmentStatus) bool {
+	for _, allowed := range appointmentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal - tells if no transition leaves s
+func (s AppointmentStatus) IsFinal() bool {
+	return len(appointmentTransitions[s]) == 0
+}
+
+// IsActive - tells if an appointment at s still holds its time, cancelled and
+// no_show appointments free the dentist and the patient
+func (s AppointmentStatus) IsActive() bool {
+	return s != StatusCancelled && s != StatusNoShow
+}
+
+// StatusChange - an entry of an appointment status history, From is empty
+// for the change which created the appointment
+type StatusChange struct {
+	Id            int               `json:"id"`
+	AppointmentId int               `json:"appointment_id"`
+	From          AppointmentStatus `json:"from,omitempty"`
+	To            AppointmentStatus `json:"to"`
+	Reason        string            `json:"reason,omitempty"`
+	ChangedAt     time.Time         `json:"changed_at"`
+}
+
+// MarshalJSON - changed_at is written in RFC 3339 at the clinic zone
+func (c StatusChange) MarshalJSON() ([]byte, error) {
+	type statusChangeFields StatusChange
+	return json.Marshal(struct {
+		statusChangeFields
+		ChangedAt string `json:"changed_at"`
+	}{statusChangeFields(c), FormatDateTime(c.ChangedAt)})
+}
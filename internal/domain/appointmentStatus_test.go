@@ -0,0 +1,46 @@
+package domain
+
+import "testing"
+
+// TestAppointmentTransitions - every pair of statuses against the lifecycle
+func TestAppointmentTransitions(t *testing.T) {
+	statuses := []AppointmentStatus{StatusScheduled, StatusConfirmed, StatusCheckedIn, StatusInProgress, StatusCompleted, StatusCancelled, StatusNoShow}
+	allowed := map[AppointmentStatus]map[AppointmentStatus]bool{
+		StatusScheduled:  {StatusConfirmed: true, StatusCheckedIn: true, StatusCancelled: true, StatusNoShow: true},
+		StatusConfirmed:  {StatusCheckedIn: true, StatusCancelled: true, StatusNoShow: true},
+		StatusCheckedIn:  {StatusInProgress: true, StatusCancelled: true},
+		StatusInProgress: {StatusCompleted: true},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got := from.CanTransitionTo(to); got != allowed[from][to] {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, allowed[from][to])
+			}
+		}
+	}
+}
+
+func TestAppointmentStatusFinalAndActive(t *testing.T) {
+	tests := []struct {
+		status AppointmentStatus
+		final  bool
+		active bool
+	}{
+		{StatusScheduled, false, true},
+		{StatusConfirmed, false, true},
+		{StatusCheckedIn, false, true},
+		{StatusInProgress, false, true},
+		{StatusCompleted, true, true},
+		{StatusCancelled, true, false},
+		{StatusNoShow, true, false},
+	}
+	for _, tt := range tests {
+		if got := tt.status.IsFinal(); got != tt.final {
+			t.Errorf("%s.IsFinal() = %v, want %v", tt.status, got, tt.final)
+		}
+		if got := tt.status.IsActive(); got != tt.active {
+			t.Errorf("%s.IsActive() = %v, want %v", tt.status, got, tt.active)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// The entities recorded in the audit log, named as their tables
+const (
+	AuditDentists     = "dentists"
+	AuditPatients     = "patients"
+	AuditAppointments = "appointments"
+)
+
+// AuditOperation - the kind of change an audit entry records
+type AuditOperation string
+
+const (
+	AuditCreate       AuditOperation = "create"
+	AuditUpdate       AuditOperation = "update"
+	AuditDelete       AuditOperation = "delete"
+	AuditRestore      AuditOperation = "restore"
+	AuditChangeStatus AuditOperation = "change_status"
+	AuditDeactivate   AuditOperation = "deactivate"
+	AuditReactivate   AuditOperation = "reactivate"
+)
+
+// SystemActor - the actor of the changes made by no user, such as the ones of the
+// background jobs
+const SystemActor = "system"
+
+// FieldChange - the value of a field before and after a change, null when the
+// entity didn't exist before or doesn't after
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry - who changed an entity, when and how. Changes holds the fields
+// whose JSON differs before and after the operation.
+type AuditEntry struct {
+	Id        int                    `json:"id"`
+	ActorId   int                    `json:"actor_id,omitempty"`
+	Actor     string                 `json:"actor"`
+	At        time.Time              `json:"at"`
+	Entity    string                 `json:"entity"`
+	EntityId  int                    `json:"entity_id"`
+	Operation AuditOperation         `json:"operation"`
+	Changes   map[string]FieldChange `json:"changes"`
+}
+
+// MarshalJSON - at is written in RFC 3339 at the clinic zone
+func (e AuditEntry) MarshalJSON() ([]byte, error) {
+	type auditEntryFields AuditEntry
+	return json.Marshal(struct {
+		auditEntryFields
+		At string `json:"at"`
+	}{auditEntryFields(e), FormatDateTime(e.At)})
+}
+
+// NewAuditEntry - the entry of an operation made now by the principal of ctx, the
+// changes are the fields of the JSON of before and after which differ, either of
+// them nil when the entity didn't exist before or doesn't after
+func NewAuditEntry(ctx context.Context, entity string, entityID int, operation AuditOperation, before, after interface{}) (AuditEntry, error) {
+	entry := AuditEntry{Actor: SystemActor, At: time.Now(), Entity: entity, EntityId: entityID, Operation: operation}
+	if p, ok := PrincipalFromContext(ctx); ok {
+		entry.ActorId, entry.Actor = p.UserId, p.Email
+	}
+
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	entry.Changes = map[string]FieldChange{}
+	for field, value := range beforeFields {
+		if string(value) != string(afterFields[field]) {
+			entry.Changes[field] = FieldChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			entry.Changes[field] = FieldChange{After: value}
+		}
+	}
+	return entry, nil
+}
+
+// jsonFields - the fields of the JSON object of v, none for nil
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
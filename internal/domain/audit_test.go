@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// changedFields - the changes of an entry as JSON text, so they compare as strings
+func changedFields(t *testing.T, entry AuditEntry) map[string][2]string {
+	t.Helper()
+	fields := map[string][2]string{}
+	for field, change := range entry.Changes {
+		before, err := json.Marshal(change.Before)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := json.Marshal(change.After)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields[field] = [2]string{string(before), string(after)}
+	}
+	return fields
+}
+
+func TestNewAuditEntryUpdate(t *testing.T) {
+	before := Dentist{Id: 1, Surname: "Silva", Name: "Ana", LicenseNumber: "L1", Version: 1}
+	after := before
+	after.Surname, after.Version = "Souza", 2
+
+	entry, err := NewAuditEntry(context.Background(), AuditDentists, 1, AuditUpdate, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2]string{
+		"surname": {`"Silva"`, `"Souza"`},
+		"version": {"1", "2"},
+	}
+	got := changedFields(t, entry)
+	if len(got) != len(want) {
+		t.Fatalf("changes %v, want %v", got, want)
+	}
+	for field, change := range want {
+		if got[field] != change {
+			t.Errorf("%s changed %v, want %v", field, got[field], change)
+		}
+	}
+	if entry.Actor != SystemActor || entry.ActorId != 0 {
+		t.Errorf("actor %d %q, want the system", entry.ActorId, entry.Actor)
+	}
+}
+
+func TestNewAuditEntryCreateAndDelete(t *testing.T) {
+	dentist := Dentist{Id: 1, Surname: "Silva", Name: "Ana", LicenseNumber: "L1", Version: 1}
+	ctx := ContextWithPrincipal(context.Background(), Principal{UserId: 7, Email: "admin@clinic.com", Role: RoleAdmin})
+
+	created, err := NewAuditEntry(ctx, AuditDentists, 1, AuditCreate, nil, dentist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, change := range changedFields(t, created) {
+		if change[0] != "null" {
+			t.Errorf("%s was %s before the creation, want null", field, change[0])
+		}
+	}
+	if got := changedFields(t, created)["license_number"]; got[1] != `"L1"` {
+		t.Errorf("license_number after the creation = %s", got[1])
+	}
+	if created.Actor != "admin@clinic.com" || created.ActorId != 7 {
+		t.Errorf("actor %d %q, want the principal of the context", created.ActorId, created.Actor)
+	}
+
+	deleted, err := NewAuditEntry(ctx, AuditDentists, 1, AuditDelete, dentist, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted.Changes) != len(created.Changes) {
+		t.Errorf("the deletion changed %d fields, the creation %d", len(deleted.Changes), len(created.Changes))
+	}
+	for field, change := range changedFields(t, deleted) {
+		if change[1] != "null" {
+			t.Errorf("%s is %s after the deletion, want null", field, change[1])
+		}
+	}
+}
+
+func TestNewAuditEntryNoChange(t *testing.T) {
+	dentist := Dentist{Id: 1, Surname: "Silva", Name: "Ana", LicenseNumber: "L1", Version: 1}
+	entry, err := NewAuditEntry(context.Background(), AuditDentists, 1, AuditUpdate, dentist, dentist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Changes) != 0 {
+		t.Errorf("changes %v, want none", entry.Changes)
+	}
+}
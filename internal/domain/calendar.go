@@ -0,0 +1,15 @@
+package domain
+
+// CalendarOwner - whose agenda a calendar feed shows
+type CalendarOwner string
+
+const (
+	CalendarDentist CalendarOwner = "dentist"
+	CalendarPatient CalendarOwner = "patient"
+)
+
+// CalendarToken - the secret of a calendar feed and the URL to subscribe to it
+type CalendarToken struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
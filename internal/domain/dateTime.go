@@ -0,0 +1,103 @@
+package domain
+
+import "time"
+
+// DateTimeLayout - legacy format of dates and times, still accepted as input and
+// read in the clinic zone, e.g. 30/01/2023 23:59. Dates and times are answered
+// in RFC 3339.
+const DateTimeLayout = "02/01/2006 15:04"
+
+// ISODateLayout - format of the dates without time of day, e.g. 2023-01-30
+const ISODateLayout = "2006-01-02"
+
+// ClockLayout - format of the times of day of the dentists schedules, e.g. 08:30
+const ClockLayout = "15:04"
+
+// DateTimeFormats and DateFormats - the formats accepted, as reported to clients
+const (
+	DateTimeFormats = "2023-01-30T23:59:00-03:00 or 30/01/2023 23:59"
+	DateFormats     = "2023-01-30 or 30/01/2023"
+)
+
+// legacyDateTimeLayouts - the legacy formats, with and without seconds
+var legacyDateTimeLayouts = []string{DateTimeLayout, "02/01/2006 15:04:05"}
+
+// clinicLocation - the zone of the clinic wall clock, see SetClinicLocation
+var clinicLocation = time.UTC
+
+// SetClinicLocation - sets the IANA zone of the clinic, in which the dates without
+// offset are read and every date is answered. It's called once at startup.
+func SetClinicLocation(location *time.Location) {
+	clinicLocation = location
+}
+
+// ClinicLocation - the zone set by SetClinicLocation, UTC by default
+func ClinicLocation() *time.Location {
+	return clinicLocation
+}
+
+// ParseDateTime - reads an RFC 3339 date and time, or one in the legacy format
+// which is taken as the clinic wall clock
+func ParseDateTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range legacyDateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, clinicLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidDate
+}
+
+// ParseDate - reads an ISO 8601 date or one in DateLayout, at the start of the
+// day in the clinic zone
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range []string{ISODateLayout, DateLayout} {
+		if t, err := time.ParseInLocation(layout, value, clinicLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidDate
+}
+
+// FormatDateTime - formats t in RFC 3339 at the clinic zone, the zero time is empty
+func FormatDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(clinicLocation).Format(time.RFC3339)
+}
+
+// FormatDate - formats the day of t in the clinic zone, the zero time is empty
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(clinicLocation).Format(ISODateLayout)
+}
+
+// dateTimeField - parses the date and time of a JSON field, an empty value is
+// the zero time so the binding rules decide whether it's required
+func dateTimeField(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := ParseDateTime(value)
+	if err != nil {
+		return time.Time{}, InvalidDate(field, DateTimeFormats)
+	}
+	return t, nil
+}
+
+// dateField - parses the date of a JSON field, see dateTimeField
+func dateField(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := ParseDate(value)
+	if err != nil {
+		return time.Time{}, InvalidDate(field, DateFormats)
+	}
+	return t, nil
+}
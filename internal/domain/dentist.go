@@ -1,8 +1,55 @@
 package domain
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Dentist struct {
 	Id            int    `json:"id"`
 	Surname       string `json:"surname" binding:"required"`
 	Name          string `json:"name" binding:"required"`
 	LicenseNumber string `json:"license_number" binding:"required"`
+	// Version - incremented by every update, sent as the ETag of the dentist
+	Version int `json:"version"`
+	// DeletedAt - when the dentist was deleted, zero while it's active
+	DeletedAt time.Time `json:"-"`
+	// DeactivatedAt - when the dentist stopped taking new bookings, zero while it
+	// takes them
+	DeactivatedAt time.Time `json:"-"`
+}
+
+// dentistFields - the fields of a dentist without its JSON methods
+type dentistFields Dentist
+
+// dentistJSON - a dentist with deleted_at and deactivated_at as it's sent
+type dentistJSON struct {
+	dentistFields
+	DeletedAt     string `json:"deleted_at,omitempty"`
+	DeactivatedAt string `json:"deactivated_at,omitempty"`
+}
+
+// IsActive - reports whether the dentist takes new bookings
+func (d Dentist) IsActive() bool {
+	return d.DeactivatedAt.IsZero()
+}
+
+// MarshalJSON - deleted_at and deactivated_at are written in RFC 3339 at the
+// clinic zone, and only when they are set
+func (d Dentist) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dentistJSON{dentistFields(d), FormatDateTime(d.DeletedAt), FormatDateTime(d.DeactivatedAt)})
+}
+
+// DentistDeactivation - a deactivated dentist and the future appointments still
+// booked with it, which are to be reassigned or cancelled
+type DentistDeactivation struct {
+	Dentist      Dentist          `json:"dentist"`
+	Appointments []AppointmentDTO `json:"appointments"`
+}
+
+// ReassignmentReport - the outcome of reassigning or cancelling the appointments
+// of a deactivated dentist, the ones left out are reported as conflicts
+type ReassignmentReport struct {
+	Appointments []AppointmentDTO `json:"appointments"`
+	Conflicts    []SeriesConflict `json:"conflicts"`
 }
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// The errors of the domain, the layers below wrap them with the details of the
+// failure, e.g. fmt.Errorf("%w: dentist 3", ErrNotFound), and pkg/web maps them to
+// the HTTP status through their type
+var (
+	ErrNotFound          error = &NotFoundError{Code: "not_found", Message: "not found"}
+	ErrDuplicateLicense  error = &ConflictError{Code: "duplicate_license", Message: "license number already exists"}
+	ErrDuplicateIdentity error = &ConflictError{Code: "duplicate_identity", Message: "identity number already exists"}
+	ErrDuplicateEmail    error = &ConflictError{Code: "duplicate_email", Message: "email already exists"}
+	// ErrInUse - the entity can't be deleted or changed while others reference it
+	ErrInUse error = &ConflictError{Code: "in_use", Message: "referenced by other records"}
+	// ErrSlotUnavailable - the period is out of the dentist availability or overlaps
+	// another appointment of the dentist or the patient
+	ErrSlotUnavailable error = &ConflictError{Code: "slot_unavailable", Message: "the time slot isn't available"}
+	// ErrInvalidTransition - the appointment lifecycle doesn't allow the status requested
+	ErrInvalidTransition error = &ConflictError{Code: "invalid_status_transition", Message: "invalid appointment status transition"}
+	// ErrDentistInactive - the dentist was deactivated and takes no new bookings
+	ErrDentistInactive error = &ConflictError{Code: "dentist_inactive", Message: "the dentist doesn't take new bookings"}
+	// ErrConcurrentUpdate - the entity was changed by another request meanwhile
+	ErrConcurrentUpdate error = &ConflictError{Code: "concurrent_update", Message: "changed by another request, please try again"}
+	// ErrVersionMismatch - the version the update was based on isn't the current one
+	ErrVersionMismatch error = &PreconditionError{Code: "precondition_failed", Message: "changed since it was read, please read it again"}
+	// ErrVersionRequired - an update must name the version it's based on
+	ErrVersionRequired error = &PreconditionError{Code: "precondition_required", Message: "the If-Match header is required"}
+	// ErrNotInSeries - a scope other than this was used for an appointment out of a series
+	ErrNotInSeries error = NewValidationError("scope", "series", "the appointment isn't part of a series")
+	// ErrInvalidDate - a date or date and time which can't be parsed, see InvalidDate
+	ErrInvalidDate              = errors.New("invalid date")
+	ErrInvalidCredentials error = &UnauthorizedError{Code: "invalid_credentials", Message: "invalid email or password"}
+	// ErrInvalidToken - an access, refresh or calendar token badly signed, expired,
+	// of the wrong kind or revoked
+	ErrInvalidToken error = &UnauthorizedError{Code: "invalid_token", Message: "invalid or expired token"}
+)
+
+// Violation - an invalid field of a request, Rule names the rule it breaks such as
+// required or oneof so clients can highlight the input
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// NotFoundError - the resource requested doesn't exist. Code is a stable, machine
+// readable identifier of the error.
+type NotFoundError struct {
+	Code    string
+	Message string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Message
+}
+
+// ConflictError - the request conflicts with the current state, such as a duplicate
+// or an overlapping entry
+type ConflictError struct {
+	Code    string
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// PreconditionError - the request is conditional on a version of the entity which
+// is missing or isn't the current one
+type PreconditionError struct {
+	Code    string
+	Message string
+}
+
+func (e *PreconditionError) Error() string {
+	return e.Message
+}
+
+// UnauthorizedError - the credentials or the token of the request aren't valid
+type UnauthorizedError struct {
+	Code    string
+	Message string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Message
+}
+
+// ValidationError - the request has invalid fields, every one of them is reported
+// as a violation. Err is the cause, such as ErrInvalidDate.
+type ValidationError struct {
+	Violations []Violation
+	Err        error
+}
+
+// InvalidDate - a validation error of field, which must be in format
+func InvalidDate(field, format string) error {
+	return &ValidationError{
+		Violations: []Violation{{Field: field, Rule: "datetime", Message: "must be in format: " + format}},
+		Err:        ErrInvalidDate,
+	}
+}
+
+// NewValidationError - a validation error of a single field
+func NewValidationError(field, rule, message string) *ValidationError {
+	return &ValidationError{Violations: []Violation{{Field: field, Rule: rule, Message: message}}}
+}
+
+// Add - reports one more invalid field
+func (e *ValidationError) Add(field, rule, message string) {
+	e.Violations = append(e.Violations, Violation{Field: field, Rule: rule, Message: message})
+}
+
+// OrNil - returns nil when no violation was reported, so a validation can build the
+// error while it checks the fields
+func (e *ValidationError) OrNil() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Field+": "+v.Message)
+	}
+	return strings.Join(messages, "; ")
+}
@@ -1,9 +1,49 @@
 package domain
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Patient struct {
-	Id             int    `json:"id"`
-	Surname        string `json:"surname" binding:"required"`
-	Name           string `json:"name" binding:"required"`
-	IdentityNumber string `json:"identity_number" binding:"required"`
-	CreatedAt      string `json:"created_at" binding:"required"`
+	Id             int       `json:"id"`
+	Surname        string    `json:"surname" binding:"required"`
+	Name           string    `json:"name" binding:"required"`
+	IdentityNumber string    `json:"identity_number" binding:"required"`
+	CreatedAt      time.Time `json:"created_at" binding:"required"`
+	// Version - incremented by every update, sent as the ETag of the patient
+	Version int `json:"version"`
+	// DeletedAt - when the patient was deleted, zero while it's active
+	DeletedAt time.Time `json:"-"`
+}
+
+// patientFields - the fields of a patient without its JSON methods
+type patientFields Patient
+
+// patientJSON - a patient with its dates as they are sent
+type patientJSON struct {
+	patientFields
+	CreatedAt string `json:"created_at"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+// MarshalJSON - the dates are written in RFC 3339 at the clinic zone, deleted_at
+// only for deleted patients
+func (p Patient) MarshalJSON() ([]byte, error) {
+	return json.Marshal(patientJSON{patientFields(p), FormatDateTime(p.CreatedAt), FormatDateTime(p.DeletedAt)})
+}
+
+// UnmarshalJSON - created_at is read with ParseDateTime, deleted_at is ignored
+func (p *Patient) UnmarshalJSON(data []byte) error {
+	var aux patientJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	createdAt, err := dateTimeField("created_at", aux.CreatedAt)
+	if err != nil {
+		return err
+	}
+	*p = Patient(aux.patientFields)
+	p.CreatedAt = createdAt
+	return nil
 }
@@ -0,0 +1,41 @@
+package domain
+
+// ProcedureType - the kind of treatment an appointment is booked for, it sets
+// the default duration of the appointment
+type ProcedureType string
+
+const (
+	ProcedureConsultation ProcedureType = "consultation"
+	ProcedureCleaning     ProcedureType = "cleaning"
+	ProcedureFilling      ProcedureType = "filling"
+	ProcedureExtraction   ProcedureType = "extraction"
+	ProcedureRootCanal    ProcedureType = "root_canal"
+	ProcedureOrthodontic  ProcedureType = "orthodontic_adjustment"
+	ProcedureWhitening    ProcedureType = "whitening"
+)
+
+// procedureDurations - the default duration of each procedure, in minutes
+var procedureDurations = map[ProcedureType]int{
+	ProcedureConsultation: 60,
+	ProcedureCleaning:     45,
+	ProcedureFilling:      60,
+	ProcedureExtraction:   60,
+	ProcedureRootCanal:    90,
+	ProcedureOrthodontic:  30,
+	ProcedureWhitening:    90,
+}
+
+// MaxAppointmentDuration - the longest appointment accepted, in minutes
+const MaxAppointmentDuration = 8 * 60
+
+// IsValid - tells if p is one of the known procedures
+func (p ProcedureType) IsValid() bool {
+	_, ok := procedureDurations[p]
+	return ok
+}
+
+// DefaultDuration - the duration, in minutes, used when an appointment of p
+// doesn't provide one
+func (p ProcedureType) DefaultDuration() int {
+	return procedureDurations[p]
+}
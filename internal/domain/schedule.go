@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WeeklyInterval - a period of a weekday, Weekday goes from 0 (sunday) to 6 (saturday)
+type WeeklyInterval struct {
+	Weekday int    `json:"weekday" binding:"min=0,max=6"`
+	Start   string `json:"start" binding:"required"`
+	End     string `json:"end" binding:"required"`
+}
+
+// Schedule - the weekly template of a dentist: the periods attended and the breaks inside them
+type Schedule struct {
+	DentistId    int              `json:"dentist_id"`
+	WorkingHours []WeeklyInterval `json:"working_hours" binding:"dive"`
+	Breaks       []WeeklyInterval `json:"breaks" binding:"dive"`
+}
+
+// TimeOff - a period in which the dentist doesn't attend, such as vacations
+type TimeOff struct {
+	Id        int       `json:"id"`
+	DentistId int       `json:"dentist_id"`
+	From      time.Time `json:"from" binding:"required"`
+	To        time.Time `json:"to" binding:"required"`
+	Reason    string    `json:"reason"`
+}
+
+// timeOffFields - the fields of a time off without its JSON methods
+type timeOffFields TimeOff
+
+// timeOffJSON - a time off with its dates as they are sent
+type timeOffJSON struct {
+	timeOffFields
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MarshalJSON - the dates are written in RFC 3339 at the clinic zone
+func (t TimeOff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timeOffJSON{timeOffFields(t), FormatDateTime(t.From), FormatDateTime(t.To)})
+}
+
+// UnmarshalJSON - from and to are read with ParseDateTime
+func (t *TimeOff) UnmarshalJSON(data []byte) error {
+	var aux timeOffJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	from, err := dateTimeField("from", aux.From)
+	if err != nil {
+		return err
+	}
+	to, err := dateTimeField("to", aux.To)
+	if err != nil {
+		return err
+	}
+	*t = TimeOff(aux.timeOffFields)
+	t.From, t.To = from, to
+	return nil
+}
+
+// Slot - a free period of a dentist agenda
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// MarshalJSON - the dates are written in RFC 3339 at the clinic zone
+func (s Slot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}{FormatDateTime(s.Start), FormatDateTime(s.End)})
+}
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Frequency - how often the occurrences of a series repeat
+type Frequency string
+
+const (
+	FrequencyWeekly  Frequency = "weekly"
+	FrequencyMonthly Frequency = "monthly"
+)
+
+// DateLayout - legacy format of the dates without time of day, e.g. 30/01/2023
+const DateLayout = "02/01/2006"
+
+// MaxOccurrences - the largest series accepted, two years of weekly appointments
+const MaxOccurrences = 104
+
+// Recurrence - an RRULE-like rule: the occurrences repeat every Interval weeks or
+// months until Count occurrences were generated or the Until date (inclusive),
+// which is the start of the day in the clinic zone
+type Recurrence struct {
+	Frequency Frequency `json:"frequency" binding:"required,oneof=weekly monthly"`
+	Interval  int       `json:"interval" binding:"min=0"`
+	Count     int       `json:"count,omitempty" binding:"min=0"`
+	Until     time.Time `json:"until,omitempty"`
+}
+
+// recurrenceFields - the fields of a recurrence without its JSON methods
+type recurrenceFields Recurrence
+
+// recurrenceJSON - a recurrence with until as it's sent
+type recurrenceJSON struct {
+	recurrenceFields
+	Until string `json:"until,omitempty"`
+}
+
+// MarshalJSON - until is written as an ISO 8601 date
+func (r Recurrence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(recurrenceJSON{recurrenceFields(r), FormatDate(r.Until)})
+}
+
+// UnmarshalJSON - until is read with ParseDate
+func (r *Recurrence) UnmarshalJSON(data []byte) error {
+	var aux recurrenceJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	until, err := dateField("recurrence.until", aux.Until)
+	if err != nil {
+		return err
+	}
+	*r = Recurrence(aux.recurrenceFields)
+	r.Until = until
+	return nil
+}
+
+// AppointmentSeries - a group of appointments generated from the same recurrence
+type AppointmentSeries struct {
+	Id         int        `json:"id"`
+	Recurrence Recurrence `json:"recurrence"`
+}
+
+// SeriesScope - which occurrences of a series an edit or a cancellation reaches
+type SeriesScope string
+
+const (
+	ScopeThis      SeriesScope = "this"
+	ScopeFollowing SeriesScope = "following"
+	ScopeAll       SeriesScope = "all"
+)
+
+// SeriesConflict - an occurrence which couldn't be booked or changed and why
+type SeriesConflict struct {
+	AppointmentId int       `json:"appointment_id,omitempty"`
+	DateAndTime   time.Time `json:"date_and_time"`
+	Reason        string    `json:"reason"`
+}
+
+// MarshalJSON - date_and_time is written in RFC 3339 at the clinic zone
+func (c SeriesConflict) MarshalJSON() ([]byte, error) {
+	type seriesConflictFields SeriesConflict
+	return json.Marshal(struct {
+		seriesConflictFields
+		DateAndTime string `json:"date_and_time,omitempty"`
+	}{seriesConflictFields(c), FormatDateTime(c.DateAndTime)})
+}
+
+// SeriesReport - the outcome of an operation over several occurrences of a series
+type SeriesReport struct {
+	SeriesId     int              `json:"series_id,omitempty"`
+	Appointments []AppointmentDTO `json:"appointments"`
+	Conflicts    []SeriesConflict `json:"conflicts"`
+}
+
+// Occurrences - returns the start of every occurrence of the rule, the first one
+// being start. Monthly occurrences falling on a day the month doesn't have are
+// skipped, as RRULE does. The occurrences keep the wall clock of start at the
+// clinic zone across changes of offset.
+func (r Recurrence) Occurrences(start time.Time) ([]time.Time, error) {
+	start = start.In(clinicLocation)
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	var until time.Time
+	switch {
+	case interval < 0:
+		return nil, NewValidationError("recurrence.interval", "min", "must be positive")
+	case (r.Count == 0) == r.Until.IsZero():
+		return nil, NewValidationError("recurrence", "required", "needs either count or until")
+	case r.Count > MaxOccurrences:
+		return nil, NewValidationError("recurrence.count", "max", "a series can't have more than 104 occurrences")
+	case !r.Until.IsZero():
+		until = r.Until.AddDate(0, 0, 1)
+	}
+
+	var occurrences []time.Time
+	for i := 0; r.Count == 0 || len(occurrences) < r.Count; i++ {
+		var next time.Time
+		if r.Frequency == FrequencyMonthly {
+			next = start.AddDate(0, i*interval, 0)
+			if next.Day() != start.Day() {
+				continue
+			}
+		} else {
+			next = start.AddDate(0, 0, 7*i*interval)
+		}
+		if !until.IsZero() && !next.Before(until) {
+			break
+		}
+		if len(occurrences) == MaxOccurrences {
+			return nil, NewValidationError("recurrence.until", "max", "a series can't have more than 104 occurrences")
+		}
+		occurrences = append(occurrences, next)
+	}
+	return occurrences, nil
+}
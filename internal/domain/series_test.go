@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// useClinicLocation - sets the clinic zone for the test, UTC is restored after it
+func useClinicLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetClinicLocation(location)
+	t.Cleanup(func() { SetClinicLocation(time.UTC) })
+	return location
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	location := useClinicLocation(t, "America/New_York")
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, location)
+	}
+
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		start      time.Time
+		want       []time.Time
+	}{
+		{
+			name:       "weekly by count",
+			recurrence: Recurrence{Frequency: FrequencyWeekly, Count: 3},
+			start:      at(2030, time.January, 10, 10),
+			want:       []time.Time{at(2030, time.January, 10, 10), at(2030, time.January, 17, 10), at(2030, time.January, 24, 10)},
+		},
+		{
+			name:       "every other week until a date, inclusive",
+			recurrence: Recurrence{Frequency: FrequencyWeekly, Interval: 2, Until: at(2030, time.February, 7, 0)},
+			start:      at(2030, time.January, 10, 10),
+			want:       []time.Time{at(2030, time.January, 10, 10), at(2030, time.January, 24, 10), at(2030, time.February, 7, 10)},
+		},
+		{
+			name:       "monthly skips the months without the day",
+			recurrence: Recurrence{Frequency: FrequencyMonthly, Count: 3},
+			start:      at(2030, time.January, 31, 9),
+			want:       []time.Time{at(2030, time.January, 31, 9), at(2030, time.March, 31, 9), at(2030, time.May, 31, 9)},
+		},
+		{
+			// daylight saving time starts on 2030-03-10 in New York
+			name:       "the wall clock is kept across a change of offset",
+			recurrence: Recurrence{Frequency: FrequencyWeekly, Count: 2},
+			start:      at(2030, time.March, 7, 10),
+			want:       []time.Time{at(2030, time.March, 7, 10), at(2030, time.March, 14, 10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.recurrence.Occurrences(tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d occurrences %v, want %v", len(got), got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) || got[i].Hour() != tt.want[i].Hour() {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecurrenceOccurrencesInvalid(t *testing.T) {
+	start := time.Date(2030, time.January, 10, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		field      string
+	}{
+		{"neither count nor until", Recurrence{Frequency: FrequencyWeekly}, "recurrence"},
+		{"both count and until", Recurrence{Frequency: FrequencyWeekly, Count: 2, Until: start.AddDate(0, 1, 0)}, "recurrence"},
+		{"negative interval", Recurrence{Frequency: FrequencyWeekly, Interval: -1, Count: 2}, "recurrence.interval"},
+		{"too many by count", Recurrence{Frequency: FrequencyWeekly, Count: MaxOccurrences + 1}, "recurrence.count"},
+		{"too many by until", Recurrence{Frequency: FrequencyWeekly, Until: start.AddDate(3, 0, 0)}, "recurrence.until"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.recurrence.Occurrences(start)
+			var validation *ValidationError
+			if !errors.As(err, &validation) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if field := validation.Violations[0].Field; field != tt.field {
+				t.Errorf("violation of %s, want %s", field, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package domain
+
+import "context"
+
+// Role - what a user is allowed to do
+type Role string
+
+const (
+	RoleAdmin        Role = "admin"
+	RoleReceptionist Role = "receptionist"
+	RoleDentist      Role = "dentist"
+	RolePatient      Role = "patient"
+)
+
+// IsValid - tells if r is one of the known roles
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleAdmin, RoleReceptionist, RoleDentist, RolePatient:
+		return true
+	}
+	return false
+}
+
+// User - an account of the API, dentists and patients users are linked to their
+// dentist or patient row
+type User struct {
+	Id           int    `json:"id"`
+	Email        string `json:"email" binding:"required,email"`
+	PasswordHash string `json:"-"`
+	Role         Role   `json:"role" binding:"required,oneof=admin receptionist dentist patient"`
+	DentistId    int    `json:"dentist_id,omitempty"`
+	PatientId    int    `json:"patient_id,omitempty"`
+}
+
+// Credentials - the body of the login
+type Credentials struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// TokenPair - the tokens returned by the login and the refresh, ExpiresIn is the
+// lifetime of the access token in seconds
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Principal - the authenticated user of a request. Dentists and patients carry
+// both the id and the number their appointments reference.
+type Principal struct {
+	UserId          int    `json:"user_id"`
+	Email           string `json:"email"`
+	Role            Role   `json:"role"`
+	DentistId       int    `json:"dentist_id,omitempty"`
+	DentistLicense  string `json:"dentist_license,omitempty"`
+	PatientId       int    `json:"patient_id,omitempty"`
+	PatientIdentity string `json:"patient_identity,omitempty"`
+}
+
+// HasRole - tells if the principal has one of roles
+func (p Principal) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess - dentists only reach their own appointments and patients the ones
+// they attend, the other roles reach every appointment
+func (p Principal) CanAccess(a Appointment) bool {
+	switch p.Role {
+	case RoleDentist:
+		return a.DentistLicense == p.DentistLicense
+	case RolePatient:
+		return a.PatientIdentity == p.PatientIdentity
+	}
+	return true
+}
+
+// principalKey - the key of the principal in a context
+type principalKey struct{}
+
+// ContextWithPrincipal - returns ctx carrying the principal the request runs for,
+// so the layers below the handlers know who acts
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext - returns the principal of ctx, false when it runs for no one
+// such as the background jobs
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
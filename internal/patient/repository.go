@@ -1,92 +1,57 @@
 package patient
 
 import (
-	"errors"
+	"context"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
 	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
-	"log"
 )
 
-var table = "patients"
-
 type Repository interface {
-	GetAll() (interface{}, error)
-	GetByID(id int) (interface{}, error)
-	Create(p domain.Patient) (interface{}, error)
-	Update(id int, p domain.Patient) (interface{}, error)
-	Delete(id int) error
+	GetAll(ctx context.Context) ([]domain.Patient, error)
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Patient], error)
+	GetByID(ctx context.Context, id int) (domain.Patient, error)
+	Create(ctx context.Context, p domain.Patient) (domain.Patient, error)
+	Update(ctx context.Context, id int, p domain.Patient) (domain.Patient, error)
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (domain.Patient, error)
 }
 
 type repository struct {
-	store store.Store
+	store store.Repository[domain.Patient]
 }
 
-func NewRepository(store store.Store) Repository {
+func NewRepository(store store.Repository[domain.Patient]) Repository {
 	return &repository{store}
 }
 
 // GetAll - returns all patients at database
-func (r *repository) GetAll() (interface{}, error) {
-	return r.store.GetAll(table)
+func (r *repository) GetAll(ctx context.Context) ([]domain.Patient, error) {
+	return r.store.GetAll(ctx)
 }
 
-func (r *repository) GetByID(id int) (interface{}, error) {
-	return r.store.GetByID(id, table)
+// List - returns a page of patients filtered and sorted by opts
+func (r *repository) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Patient], error) {
+	return r.store.List(ctx, opts)
 }
 
-func (r *repository) Create(p domain.Patient) (interface{}, error) {
-	if !r.validateIdentificationNumber(p.IdentityNumber) {
-		return nil, errors.New("license number already exists at database")
-	}
-	return r.store.Save(p, table)
+func (r *repository) GetByID(ctx context.Context, id int) (domain.Patient, error) {
+	return r.store.GetByID(ctx, id)
 }
 
-func (r *repository) Update(id int, p domain.Patient) (interface{}, error) {
-
-	pInterface, err := r.GetAll()
-	if err != nil {
-		log.Fatalln("error while trying to fetch data from db while update a patient")
-		return nil, err
-	}
-	patients, ok := pInterface.([]domain.Patient)
-	if !ok {
-		log.Fatalln("error while trying to fetch data from db while updating a patient")
-		return nil, err
-	}
-
-	for _, patient := range patients {
-		if patient.Id == id {
-			if !r.validateIdentificationNumber(p.IdentityNumber) && p.IdentityNumber != p.IdentityNumber {
-				return nil, errors.New("there's a patient with same identity number")
-			}
-			return r.store.Update(id, p, table)
-		}
-	}
-	return nil, errors.New("patient not found")
+// Create - the unique identity number is checked by the store
+func (r *repository) Create(ctx context.Context, p domain.Patient) (domain.Patient, error) {
+	return r.store.Save(ctx, p)
 }
 
-func (r *repository) Delete(id int) error {
-	return r.store.Delete(id, table)
+// Update - the store answers the missing patient and the duplicate identity number
+func (r *repository) Update(ctx context.Context, id int, p domain.Patient) (domain.Patient, error) {
+	return r.store.Update(ctx, id, p)
 }
 
-func (r *repository) validateIdentificationNumber(identityNumber string) bool {
-	var patients []domain.Patient
-	patientsInterface, err := r.GetAll()
-	if err != nil {
-		log.Fatalln("erro while trying to fetch data from db")
-		return false
-	}
-	patients, ok := patientsInterface.([]domain.Patient)
-	if !ok {
-		log.Fatalln("error while trying to fetch data from db")
-		return false
-	}
-
-	for _, patient := range patients {
-		if patient.IdentityNumber == identityNumber {
-			return false
-		}
-	}
+func (r *repository) Delete(ctx context.Context, id int) error {
+	return r.store.Delete(ctx, id)
+}
 
-	return true
+func (r *repository) Restore(ctx context.Context, id int) (domain.Patient, error) {
+	return r.store.Restore(ctx, id)
 }
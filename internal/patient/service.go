@@ -1,16 +1,19 @@
 package patient
 
 import (
-	"errors"
+	"context"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
 )
 
 type Service interface {
-	GetAll() ([]domain.Patient, error)
-	GetByID(id int) (domain.Patient, error)
-	Create(p domain.Patient) (domain.Patient, error)
-	Update(id int, p domain.Patient) (domain.Patient, error)
-	Delete(id int) error
+	GetAll(ctx context.Context) ([]domain.Patient, error)
+	List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Patient], error)
+	GetByID(ctx context.Context, id int) (domain.Patient, error)
+	Create(ctx context.Context, p domain.Patient) (domain.Patient, error)
+	Update(ctx context.Context, id int, p domain.Patient) (domain.Patient, error)
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (domain.Patient, error)
 }
 
 type service struct {
@@ -21,46 +24,26 @@ func NewService(r Repository) Service {
 	return &service{r}
 }
 
-func (s *service) GetAll() ([]domain.Patient, error) {
-	list, err := s.r.GetAll()
-	if err != nil {
-		return nil, err
-	}
-	patients, ok := list.([]domain.Patient)
-	if !ok {
-		return nil, errors.New("an error occurred while trying to fetch data from db")
-	}
-	return patients, nil
+func (s *service) GetAll(ctx context.Context) ([]domain.Patient, error) {
+	return s.r.GetAll(ctx)
 }
 
-func (s *service) GetByID(id int) (domain.Patient, error) {
-	pInterface, err := s.r.GetByID(id)
-	if err != nil {
-		return domain.Patient{}, err
-	}
-	patient, ok := pInterface.(domain.Patient)
-	if !ok {
-		return patient, errors.New("not found a patient with provided id")
-	}
-	return patient, nil
+func (s *service) List(ctx context.Context, opts store.ListOptions) (store.Page[domain.Patient], error) {
+	return s.r.List(ctx, opts)
 }
 
-func (s *service) Create(p domain.Patient) (domain.Patient, error) {
-	pSavedInterface, err := s.r.Create(p)
-	if err != nil {
-		return domain.Patient{}, err
-	}
-
-	patientSaved, ok := pSavedInterface.(domain.Patient)
-	if ok {
-		return patientSaved, nil
-	}
+func (s *service) GetByID(ctx context.Context, id int) (domain.Patient, error) {
+	return s.r.GetByID(ctx, id)
+}
 
-	return domain.Patient{}, errors.New("failed to save a new patient at db")
+func (s *service) Create(ctx context.Context, p domain.Patient) (domain.Patient, error) {
+	return s.r.Create(ctx, p)
 }
 
-func (s *service) Update(id int, p domain.Patient) (domain.Patient, error) {
-	pdb, err := s.GetByID(id)
+// Update - fills the empty fields of p with the stored ones, a zero version is the
+// stored one
+func (s *service) Update(ctx context.Context, id int, p domain.Patient) (domain.Patient, error) {
+	pdb, err := s.GetByID(ctx, id)
 	if err != nil {
 		return domain.Patient{}, err
 	}
@@ -74,22 +57,21 @@ func (s *service) Update(id int, p domain.Patient) (domain.Patient, error) {
 	if p.IdentityNumber == "" {
 		p.IdentityNumber = pdb.IdentityNumber
 	}
-	if p.CreatedAt == "" {
+	if p.CreatedAt.IsZero() {
 		p.CreatedAt = pdb.CreatedAt
 	}
-	p.Id = pdb.Id
-	pUpdated, err := s.r.Update(id, p)
-	if err != nil {
-		return domain.Patient{}, err
-	}
-	patientUpdated, ok := pUpdated.(domain.Patient)
-	if ok {
-		return patientUpdated, nil
+	if p.Version == 0 {
+		p.Version = pdb.Version
 	}
+	p.Id = pdb.Id
+	return s.r.Update(ctx, id, p)
+}
 
-	return domain.Patient{}, errors.New("failed to update the patient")
+func (s *service) Delete(ctx context.Context, id int) error {
+	return s.r.Delete(ctx, id)
 }
 
-func (s *service) Delete(id int) error {
-	return s.r.Delete(id)
+// Restore - undoes the deletion of the patient
+func (s *service) Restore(ctx context.Context, id int) (domain.Patient, error) {
+	return s.r.Restore(ctx, id)
 }
@@ -0,0 +1,72 @@
+package purge
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger - removes for good the records deleted before a time, see store.Repository
+type Purger interface {
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+// Job - removes the dentists, patients and appointments deleted longer than the
+// retention ago, every interval
+type Job struct {
+	retention time.Duration
+	interval  time.Duration
+	targets   []target
+	logger    *slog.Logger
+}
+
+type target struct {
+	name   string
+	purger Purger
+}
+
+// NewJob - the appointments are purged first, so the dentists and patients they
+// referenced are purged in the same run
+func NewJob(retention, interval time.Duration, appointments, patients, dentists Purger, logger *slog.Logger) *Job {
+	return &Job{
+		retention: retention,
+		interval:  interval,
+		targets: []target{
+			{"appointments", appointments},
+			{"patients", patients},
+			{"dentists", dentists},
+		},
+		logger: logger,
+	}
+}
+
+// Run - purges at once and then every interval until ctx is done
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if err := j.Purge(ctx); err != nil {
+			j.logger.ErrorContext(ctx, "purging deleted records failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge - removes the records deleted before the retention
+func (j *Job) Purge(ctx context.Context) error {
+	before := time.Now().Add(-j.retention)
+	for _, t := range j.targets {
+		count, err := t.purger.Purge(ctx, before)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			j.logger.InfoContext(ctx, "purged deleted records", "entity", t.name, "count", count)
+		}
+	}
+	return nil
+}
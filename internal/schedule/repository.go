@@ -0,0 +1,69 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+type Repository interface {
+	GetSchedule(ctx context.Context, dentistID int) (domain.Schedule, error)
+	SaveSchedule(ctx context.Context, s domain.Schedule) (domain.Schedule, error)
+	GetAllTimeOff(ctx context.Context, dentistID int) ([]domain.TimeOff, error)
+	CreateTimeOff(ctx context.Context, t domain.TimeOff) (domain.TimeOff, error)
+	DeleteTimeOff(ctx context.Context, dentistID, timeOffID int) error
+	GetDentist(ctx context.Context, dentistID int) (domain.Dentist, error)
+	GetDentistByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error)
+	GetAllAppointmentsByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error)
+}
+
+type repository struct {
+	schedules    store.ScheduleStore
+	dentists     store.Repository[domain.Dentist]
+	appointments store.ApStore
+}
+
+func NewRepository(schedules store.ScheduleStore, dentists store.Repository[domain.Dentist], appointments store.ApStore) Repository {
+	return &repository{schedules, dentists, appointments}
+}
+
+func (r *repository) GetSchedule(ctx context.Context, dentistID int) (domain.Schedule, error) {
+	return r.schedules.GetSchedule(ctx, dentistID)
+}
+
+func (r *repository) SaveSchedule(ctx context.Context, s domain.Schedule) (domain.Schedule, error) {
+	return r.schedules.SaveSchedule(ctx, s)
+}
+
+func (r *repository) GetAllTimeOff(ctx context.Context, dentistID int) ([]domain.TimeOff, error) {
+	return r.schedules.GetAllTimeOff(ctx, dentistID)
+}
+
+func (r *repository) CreateTimeOff(ctx context.Context, t domain.TimeOff) (domain.TimeOff, error) {
+	return r.schedules.SaveTimeOff(ctx, t)
+}
+
+func (r *repository) DeleteTimeOff(ctx context.Context, dentistID, timeOffID int) error {
+	return r.schedules.DeleteTimeOff(ctx, dentistID, timeOffID)
+}
+
+func (r *repository) GetDentist(ctx context.Context, dentistID int) (domain.Dentist, error) {
+	return r.dentists.GetByID(ctx, dentistID)
+}
+
+func (r *repository) GetDentistByLicense(ctx context.Context, licenseNumber string) (domain.Dentist, error) {
+	page, err := r.dentists.List(ctx, store.ListOptions{Limit: 1, Filters: map[string]string{"license_number": licenseNumber}})
+	if err != nil {
+		return domain.Dentist{}, err
+	}
+	if len(page.Items) == 0 {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %s", domain.ErrNotFound, licenseNumber)
+	}
+	return page.Items[0], nil
+}
+
+func (r *repository) GetAllAppointmentsByLicenseNumber(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error) {
+	return r.appointments.GetAllAppointmentsByDentistsLicense(ctx, licenseNumber)
+}
@@ -0,0 +1,249 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// maxAvailabilityRange - the longest period accepted by GetAvailability
+const maxAvailabilityRange = 31 * 24 * time.Hour
+
+type Service interface {
+	GetSchedule(ctx context.Context, dentistID int) (domain.Schedule, error)
+	UpdateSchedule(ctx context.Context, s domain.Schedule) (domain.Schedule, error)
+	GetAllTimeOff(ctx context.Context, dentistID int) ([]domain.TimeOff, error)
+	CreateTimeOff(ctx context.Context, t domain.TimeOff) (domain.TimeOff, error)
+	DeleteTimeOff(ctx context.Context, dentistID, timeOffID int) error
+	GetAvailability(ctx context.Context, dentistID int, from, to time.Time, duration time.Duration) ([]domain.Slot, error)
+	CheckAvailability(ctx context.Context, licenseNumber string, start, end time.Time) error
+	// WithRepository - the service reading and writing through r, such as the
+	// repository of a unit of work
+	WithRepository(r Repository) Service
+}
+
+type service struct {
+	r        Repository
+	location *time.Location
+}
+
+// NewService - location is the time zone of the clinic, in which the working hours
+// and breaks are kept
+func NewService(r Repository, location *time.Location) Service {
+	return &service{r, location}
+}
+
+func (s *service) WithRepository(r Repository) Service {
+	return &service{r, s.location}
+}
+
+func (s *service) GetSchedule(ctx context.Context, dentistID int) (domain.Schedule, error) {
+	if _, err := s.r.GetDentist(ctx, dentistID); err != nil {
+		return domain.Schedule{}, err
+	}
+	return s.r.GetSchedule(ctx, dentistID)
+}
+
+func (s *service) UpdateSchedule(ctx context.Context, schedule domain.Schedule) (domain.Schedule, error) {
+	if _, err := s.r.GetDentist(ctx, schedule.DentistId); err != nil {
+		return domain.Schedule{}, err
+	}
+	if err := validateIntervals("working_hours", schedule.WorkingHours); err != nil {
+		return domain.Schedule{}, err
+	}
+	if err := validateIntervals("breaks", schedule.Breaks); err != nil {
+		return domain.Schedule{}, err
+	}
+	return s.r.SaveSchedule(ctx, schedule)
+}
+
+func (s *service) GetAllTimeOff(ctx context.Context, dentistID int) ([]domain.TimeOff, error) {
+	if _, err := s.r.GetDentist(ctx, dentistID); err != nil {
+		return nil, err
+	}
+	return s.r.GetAllTimeOff(ctx, dentistID)
+}
+
+func (s *service) CreateTimeOff(ctx context.Context, t domain.TimeOff) (domain.TimeOff, error) {
+	if _, err := s.r.GetDentist(ctx, t.DentistId); err != nil {
+		return domain.TimeOff{}, err
+	}
+	if !t.From.Before(t.To) {
+		return domain.TimeOff{}, domain.NewValidationError("to", "after", "the time off must end after it starts")
+	}
+	return s.r.CreateTimeOff(ctx, t)
+}
+
+func (s *service) DeleteTimeOff(ctx context.Context, dentistID, timeOffID int) error {
+	return s.r.DeleteTimeOff(ctx, dentistID, timeOffID)
+}
+
+// GetAvailability - returns the free slots of duration between from and to, built from
+// the dentist working hours minus breaks, time off and appointments already booked.
+// A deactivated dentist takes no new bookings, so it has none.
+func (s *service) GetAvailability(ctx context.Context, dentistID int, from, to time.Time, duration time.Duration) ([]domain.Slot, error) {
+	switch {
+	case !from.Before(to):
+		return nil, domain.NewValidationError("to", "after", "from must be before to")
+	case to.Sub(from) > maxAvailabilityRange:
+		return nil, domain.NewValidationError("to", "max", "the period can't be longer than 31 days")
+	case duration <= 0:
+		return nil, domain.NewValidationError("duration", "min", "duration must be positive")
+	}
+
+	dentist, err := s.r.GetDentist(ctx, dentistID)
+	if err != nil {
+		return nil, err
+	}
+	if !dentist.IsActive() {
+		return []domain.Slot{}, nil
+	}
+	schedule, err := s.r.GetSchedule(ctx, dentistID)
+	if err != nil {
+		return nil, err
+	}
+	allTimeOff, err := s.r.GetAllTimeOff(ctx, dentistID)
+	if err != nil {
+		return nil, err
+	}
+	appointments, err := s.r.GetAllAppointmentsByLicenseNumber(ctx, dentist.LicenseNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var busy []period
+	for _, t := range allTimeOff {
+		busy = append(busy, period{t.From, t.To})
+	}
+	for _, a := range appointments {
+		if !a.Status.IsActive() {
+			continue
+		}
+		start, end := a.Interval()
+		busy = append(busy, period{start, end})
+	}
+
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+	slots := []domain.Slot{}
+	for day := startOfDay(from.In(s.location)); day.Before(to); day = day.AddDate(0, 0, 1) {
+		dayBusy := append(weeklyPeriods(day, schedule.Breaks), busy...)
+		for _, working := range weeklyPeriods(day, schedule.WorkingHours) {
+			for start := working.start; !start.Add(duration).After(working.end); start = start.Add(duration) {
+				slot := period{start, start.Add(duration)}
+				if slot.start.Before(from) || slot.end.After(to) || slot.overlapsAny(dayBusy) {
+					continue
+				}
+				slots = append(slots, domain.Slot{Start: slot.start, End: slot.end})
+			}
+		}
+	}
+	return slots, nil
+}
+
+// CheckAvailability - returns domain.ErrSlotUnavailable, wrapped with the reason, when the period
+// isn't inside the dentist working hours or falls on a break or time off. A dentist
+// without working hours isn't bound by them. Overlaps with other appointments are
+// checked by the store.
+func (s *service) CheckAvailability(ctx context.Context, licenseNumber string, start, end time.Time) error {
+	dentist, err := s.r.GetDentistByLicense(ctx, licenseNumber)
+	if err != nil {
+		return err
+	}
+	schedule, err := s.r.GetSchedule(ctx, dentist.Id)
+	if err != nil {
+		return err
+	}
+	allTimeOff, err := s.r.GetAllTimeOff(ctx, dentist.Id)
+	if err != nil {
+		return err
+	}
+
+	p := period{start, end}
+	day := startOfDay(start.In(s.location))
+	inside := len(schedule.WorkingHours) == 0
+	for _, working := range weeklyPeriods(day, schedule.WorkingHours) {
+		if !p.start.Before(working.start) && !p.end.After(working.end) {
+			inside = true
+		}
+	}
+	if !inside {
+		return fmt.Errorf("%w: outside the working hours", domain.ErrSlotUnavailable)
+	}
+	if p.overlapsAny(weeklyPeriods(day, schedule.Breaks)) {
+		return fmt.Errorf("%w: during a break", domain.ErrSlotUnavailable)
+	}
+	for _, t := range allTimeOff {
+		if p.overlapsAny([]period{{t.From, t.To}}) {
+			return fmt.Errorf("%w: on time off", domain.ErrSlotUnavailable)
+		}
+	}
+	return nil
+}
+
+// period - a half-open interval [start, end)
+type period struct {
+	start time.Time
+	end   time.Time
+}
+
+func (p period) overlapsAny(others []period) bool {
+	for _, other := range others {
+		if p.start.Before(other.end) && other.start.Before(p.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// weeklyPeriods - returns the intervals of the template which happen at day, read
+// at the wall clock of its location so days with a change of offset are right
+func weeklyPeriods(day time.Time, intervals []domain.WeeklyInterval) []period {
+	var periods []period
+	for _, interval := range intervals {
+		if time.Weekday(interval.Weekday) != day.Weekday() {
+			continue
+		}
+		start, errStart := time.Parse(domain.ClockLayout, interval.Start)
+		end, errEnd := time.Parse(domain.ClockLayout, interval.End)
+		if errStart != nil || errEnd != nil {
+			continue
+		}
+		periods = append(periods, period{
+			start: time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, day.Location()),
+			end:   time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, day.Location()),
+		})
+	}
+	return periods
+}
+
+func validateIntervals(field string, intervals []domain.WeeklyInterval) error {
+	var periods []period
+	for _, interval := range intervals {
+		start, err := time.Parse(domain.ClockLayout, interval.Start)
+		if err != nil {
+			return domain.NewValidationError(field, "clock", fmt.Sprintf("start must be in format 23:59: %s", interval.Start))
+		}
+		end, err := time.Parse(domain.ClockLayout, interval.End)
+		if err != nil {
+			return domain.NewValidationError(field, "clock", fmt.Sprintf("end must be in format 23:59: %s", interval.End))
+		}
+		if !start.Before(end) {
+			return domain.NewValidationError(field, "after", fmt.Sprintf("must end after they start: %s-%s", interval.Start, interval.End))
+		}
+		// weekdays are placed in different days so only the same weekday can overlap
+		p := period{start.AddDate(0, 0, interval.Weekday), end.AddDate(0, 0, interval.Weekday)}
+		if p.overlapsAny(periods) {
+			return domain.NewValidationError(field, "overlap", fmt.Sprintf("can't overlap: weekday %d %s-%s", interval.Weekday, interval.Start, interval.End))
+		}
+		periods = append(periods, p)
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
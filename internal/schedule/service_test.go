@@ -0,0 +1,228 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/store"
+)
+
+// thursday - 2030-01-10 at hour:minute in UTC, the clinic zone of the tests
+func thursday(hour, minute int) time.Time {
+	return time.Date(2030, time.January, 10, hour, minute, 0, 0, time.UTC)
+}
+
+type fixture struct {
+	store   store.Store
+	ap      store.ApStore
+	service Service
+	dentist domain.Dentist
+}
+
+// newFixture - a dentist working on thursdays from 08:00 to 12:00 with a break at
+// 10:00, and a patient, in a memory store
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	dentist, err := m.Dentists().Save(ctx, domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: "L1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Patients().Save(ctx, domain.Patient{Surname: "Lima", Name: "João", IdentityNumber: "P1", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Schedules().SaveSchedule(ctx, domain.Schedule{
+		DentistId:    dentist.Id,
+		WorkingHours: []domain.WeeklyInterval{{Weekday: 4, Start: "08:00", End: "12:00"}},
+		Breaks:       []domain.WeeklyInterval{{Weekday: 4, Start: "10:00", End: "10:30"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ap := m.Appointments()
+	return fixture{
+		store:   m,
+		ap:      ap,
+		service: NewService(NewRepository(m.Schedules(), m.Dentists(), ap), time.UTC),
+		dentist: dentist,
+	}
+}
+
+// book - stores an appointment of the patient with the dentist at start
+func (f fixture) book(t *testing.T, start time.Time, minutes int, status domain.AppointmentStatus) {
+	t.Helper()
+	_, err := f.ap.Save(context.Background(), domain.AppointmentDTO{Appointment: domain.Appointment{
+		Description:     "checkup",
+		DateAndTime:     start,
+		DentistLicense:  f.dentist.LicenseNumber,
+		PatientIdentity: "P1",
+		Status:          status,
+		ProcedureType:   domain.ProcedureConsultation,
+		DurationMinutes: minutes,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func slotStarts(slots []domain.Slot) []time.Time {
+	starts := make([]time.Time, 0, len(slots))
+	for _, slot := range slots {
+		starts = append(starts, slot.Start)
+	}
+	return starts
+}
+
+func assertStarts(t *testing.T, slots []domain.Slot, want ...time.Time) {
+	t.Helper()
+	got := slotStarts(slots)
+	if len(got) != len(want) {
+		t.Fatalf("slots starting at %v, want %v", got, want)
+	}
+	for i := range got {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("slot %d starts at %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestGetAvailability - the working hours minus the break, the appointments still
+// active and the time off
+func TestGetAvailability(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	from, to := thursday(0, 0), thursday(23, 59)
+
+	slots, err := f.service.GetAvailability(ctx, f.dentist.Id, from, to, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStarts(t, slots, thursday(8, 0), thursday(9, 0), thursday(11, 0))
+
+	slots, err = f.service.GetAvailability(ctx, f.dentist.Id, from, to, 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStarts(t, slots, thursday(8, 0), thursday(8, 30), thursday(9, 0), thursday(9, 30), thursday(10, 30), thursday(11, 0), thursday(11, 30))
+
+	f.book(t, thursday(8, 0), 60, domain.StatusScheduled)
+	f.book(t, thursday(11, 0), 60, domain.StatusCancelled)
+	slots, err = f.service.GetAvailability(ctx, f.dentist.Id, from, to, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStarts(t, slots, thursday(9, 0), thursday(11, 0))
+
+	if _, err := f.store.Schedules().SaveTimeOff(ctx, domain.TimeOff{DentistId: f.dentist.Id, From: thursday(9, 0), To: thursday(12, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	slots, err = f.service.GetAvailability(ctx, f.dentist.Id, from, to, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStarts(t, slots)
+}
+
+func TestGetAvailabilityInactiveDentist(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	if _, err := f.store.Dentists().SetDeactivated(ctx, f.dentist.Id, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	slots, err := f.service.GetAvailability(ctx, f.dentist.Id, thursday(0, 0), thursday(23, 59), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots == nil || len(slots) != 0 {
+		t.Errorf("slots %v, want an empty list", slots)
+	}
+}
+
+func TestGetAvailabilityInvalid(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	tests := []struct {
+		name     string
+		from, to time.Time
+		duration time.Duration
+		field    string
+	}{
+		{"to before from", thursday(12, 0), thursday(8, 0), time.Hour, "to"},
+		{"longer than 31 days", thursday(0, 0), thursday(0, 0).AddDate(0, 0, 32), time.Hour, "to"},
+		{"no duration", thursday(0, 0), thursday(23, 59), 0, "duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.GetAvailability(ctx, f.dentist.Id, tt.from, tt.to, tt.duration)
+			var validation *domain.ValidationError
+			if !errors.As(err, &validation) || validation.Violations[0].Field != tt.field {
+				t.Errorf("expected a violation of %s, got %v", tt.field, err)
+			}
+		})
+	}
+
+	if _, err := f.service.GetAvailability(ctx, 99, thursday(0, 0), thursday(23, 59), time.Hour); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown dentist: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCheckAvailability(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	if _, err := f.store.Schedules().SaveTimeOff(ctx, domain.TimeOff{DentistId: f.dentist.Id, From: thursday(11, 0), To: thursday(12, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		available  bool
+	}{
+		{"inside the working hours", thursday(8, 0), thursday(9, 0), true},
+		{"ending at the break", thursday(9, 0), thursday(10, 0), true},
+		{"before the working hours", thursday(7, 30), thursday(8, 30), false},
+		{"during the break", thursday(9, 30), thursday(10, 30), false},
+		{"on time off", thursday(10, 30), thursday(11, 30), false},
+		{"on a day off", thursday(8, 0).AddDate(0, 0, 1), thursday(9, 0).AddDate(0, 0, 1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.service.CheckAvailability(ctx, f.dentist.LicenseNumber, tt.start, tt.end)
+			if tt.available && err != nil {
+				t.Errorf("expected the period available, got %v", err)
+			}
+			if !tt.available && !errors.Is(err, domain.ErrSlotUnavailable) {
+				t.Errorf("expected ErrSlotUnavailable, got %v", err)
+			}
+		})
+	}
+
+	if err := f.service.CheckAvailability(ctx, "L9", thursday(8, 0), thursday(9, 0)); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown dentist: expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestCheckAvailabilityWithoutWorkingHours - a dentist whose schedule was never set
+// is only unavailable on time off
+func TestCheckAvailabilityWithoutWorkingHours(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	dentist, err := f.store.Dentists().Save(ctx, domain.Dentist{Surname: "Souza", Name: "Rui", LicenseNumber: "L2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.store.Schedules().SaveTimeOff(ctx, domain.TimeOff{DentistId: dentist.Id, From: thursday(11, 0), To: thursday(12, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.service.CheckAvailability(ctx, "L2", thursday(20, 0), thursday(21, 0)); err != nil {
+		t.Errorf("expected the period available, got %v", err)
+	}
+	if err := f.service.CheckAvailability(ctx, "L2", thursday(11, 0), thursday(12, 0)); !errors.Is(err, domain.ErrSlotUnavailable) {
+		t.Errorf("on time off: expected ErrSlotUnavailable, got %v", err)
+	}
+}
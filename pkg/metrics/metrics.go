@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "clinic"
+
+// Metrics - the Prometheus metrics of the server. A nil Metrics records nothing,
+// so the stores and services built without one keep working.
+type Metrics struct {
+	registry              *prometheus.Registry
+	httpRequests          *prometheus.CounterVec
+	httpDuration          *prometheus.HistogramVec
+	dbQueryDuration       *prometheus.HistogramVec
+	appointmentsCreated   prometheus.Counter
+	appointmentsCancelled prometheus.Counter
+	appointmentsRejected  *prometheus.CounterVec
+}
+
+// New - the metrics along with the ones of the Go runtime and the process
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests answered, by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to answer the HTTP requests, by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		dbQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by the database queries, by operation and table.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		appointmentsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "appointments_created_total",
+			Help:      "Appointments booked, the occurrences of series included.",
+		}),
+		appointmentsCancelled: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "appointments_cancelled_total",
+			Help:      "Appointments cancelled.",
+		}),
+		appointmentsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "appointments_rejected_total",
+			Help:      "Bookings and reschedules rejected because the dentist wasn't available, by reason.",
+		}, []string{"reason"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbQueryDuration,
+		m.appointmentsCreated,
+		m.appointmentsCancelled,
+		m.appointmentsRejected,
+	)
+	return m
+}
+
+// Handler - serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// RegisterDB - exposes the size and usage of the connection pool of db under the
+// db_name name
+func (m *Metrics) RegisterDB(name string, db *sql.DB) error {
+	if m == nil {
+		return nil
+	}
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveRequest - records a request answered with status after d. route is the
+// pattern the request matched, so the ids in the paths don't make new series.
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveQuery - records a query of operation, such as select or insert, on table
+// which took d
+func (m *Metrics) ObserveQuery(operation, table string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.dbQueryDuration.WithLabelValues(operation, table).Observe(d.Seconds())
+}
+
+// AppointmentsCreated - counts n appointments booked
+func (m *Metrics) AppointmentsCreated(n int) {
+	if m == nil {
+		return
+	}
+	m.appointmentsCreated.Add(float64(n))
+}
+
+// AppointmentsCancelled - counts n appointments cancelled
+func (m *Metrics) AppointmentsCancelled(n int) {
+	if m == nil {
+		return
+	}
+	m.appointmentsCancelled.Add(float64(n))
+}
+
+// AppointmentRejected - counts a booking rejected for reason, the code of the
+// conflict such as slot_unavailable
+func (m *Metrics) AppointmentRejected(reason string) {
+	if m == nil {
+		return
+	}
+	m.appointmentsRejected.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,8 @@
+create table patients (
+    id int not null auto_increment,
+    surname varchar(50) not null,
+    name varchar(25) not null,
+    identity_number varchar(10) not null unique,
+    created_at datetime not null,
+    primary key (id)
+);
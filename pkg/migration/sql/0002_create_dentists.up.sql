@@ -0,0 +1,7 @@
+create table dentists (
+    id int not null auto_increment,
+    surname varchar(50) not null,
+    name varchar(25) not null,
+    license_number varchar(10) not null unique,
+    primary key (id)
+);
@@ -0,0 +1,10 @@
+create table appointments (
+    id int not null auto_increment,
+    description varchar(250) not null,
+    date_and_time datetime not null,
+    dentist_license varchar(10) not null,
+    patient_identity varchar(10) not null,
+    primary key (id),
+    constraint fk_dentist foreign key (dentist_license) references dentists(license_number),
+    constraint fk_patient foreign key (patient_identity) references patients(identity_number)
+);
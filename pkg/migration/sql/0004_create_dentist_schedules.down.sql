@@ -0,0 +1,2 @@
+drop table dentist_time_off;
+drop table dentist_weekly_intervals;
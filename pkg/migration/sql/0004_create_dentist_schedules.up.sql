@@ -0,0 +1,20 @@
+create table dentist_weekly_intervals (
+    id int not null auto_increment,
+    dentist_id int not null,
+    kind enum('working', 'break') not null,
+    weekday tinyint not null,
+    start_time time not null,
+    end_time time not null,
+    primary key (id),
+    constraint fk_weekly_interval_dentist foreign key (dentist_id) references dentists(id) on delete cascade
+);
+
+create table dentist_time_off (
+    id int not null auto_increment,
+    dentist_id int not null,
+    starts_at datetime not null,
+    ends_at datetime not null,
+    reason varchar(250) not null default '',
+    primary key (id),
+    constraint fk_time_off_dentist foreign key (dentist_id) references dentists(id) on delete cascade
+);
@@ -0,0 +1,2 @@
+drop table appointment_status_changes;
+alter table appointments drop column status;
@@ -0,0 +1,12 @@
+alter table appointments add column status varchar(20) not null default 'scheduled';
+
+create table appointment_status_changes (
+    id int not null auto_increment,
+    appointment_id int not null,
+    from_status varchar(20) null,
+    to_status varchar(20) not null,
+    reason varchar(250) not null default '',
+    changed_at datetime not null,
+    primary key (id),
+    constraint fk_status_change_appointment foreign key (appointment_id) references appointments(id) on delete cascade
+);
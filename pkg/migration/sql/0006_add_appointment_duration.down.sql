@@ -0,0 +1,2 @@
+alter table appointments drop column duration_minutes;
+alter table appointments drop column procedure_type;
@@ -0,0 +1,2 @@
+alter table appointments add column procedure_type varchar(30) not null default 'consultation';
+alter table appointments add column duration_minutes int not null default 60;
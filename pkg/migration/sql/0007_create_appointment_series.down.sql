@@ -0,0 +1,3 @@
+alter table appointments drop foreign key fk_appointment_series;
+alter table appointments drop column series_id;
+drop table appointment_series;
@@ -0,0 +1,11 @@
+create table appointment_series (
+    id int not null auto_increment,
+    frequency varchar(10) not null,
+    interval_count int not null default 1,
+    occurrences int null,
+    until date null,
+    primary key (id)
+);
+
+alter table appointments add column series_id int null;
+alter table appointments add constraint fk_appointment_series foreign key (series_id) references appointment_series(id) on delete set null;
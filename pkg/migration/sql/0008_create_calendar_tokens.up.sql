@@ -0,0 +1,8 @@
+create table calendar_tokens (
+    owner varchar(10) not null,
+    owner_id int not null,
+    token char(64) not null,
+    created_at datetime not null,
+    primary key (owner, owner_id),
+    unique key uk_calendar_tokens_token (token)
+);
@@ -0,0 +1,13 @@
+create table users (
+    id int not null auto_increment,
+    email varchar(100) not null,
+    password_hash varchar(100) not null,
+    role varchar(20) not null,
+    dentist_id int null,
+    patient_id int null,
+    created_at datetime not null,
+    primary key (id),
+    unique key uk_users_email (email),
+    constraint fk_user_dentist foreign key (dentist_id) references dentists(id) on delete cascade,
+    constraint fk_user_patient foreign key (patient_id) references patients(id) on delete cascade
+);
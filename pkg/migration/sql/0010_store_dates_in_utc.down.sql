@@ -0,0 +1,10 @@
+-- back to the America/Sao_Paulo wall clock, checked as in the up migration
+drop table if exists migration_timezone_check;
+create table migration_timezone_check (converted datetime not null);
+insert into migration_timezone_check values (CONVERT_TZ('2000-01-01 00:00:00', '+00:00', 'America/Sao_Paulo'));
+drop table migration_timezone_check;
+update appointments set date_and_time = CONVERT_TZ(date_and_time, '+00:00', 'America/Sao_Paulo');
+update patients set created_at = CONVERT_TZ(created_at, '+00:00', 'America/Sao_Paulo');
+update dentist_time_off set
+    starts_at = CONVERT_TZ(starts_at, '+00:00', 'America/Sao_Paulo'),
+    ends_at = CONVERT_TZ(ends_at, '+00:00', 'America/Sao_Paulo');
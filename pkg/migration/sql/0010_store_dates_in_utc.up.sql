@@ -0,0 +1,14 @@
+-- the dates were stored at the wall clock of the clinic, America/Sao_Paulo, which
+-- was the only time zone before CLINIC_TIMEZONE. They are moved to UTC.
+-- CONVERT_TZ needs the time zone tables of MySQL loaded (mysql_tzinfo_to_sql),
+-- without them it returns NULL: the not null column of the check below then fails
+-- the migration before any date is changed.
+drop table if exists migration_timezone_check;
+create table migration_timezone_check (converted datetime not null);
+insert into migration_timezone_check values (CONVERT_TZ('2000-01-01 00:00:00', 'America/Sao_Paulo', '+00:00'));
+drop table migration_timezone_check;
+update appointments set date_and_time = CONVERT_TZ(date_and_time, 'America/Sao_Paulo', '+00:00');
+update patients set created_at = CONVERT_TZ(created_at, 'America/Sao_Paulo', '+00:00');
+update dentist_time_off set
+    starts_at = CONVERT_TZ(starts_at, 'America/Sao_Paulo', '+00:00'),
+    ends_at = CONVERT_TZ(ends_at, 'America/Sao_Paulo', '+00:00');
@@ -0,0 +1,3 @@
+alter table appointments drop column version;
+alter table patients drop column version;
+alter table dentists drop column version;
@@ -0,0 +1,5 @@
+-- the version is incremented by every update, an update naming an older version
+-- than the stored one changes nothing
+alter table dentists add column version int not null default 1;
+alter table patients add column version int not null default 1;
+alter table appointments add column version int not null default 1;
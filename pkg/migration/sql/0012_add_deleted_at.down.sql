@@ -0,0 +1,4 @@
+drop index ix_appointments_deleted_at on appointments;
+alter table appointments drop column deleted_at;
+alter table patients drop column deleted_at;
+alter table dentists drop column deleted_at;
@@ -0,0 +1,6 @@
+-- a deleted row keeps its references and history until it's purged, deleted_at is
+-- null while it's active
+alter table dentists add column deleted_at datetime null;
+alter table patients add column deleted_at datetime null;
+alter table appointments add column deleted_at datetime null;
+create index ix_appointments_deleted_at on appointments (deleted_at);
@@ -0,0 +1 @@
+alter table dentists drop column deactivated_at;
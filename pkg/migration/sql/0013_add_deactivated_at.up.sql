@@ -0,0 +1,3 @@
+-- a deactivated dentist keeps its appointments but takes no new bookings until
+-- it's reactivated, deactivated_at is null while it takes them
+alter table dentists add column deactivated_at datetime null;
@@ -0,0 +1,14 @@
+-- the entries outlive the rows they describe, so entity_id and actor_id reference
+-- nothing
+create table audit_log (
+    id int not null auto_increment,
+    actor_id int null,
+    actor varchar(100) not null,
+    at datetime not null,
+    entity varchar(20) not null,
+    entity_id int not null,
+    operation varchar(20) not null,
+    changes json not null,
+    primary key (id),
+    key ix_audit_log_entity (entity, entity_id, at)
+);
@@ -0,0 +1,333 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+type appointmentMemoryStore struct {
+	*memoryStore
+}
+
+// GetAll - returns all appointments ordered by date and time
+func (m *appointmentMemoryStore) GetAll(ctx context.Context) ([]domain.AppointmentDTO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.appointmentsDTO(func(domain.Appointment) bool { return true }), nil
+}
+
+// List - returns a page of appointments
+func (m *appointmentMemoryStore) List(ctx context.Context, opts ListOptions) (Page[domain.AppointmentDTO], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	appointments := make([]domain.Appointment, 0, len(m.appointments))
+	for _, id := range sortedIDs(m.appointments) {
+		appointments = append(appointments, m.appointments[id])
+	}
+	page, err := memList(appointments, opts, appointmentColumns, "date_and_time", func(a domain.Appointment, column string) interface{} {
+		switch column {
+		case "id":
+			return a.Id
+		case "description":
+			return a.Description
+		case "date_and_time":
+			return a.DateAndTime
+		case "dentist_license":
+			return a.DentistLicense
+		case "patient_identity":
+			return a.PatientIdentity
+		case "deleted_at":
+			return a.DeletedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return Page[domain.AppointmentDTO]{}, err
+	}
+	dtos := Page[domain.AppointmentDTO]{Items: make([]domain.AppointmentDTO, 0, len(page.Items)), Total: page.Total}
+	for _, appointment := range page.Items {
+		if dto, ok := m.join(appointment); ok {
+			dtos.Items = append(dtos.Items, dto)
+		}
+	}
+	return dtos, nil
+}
+
+// GetByID - returns an appointment by ID
+func (m *appointmentMemoryStore) GetByID(ctx context.Context, entityID int) (domain.AppointmentDTO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.appointmentDTO(entityID)
+}
+
+// Save - inserts the appointment along with the first entry of its status history
+// and returns it joined with dentist and patient
+func (m *appointmentMemoryStore) Save(ctx context.Context, appointment domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+	m.lock()
+	defer m.unlock()
+
+	stored, err := m.newAppointment(appointment.Appointment)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if m.overlaps(stored) {
+		return domain.AppointmentDTO{}, errOverlap
+	}
+	stored.Id = m.nextID("appointments")
+	stored.Version = 1
+	m.appointments[stored.Id] = stored
+	m.addStatusChange(domain.StatusChange{AppointmentId: stored.Id, To: stored.Status})
+	return m.appointmentDTO(stored.Id)
+}
+
+// Update - updates the appointment at appointment.Version and returns it joined
+// with dentist and patient
+func (m *appointmentMemoryStore) Update(ctx context.Context, entityID int, appointment domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+	m.lock()
+	defer m.unlock()
+
+	current, ok := m.appointments[entityID]
+	if !ok || !current.DeletedAt.IsZero() {
+		return domain.AppointmentDTO{}, fmt.Errorf("%w: appointment %d", domain.ErrNotFound, entityID)
+	}
+	if current.Version != appointment.Version {
+		return domain.AppointmentDTO{}, fmt.Errorf("%w: appointment %d is at version %d", domain.ErrVersionMismatch, entityID, current.Version)
+	}
+	appointment.Id = entityID
+	stored, err := m.newAppointment(appointment.Appointment)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	// the status only changes through ChangeStatus and the series never changes
+	stored.Status = current.Status
+	stored.SeriesId = current.SeriesId
+	stored.Version = current.Version + 1
+	if m.overlaps(stored) {
+		return domain.AppointmentDTO{}, errOverlap
+	}
+	m.appointments[entityID] = stored
+	return m.appointmentDTO(entityID)
+}
+
+// Delete - the appointment keeps its status history and frees its time
+func (m *appointmentMemoryStore) Delete(ctx context.Context, entityID int) error {
+	m.lock()
+	defer m.unlock()
+
+	appointment, ok := m.appointments[entityID]
+	if !ok || !appointment.DeletedAt.IsZero() {
+		return fmt.Errorf("%w: appointment %d", domain.ErrNotFound, entityID)
+	}
+	appointment.DeletedAt = time.Now()
+	appointment.Version++
+	m.appointments[entityID] = appointment
+	return nil
+}
+
+// Restore - an appointment holding its time is restored only if it doesn't
+// overlap another one and its dentist and patient aren't deleted
+func (m *appointmentMemoryStore) Restore(ctx context.Context, entityID int) (domain.AppointmentDTO, error) {
+	m.lock()
+	defer m.unlock()
+
+	appointment, ok := m.appointments[entityID]
+	if !ok || appointment.DeletedAt.IsZero() {
+		return domain.AppointmentDTO{}, fmt.Errorf("%w: no deleted appointment %d", domain.ErrNotFound, entityID)
+	}
+	if appointment.Status.IsActive() {
+		if _, err := m.newAppointment(appointment); err != nil {
+			return domain.AppointmentDTO{}, err
+		}
+		if m.overlaps(appointment) {
+			return domain.AppointmentDTO{}, errOverlap
+		}
+	}
+	appointment.DeletedAt = time.Time{}
+	appointment.Version++
+	m.appointments[entityID] = appointment
+	return m.appointmentDTO(entityID)
+}
+
+// Purge - the status history of the appointments is removed along
+func (m *appointmentMemoryStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	purged := 0
+	for id, appointment := range m.appointments {
+		if !isPurgeable(appointment.DeletedAt, before) {
+			continue
+		}
+		delete(m.appointments, id)
+		for changeID, change := range m.statusChanges {
+			if change.AppointmentId == id {
+				delete(m.statusChanges, changeID)
+			}
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (m *appointmentMemoryStore) GetAllAppointmentsByPatientIdentify(ctx context.Context, identifyNumber string) ([]domain.AppointmentDTO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.appointmentsDTO(func(a domain.Appointment) bool { return a.PatientIdentity == identifyNumber }), nil
+}
+
+func (m *appointmentMemoryStore) GetAllAppointmentsByDentistsLicense(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.appointmentsDTO(func(a domain.Appointment) bool { return a.DentistLicense == licenseNumber }), nil
+}
+
+// ChangeStatus - fails if the appointment isn't at change.From anymore
+func (m *appointmentMemoryStore) ChangeStatus(ctx context.Context, change domain.StatusChange) (domain.AppointmentDTO, error) {
+	m.lock()
+	defer m.unlock()
+
+	stored, ok := m.appointments[change.AppointmentId]
+	if !ok || !stored.DeletedAt.IsZero() || stored.Status != change.From {
+		return domain.AppointmentDTO{}, domain.ErrConcurrentUpdate
+	}
+	stored.Status = change.To
+	stored.Version++
+	m.appointments[change.AppointmentId] = stored
+	m.addStatusChange(change)
+	return m.appointmentDTO(change.AppointmentId)
+}
+
+// GetStatusHistory - returns the status changes of an appointment, oldest first
+func (m *appointmentMemoryStore) GetStatusHistory(ctx context.Context, appointmentID int) ([]domain.StatusChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	history := []domain.StatusChange{}
+	for _, id := range sortedIDs(m.statusChanges) {
+		if m.statusChanges[id].AppointmentId == appointmentID {
+			history = append(history, m.statusChanges[id])
+		}
+	}
+	return history, nil
+}
+
+// SaveSeries - inserts the recurrence of a series, its occurrences are inserted by Save
+func (m *appointmentMemoryStore) SaveSeries(ctx context.Context, series domain.AppointmentSeries) (domain.AppointmentSeries, error) {
+	m.lock()
+	defer m.unlock()
+
+	series.Id = m.nextID("appointment_series")
+	m.series[series.Id] = series
+	return series, nil
+}
+
+func (m *appointmentMemoryStore) GetAllAppointmentsBySeries(ctx context.Context, seriesID int) ([]domain.AppointmentDTO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.appointmentsDTO(func(a domain.Appointment) bool { return a.SeriesId == seriesID }), nil
+}
+
+// addStatusChange - records a status change at the current time. Callers must hold the lock.
+func (m *appointmentMemoryStore) addStatusChange(change domain.StatusChange) {
+	change.Id = m.nextID("appointment_status_changes")
+	change.ChangedAt = time.Now()
+	m.statusChanges[change.Id] = change
+}
+
+// appointmentDTO - returns a single joined appointment. Callers must hold the lock.
+func (m *appointmentMemoryStore) appointmentDTO(entityID int) (domain.AppointmentDTO, error) {
+	appointments := m.appointmentsDTO(func(a domain.Appointment) bool { return a.Id == entityID })
+	if len(appointments) == 0 {
+		return domain.AppointmentDTO{}, fmt.Errorf("%w: appointment %d", domain.ErrNotFound, entityID)
+	}
+	return appointments[0], nil
+}
+
+// appointmentsDTO - returns the appointments matching filter joined with their
+// dentist and patient, ordered by date_and_time. The deleted ones are left out.
+// Callers must hold the lock.
+func (m *appointmentMemoryStore) appointmentsDTO(filter func(domain.Appointment) bool) []domain.AppointmentDTO {
+	var appointments []domain.Appointment
+	for _, appointment := range m.appointments {
+		if appointment.DeletedAt.IsZero() && filter(appointment) {
+			appointments = append(appointments, appointment)
+		}
+	}
+	sort.Slice(appointments, func(i, j int) bool {
+		if appointments[i].DateAndTime.Equal(appointments[j].DateAndTime) {
+			return appointments[i].Id < appointments[j].Id
+		}
+		return appointments[i].DateAndTime.Before(appointments[j].DateAndTime)
+	})
+
+	dtos := []domain.AppointmentDTO{}
+	for _, appointment := range appointments {
+		if dto, ok := m.join(appointment); ok {
+			dtos = append(dtos, dto)
+		}
+	}
+	return dtos
+}
+
+// join - joins an appointment with its dentist and patient as the INNER JOIN
+// of the SQL store does. Callers must hold the lock.
+func (m *appointmentMemoryStore) join(appointment domain.Appointment) (domain.AppointmentDTO, bool) {
+	dentist := m.dentistByLicense(appointment.DentistLicense)
+	patient := m.patientByIdentity(appointment.PatientIdentity)
+	if dentist == nil || patient == nil {
+		return domain.AppointmentDTO{}, false
+	}
+	dto := domain.AppointmentDTO{
+		Appointment: appointment,
+		Dentist:     *dentist,
+		Patient:     *patient,
+	}
+	_, dto.EndDateAndTime = appointment.Interval()
+	return dto, true
+}
+
+// overlaps - tells if an active appointment of the same dentist or patient overlaps
+// appointment, as the overlapping query of the SQL store. Callers must hold the lock.
+func (m *appointmentMemoryStore) overlaps(appointment domain.Appointment) bool {
+	start, end := appointment.Interval()
+	return m.hasAppointments(func(other domain.Appointment) bool {
+		otherStart, otherEnd := other.Interval()
+		return other.Id != appointment.Id &&
+			other.DeletedAt.IsZero() &&
+			other.Status.IsActive() &&
+			(other.DentistLicense == appointment.DentistLicense || other.PatientIdentity == appointment.PatientIdentity) &&
+			otherStart.Before(end) &&
+			start.Before(otherEnd)
+	})
+}
+
+// newAppointment - validates the references of an appointment as the foreign
+// keys would do, a deleted dentist or patient isn't referenced. A deactivated
+// dentist only keeps the appointments already booked with it. Callers must hold
+// the lock.
+func (m *appointmentMemoryStore) newAppointment(appointment domain.Appointment) (domain.Appointment, error) {
+	dentist := m.dentistByLicense(appointment.DentistLicense)
+	if dentist == nil || !dentist.DeletedAt.IsZero() {
+		return domain.Appointment{}, domain.NewValidationError("dentist_license", "exists", "doesn't reference a dentist")
+	}
+	if current, ok := m.appointments[appointment.Id]; !dentist.IsActive() && (!ok || current.DentistLicense != dentist.LicenseNumber) {
+		return domain.Appointment{}, fmt.Errorf("%w: dentist %s", domain.ErrDentistInactive, dentist.LicenseNumber)
+	}
+	if patient := m.patientByIdentity(appointment.PatientIdentity); patient == nil || !patient.DeletedAt.IsZero() {
+		return domain.Appointment{}, domain.NewValidationError("patient_identity", "exists", "doesn't reference a patient")
+	}
+	if _, ok := m.series[appointment.SeriesId]; appointment.SeriesId != 0 && !ok {
+		return domain.Appointment{}, domain.NewValidationError("series_id", "exists", "doesn't reference a series")
+	}
+	return appointment, nil
+}
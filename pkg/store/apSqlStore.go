@@ -1,80 +1,405 @@
 package store
 
 import (
+	"context"
 	"database/sql"
-	"github.com/mauriciogregory/esp_backIII_go/config"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
-	"log"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/metrics"
 )
 
-func init() {
-	config.LoadConfig()
-}
+// appointmentDTOQuery - selects appointments joined with their dentist and patient,
+// the WHERE and ORDER BY clauses are appended by each method
+const appointmentDTOQuery = "SELECT a.id, a.description, a.date_and_time,a.dentist_license,a.patient_identity,a.status,a.procedure_type,a.duration_minutes,DATE_ADD(a.date_and_time, INTERVAL a.duration_minutes MINUTE) end_date_and_time,COALESCE(a.series_id,0),a.version,a.deleted_at,d.id,d.surname,d.name,d.license_number,d.version,d.deleted_at,p.id,p.surname,p.name,p.identity_number,p.created_at,p.version,p.deleted_at FROM appointments a INNER JOIN dentists d on a.dentist_license = d.license_number INNER JOIN patients p on a.patient_identity = p.identity_number"
+
+// overlappingQuery - counts the active appointments of the same dentist or patient
+// whose interval overlaps [start, end), the appointment itself and the deleted ones
+// excluded. It's a locking read so it sees the appointments committed meanwhile.
+const overlappingQuery = "SELECT COUNT(*) FROM appointments WHERE id <> ? AND deleted_at IS NULL AND status NOT IN (?,?) AND (dentist_license = ? OR patient_identity = ?) AND date_and_time < ? AND DATE_ADD(date_and_time, INTERVAL duration_minutes MINUTE) > ? FOR UPDATE"
+
+// inactiveDentistQuery - tells whether the dentist of an appointment is deactivated
+// and the appointment isn't already booked with it
+const inactiveDentistQuery = "SELECT COUNT(*) > 0 FROM dentists d WHERE d.license_number = ? AND d.deactivated_at IS NOT NULL AND NOT EXISTS (SELECT 1 FROM appointments a WHERE a.id = ? AND a.dentist_license = d.license_number)"
 
 type ApStore interface {
-	Store
-	GetAllAppointmentsByPatientIdentify(identifyNumber string) ([]domain.AppointmentDTO, error)
-	GetAllAppointmentsByDentistsLicense(licenseNumber string) ([]domain.AppointmentDTO, error)
-	GetAllAppointmentsByDateTimeInterval(startDateTime, endDateTime string) ([]domain.Appointment, error)
+	Repository[domain.AppointmentDTO]
+	GetAllAppointmentsByPatientIdentify(ctx context.Context, identifyNumber string) ([]domain.AppointmentDTO, error)
+	GetAllAppointmentsByDentistsLicense(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error)
+	// ChangeStatus - moves an appointment from change.From to change.To and records
+	// the change, fails if the appointment isn't at change.From anymore
+	ChangeStatus(ctx context.Context, change domain.StatusChange) (domain.AppointmentDTO, error)
+	GetStatusHistory(ctx context.Context, appointmentID int) ([]domain.StatusChange, error)
+	SaveSeries(ctx context.Context, series domain.AppointmentSeries) (domain.AppointmentSeries, error)
+	// GetAllAppointmentsBySeries - returns the occurrences of a series ordered by date and time
+	GetAllAppointmentsBySeries(ctx context.Context, seriesID int) ([]domain.AppointmentDTO, error)
 }
 
-func NewSQLAp() ApStore {
-	database, err := config.ConnectDatabase()
-	if err != nil {
-		panic(err)
-	}
+// NewSQLAp - the appointments store of db, whose pool is shared with the other
+// stores. logger receives the writes of the store at debug level, m the duration
+// of its queries.
+func NewSQLAp(db *sql.DB, logger *slog.Logger, m *metrics.Metrics) ApStore {
 	return &appointmentStore{
-		sqlStore: &sqlStore{db: database},
-		db:       database,
+		db:     &conn{db: db, metrics: m},
+		logger: logger,
 	}
 }
 
 type appointmentStore struct {
-	*sqlStore
-	db *sql.DB
+	db     *conn
+	logger *slog.Logger
 }
 
-func (sa *appointmentStore) GetAllAppointmentsByPatientIdentify(identifyNumber string) ([]domain.AppointmentDTO, error) {
-	var appointment domain.AppointmentDTO
-	var appointments []domain.AppointmentDTO
+// GetAll - returns all appointments ordered by date and time
+func (sa *appointmentStore) GetAll(ctx context.Context) ([]domain.AppointmentDTO, error) {
+	rows, err := sa.db.QueryContext(ctx, appointmentDTOQuery+" WHERE a.deleted_at IS NULL ORDER BY a.date_and_time")
+	if err != nil {
+		return nil, err
+	}
+	return scanAppointmentsDTO(rows)
+}
+
+// List - returns a page of appointments
+func (sa *appointmentStore) List(ctx context.Context, opts ListOptions) (Page[domain.AppointmentDTO], error) {
+	list, err := opts.sqlListClauses(appointmentColumns, "date_and_time", "a.", "date_and_time")
+	if err != nil {
+		return Page[domain.AppointmentDTO]{}, err
+	}
+
+	var page Page[domain.AppointmentDTO]
+	if err := sa.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM appointments a"+list.where, list.args...).Scan(&page.Total); err != nil {
+		return Page[domain.AppointmentDTO]{}, err
+	}
+	rows, err := sa.db.QueryContext(ctx, appointmentDTOQuery+list.where+list.orderBy, list.args...)
+	if err != nil {
+		return Page[domain.AppointmentDTO]{}, err
+	}
+	if page.Items, err = scanAppointmentsDTO(rows); err != nil {
+		return Page[domain.AppointmentDTO]{}, err
+	}
+	return page, nil
+}
+
+// GetByID - returns an appointment by ID
+func (sa *appointmentStore) GetByID(ctx context.Context, entityID int) (domain.AppointmentDTO, error) {
+	rows, err := sa.db.QueryContext(ctx, appointmentDTOQuery+" WHERE a.id = ? AND a.deleted_at IS NULL", entityID)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	appointments, err := scanAppointmentsDTO(rows)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if len(appointments) == 0 {
+		return domain.AppointmentDTO{}, fmt.Errorf("%w: appointment %d", domain.ErrNotFound, entityID)
+	}
+	return appointments[0], nil
+}
+
+// Save - inserts the appointment along with the first entry of its status history
+// and returns it joined with dentist and patient. The overlap check and the insert
+// run in the same transaction.
+func (sa *appointmentStore) Save(ctx context.Context, appointment domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+	apDateAndTimeParsed, end := appointment.Interval()
+	tx, err := sa.db.begin(ctx)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkOverlap(ctx, tx, appointment.Appointment, apDateAndTimeParsed, end); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	var seriesID sql.NullInt64
+	if appointment.SeriesId != 0 {
+		seriesID = sql.NullInt64{Int64: int64(appointment.SeriesId), Valid: true}
+	}
+	result, err := tx.ExecContext(ctx, "INSERT INTO appointments(DESCRIPTION, DATE_AND_TIME, dentist_license, patient_identity, status, procedure_type, duration_minutes, series_id) VALUES(?,?,?,?,?,?,?,?)",
+		appointment.Description,
+		apDateAndTimeParsed,
+		appointment.DentistLicense,
+		appointment.PatientIdentity,
+		appointment.Status,
+		appointment.ProcedureType,
+		appointment.DurationMinutes,
+		seriesID)
+	if err != nil {
+		return domain.AppointmentDTO{}, sqlError(err)
+	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if err := insertStatusChange(ctx, tx, domain.StatusChange{AppointmentId: int(lastInsertedID), To: appointment.Status}); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	sa.logger.DebugContext(ctx, "appointment inserted", "id", lastInsertedID, "dentist_license", appointment.DentistLicense)
+	return sa.GetByID(ctx, int(lastInsertedID))
+}
+
+// Update - updates the appointment at appointment.Version and returns it joined
+// with dentist and patient. The overlap check and the update run in the same
+// transaction.
+func (sa *appointmentStore) Update(ctx context.Context, entityID int, appointment domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+	apDateAndTimeParsed, end := appointment.Interval()
+	tx, err := sa.db.begin(ctx)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	defer tx.Rollback()
+
+	appointment.Id = entityID
+	if err := checkOverlap(ctx, tx, appointment.Appointment, apDateAndTimeParsed, end); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	result, err := tx.ExecContext(ctx, "UPDATE appointments SET description = ?, date_and_time = ?, dentist_license = ?, patient_identity = ?, procedure_type = ?, duration_minutes = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL",
+		appointment.Description,
+		apDateAndTimeParsed,
+		appointment.DentistLicense,
+		appointment.PatientIdentity,
+		appointment.ProcedureType,
+		appointment.DurationMinutes,
+		entityID,
+		appointment.Version)
+	if err != nil {
+		return domain.AppointmentDTO{}, sqlError(err)
+	}
+	if err := checkVersion(ctx, tx, result, "SELECT version FROM appointments WHERE id = ? AND deleted_at IS NULL", entityID); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	return sa.GetByID(ctx, entityID)
+}
+
+// Delete - the appointment keeps its status history and frees its time
+func (sa *appointmentStore) Delete(ctx context.Context, entityID int) error {
+	return softDelete(ctx, sa.db, "UPDATE appointments SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL", entityID)
+}
+
+// Restore - an appointment holding its time is restored only if it doesn't
+// overlap another one and its dentist and patient aren't deleted, which is checked
+// in the same transaction
+func (sa *appointmentStore) Restore(ctx context.Context, entityID int) (domain.AppointmentDTO, error) {
+	tx, err := sa.db.begin(ctx)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, appointmentDTOQuery+" WHERE a.id = ? AND a.deleted_at IS NOT NULL", entityID)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	appointments, err := scanAppointmentsDTO(rows)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if len(appointments) == 0 {
+		return domain.AppointmentDTO{}, fmt.Errorf("%w: no deleted appointment %d", domain.ErrNotFound, entityID)
+	}
+	if appointment := appointments[0]; appointment.Status.IsActive() {
+		start, end := appointment.Interval()
+		if err := checkOverlap(ctx, tx, appointment.Appointment, start, end); err != nil {
+			return domain.AppointmentDTO{}, err
+		}
+	}
+	if err := restore(ctx, tx, "UPDATE appointments SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", entityID); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	return sa.GetByID(ctx, entityID)
+}
+
+// Purge - the status history of the appointments is removed along
+func (sa *appointmentStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	return purge(ctx, sa.db, "DELETE FROM appointments WHERE deleted_at < ?", before)
+}
+
+func (sa *appointmentStore) GetAllAppointmentsByPatientIdentify(ctx context.Context, identifyNumber string) ([]domain.AppointmentDTO, error) {
+	rows, err := sa.db.QueryContext(ctx, appointmentDTOQuery+" WHERE a.patient_identity = ? AND a.deleted_at IS NULL ORDER BY a.date_and_time", identifyNumber)
+	if err != nil {
+		return nil, err
+	}
+	return scanAppointmentsDTO(rows)
+}
 
-	query := "SELECT a.id, a.description, DATE_FORMAT(a.date_and_time,'%d/%m/%Y %H:%i') date_and_time,a.dentist_license,a.patient_identity,d.id,d.surname,d.name,d.license_number,p.id,p.surname,p.name,p.identity_number,DATE_FORMAT(p.created_at,'%d/%m/%Y %H:%i') created_at FROM appointments a INNER JOIN dentists d on a.dentist_license = d.license_number INNER JOIN patients p on a.patient_identity = p.identity_number WHERE a.patient_identity = ? ORDER BY a.date_and_time"
-	rows, err := sa.db.Query(query, identifyNumber)
+func (sa *appointmentStore) GetAllAppointmentsByDentistsLicense(ctx context.Context, licenseNumber string) ([]domain.AppointmentDTO, error) {
+	rows, err := sa.db.QueryContext(ctx, appointmentDTOQuery+" WHERE a.dentist_license = ? AND a.deleted_at IS NULL ORDER BY a.date_and_time", licenseNumber)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
+	return scanAppointmentsDTO(rows)
+}
+
+// ChangeStatus - the status is compared in the UPDATE so two concurrent changes
+// can't both leave the same status
+func (sa *appointmentStore) ChangeStatus(ctx context.Context, change domain.StatusChange) (domain.AppointmentDTO, error) {
+	tx, err := sa.db.begin(ctx)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE appointments SET status = ?, version = version + 1 WHERE id = ? AND status = ? AND deleted_at IS NULL", change.To, change.AppointmentId, change.From)
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if count == 0 {
+		return domain.AppointmentDTO{}, domain.ErrConcurrentUpdate
+	}
+	if err := insertStatusChange(ctx, tx, change); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.AppointmentDTO{}, err
+	}
+	return sa.GetByID(ctx, change.AppointmentId)
+}
+
+// GetStatusHistory - returns the status changes of an appointment, oldest first
+func (sa *appointmentStore) GetStatusHistory(ctx context.Context, appointmentID int) ([]domain.StatusChange, error) {
+	rows, err := sa.db.QueryContext(ctx, "SELECT id, appointment_id, COALESCE(from_status,''), to_status, reason, changed_at FROM appointment_status_changes WHERE appointment_id = ? ORDER BY changed_at, id", appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var change domain.StatusChange
+	history := []domain.StatusChange{}
 	for rows.Next() {
 		if err := rows.Scan(
-			&appointment.Id,
-			&appointment.Description,
-			&appointment.DateAndTime,
-			&appointment.DentistLicense,
-			&appointment.PatientIdentity,
-			&appointment.Dentist.Id,
-			&appointment.Dentist.Surname,
-			&appointment.Dentist.Name,
-			&appointment.Dentist.LicenseNumber,
-			&appointment.Patient.Id,
-			&appointment.Patient.Surname,
-			&appointment.Patient.Name,
-			&appointment.Patient.IdentityNumber,
-			&appointment.Patient.CreatedAt); err != nil {
-			return appointments, err
+			&change.Id,
+			&change.AppointmentId,
+			&change.From,
+			&change.To,
+			&change.Reason,
+			&change.ChangedAt); err != nil {
+			return history, err
 		}
-		appointments = append(appointments, appointment)
+		history = append(history, change)
 	}
-	return appointments, nil
+	return history, rows.Err()
 }
 
-func (sa *appointmentStore) GetAllAppointmentsByDentistsLicense(licenseNumber string) ([]domain.AppointmentDTO, error) {
-	var appointment domain.AppointmentDTO
-	var appointments []domain.AppointmentDTO
+// SaveSeries - inserts the recurrence of a series, its occurrences are inserted by Save
+func (sa *appointmentStore) SaveSeries(ctx context.Context, series domain.AppointmentSeries) (domain.AppointmentSeries, error) {
+	var count sql.NullInt64
+	if series.Recurrence.Count != 0 {
+		count = sql.NullInt64{Int64: int64(series.Recurrence.Count), Valid: true}
+	}
+	// until is a day of the clinic calendar, it's stored as such and not converted to UTC
+	var until sql.NullString
+	if !series.Recurrence.Until.IsZero() {
+		until = sql.NullString{String: domain.FormatDate(series.Recurrence.Until), Valid: true}
+	}
+	result, err := sa.db.ExecContext(ctx, "INSERT INTO appointment_series(frequency, interval_count, occurrences, until) VALUES (?,?,?,?)",
+		series.Recurrence.Frequency,
+		series.Recurrence.Interval,
+		count,
+		until)
+	if err != nil {
+		return domain.AppointmentSeries{}, err
+	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return domain.AppointmentSeries{}, err
+	}
+	series.Id = int(lastInsertedID)
+	return series, nil
+}
 
-	query := "SELECT a.id, a.description, DATE_FORMAT(a.date_and_time,'%d/%m/%Y %H:%i') date_and_time,a.dentist_license,a.patient_identity,d.id,d.surname,d.name,d.license_number,p.id,p.surname,p.name,p.identity_number,DATE_FORMAT(p.created_at,'%d/%m/%Y %H:%i') created_at FROM appointments a INNER JOIN dentists d on a.dentist_license = d.license_number INNER JOIN patients p on a.patient_identity = p.identity_number WHERE a.dentist_license = ? ORDER BY a.date_and_time"
-	rows, err := sa.db.Query(query, licenseNumber)
+func (sa *appointmentStore) GetAllAppointmentsBySeries(ctx context.Context, seriesID int) ([]domain.AppointmentDTO, error) {
+	rows, err := sa.db.QueryContext(ctx, appointmentDTOQuery+" WHERE a.series_id = ? AND a.deleted_at IS NULL ORDER BY a.date_and_time", seriesID)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
+	}
+	return scanAppointmentsDTO(rows)
+}
+
+// checkOverlap - returns errOverlap if an active appointment of the same dentist
+// or patient overlaps [start, end). The rows of the dentist and the patient stay
+// locked until the transaction ends, so the bookings of either are checked and
+// written one at a time and two of them can't take the same slot. A deleted dentist
+// or patient is reported as not referenced, a missing one is left to the foreign keys.
+// A deactivated dentist only keeps the appointments already booked with it.
+func checkOverlap(ctx context.Context, tx querier, appointment domain.Appointment, start, end time.Time) error {
+	// always the dentist first, so two bookings can't wait for each other
+	for _, lock := range []struct{ query, key, field, message string }{
+		{"SELECT deleted_at IS NULL FROM dentists WHERE license_number = ? FOR UPDATE", appointment.DentistLicense, "dentist_license", "doesn't reference a dentist"},
+		{"SELECT deleted_at IS NULL FROM patients WHERE identity_number = ? FOR UPDATE", appointment.PatientIdentity, "patient_identity", "doesn't reference a patient"},
+	} {
+		var active bool
+		err := tx.QueryRowContext(ctx, lock.query, lock.key).Scan(&active)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !active {
+			return domain.NewValidationError(lock.field, "exists", lock.message)
+		}
+	}
+	var inactive bool
+	if err := tx.QueryRowContext(ctx, inactiveDentistQuery, appointment.DentistLicense, appointment.Id).Scan(&inactive); err != nil {
+		return err
+	}
+	if inactive {
+		return fmt.Errorf("%w: dentist %s", domain.ErrDentistInactive, appointment.DentistLicense)
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, overlappingQuery,
+		appointment.Id,
+		domain.StatusCancelled,
+		domain.StatusNoShow,
+		appointment.DentistLicense,
+		appointment.PatientIdentity,
+		end,
+		start).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return errOverlap
 	}
+	return nil
+}
+
+// insertStatusChange - records a status change at the current time, an empty
+// From is stored as NULL
+func insertStatusChange(ctx context.Context, tx querier, change domain.StatusChange) error {
+	var from sql.NullString
+	if change.From != "" {
+		from = sql.NullString{String: string(change.From), Valid: true}
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO appointment_status_changes(appointment_id, from_status, to_status, reason, changed_at) VALUES (?,?,?,?,?)",
+		change.AppointmentId,
+		from,
+		change.To,
+		change.Reason,
+		time.Now())
+	return err
+}
+
+// scanAppointmentsDTO - reads and closes rows selected with appointmentDTOQuery
+func scanAppointmentsDTO(rows *sql.Rows) ([]domain.AppointmentDTO, error) {
+	defer rows.Close()
+
+	var appointment domain.AppointmentDTO
+	appointments := []domain.AppointmentDTO{}
 	for rows.Next() {
 		if err := rows.Scan(
 			&appointment.Id,
@@ -82,39 +407,29 @@ func (sa *appointmentStore) GetAllAppointmentsByDentistsLicense(licenseNumber st
 			&appointment.DateAndTime,
 			&appointment.DentistLicense,
 			&appointment.PatientIdentity,
+			&appointment.Status,
+			&appointment.ProcedureType,
+			&appointment.DurationMinutes,
+			&appointment.EndDateAndTime,
+			&appointment.SeriesId,
+			&appointment.Version,
+			nullTime{&appointment.DeletedAt},
 			&appointment.Dentist.Id,
 			&appointment.Dentist.Surname,
 			&appointment.Dentist.Name,
 			&appointment.Dentist.LicenseNumber,
+			&appointment.Dentist.Version,
+			nullTime{&appointment.Dentist.DeletedAt},
 			&appointment.Patient.Id,
 			&appointment.Patient.Surname,
 			&appointment.Patient.Name,
 			&appointment.Patient.IdentityNumber,
-			&appointment.Patient.CreatedAt); err != nil {
-			return appointments, err
-		}
-		appointments = append(appointments, appointment)
-	}
-	return appointments, nil
-}
-
-func (sa *appointmentStore) GetAllAppointmentsByDateTimeInterval(startDateTime, endDateTime string) ([]domain.Appointment, error) {
-	var appointment domain.Appointment
-	var appointments []domain.Appointment
-	rows, err := sa.db.Query("SELECT * FROM appointments WHERE date_and_time BETWEEN ? AND ?", startDateTime, endDateTime)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	for rows.Next() {
-		if err := rows.Scan(
-			&appointment.Id,
-			&appointment.Description,
-			&appointment.DateAndTime,
-			&appointment.DentistLicense,
-			&appointment.PatientIdentity); err != nil {
+			&appointment.Patient.CreatedAt,
+			&appointment.Patient.Version,
+			nullTime{&appointment.Patient.DeletedAt}); err != nil {
 			return appointments, err
 		}
 		appointments = append(appointments, appointment)
 	}
-	return appointments, nil
+	return appointments, rows.Err()
 }
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// TestFailingQueryReturnsError - a query failing used to end the process with
+// log.Fatalln, which would end this test binary too
+func TestFailingQueryReturnsError(t *testing.T) {
+	db, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:3306)/clinic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every query on a closed pool fails without reaching a server
+	db.Close()
+	s := &appointmentStore{db: &conn{db: db}}
+
+	if _, err := s.GetAllAppointmentsByPatientIdentify(context.Background(), "123"); err == nil {
+		t.Error("GetAllAppointmentsByPatientIdentify: expected an error from the closed database")
+	}
+	if _, err := s.GetAllAppointmentsByDentistsLicense(context.Background(), "123"); err == nil {
+		t.Error("GetAllAppointmentsByDentistsLicense: expected an error from the closed database")
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+type auditMemoryStore struct {
+	*memoryStore
+}
+
+func (m *auditMemoryStore) SaveAuditEntry(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	m.lock()
+	defer m.unlock()
+
+	entry.Id = m.nextID("audit_log")
+	m.auditLog[entry.Id] = entry
+	return entry, nil
+}
+
+// ListAuditEntries - From and To bound at
+func (m *auditMemoryStore) ListAuditEntries(ctx context.Context, opts ListOptions) (Page[domain.AuditEntry], error) {
+	m.mu.RLock()
+	entries := make([]domain.AuditEntry, 0, len(m.auditLog))
+	for _, id := range sortedIDs(m.auditLog) {
+		entries = append(entries, m.auditLog[id])
+	}
+	m.mu.RUnlock()
+
+	return memList(entries, opts, auditColumns, "at", func(e domain.AuditEntry, column string) interface{} {
+		switch column {
+		case "id":
+			return e.Id
+		case "at", "date_and_time":
+			return e.At
+		case "entity":
+			return e.Entity
+		case "entity_id":
+			return e.EntityId
+		case "actor_id":
+			return e.ActorId
+		case "operation":
+			return e.Operation
+		}
+		return nil
+	})
+}
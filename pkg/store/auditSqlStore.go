@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+const auditQuery = "SELECT id, actor_id, actor, at, entity, entity_id, operation, changes FROM audit_log"
+
+type auditSQLStore struct {
+	db *conn
+}
+
+func (s *auditSQLStore) SaveAuditEntry(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return domain.AuditEntry{}, err
+	}
+	var actorID sql.NullInt64
+	if entry.ActorId != 0 {
+		actorID = sql.NullInt64{Int64: int64(entry.ActorId), Valid: true}
+	}
+	result, err := s.db.ExecContext(ctx, "INSERT INTO audit_log(actor_id, actor, at, entity, entity_id, operation, changes) VALUES (?,?,?,?,?,?,?)",
+		actorID,
+		entry.Actor,
+		entry.At,
+		entry.Entity,
+		entry.EntityId,
+		entry.Operation,
+		changes)
+	if err != nil {
+		return domain.AuditEntry{}, err
+	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return domain.AuditEntry{}, err
+	}
+	entry.Id = int(lastInsertedID)
+	return entry, nil
+}
+
+// ListAuditEntries - From and To bound at
+func (s *auditSQLStore) ListAuditEntries(ctx context.Context, opts ListOptions) (Page[domain.AuditEntry], error) {
+	// the audit log has no deleted rows
+	opts.IncludeDeleted = true
+	list, err := opts.sqlListClauses(auditColumns, "at", "", "at")
+	if err != nil {
+		return Page[domain.AuditEntry]{}, err
+	}
+
+	var page Page[domain.AuditEntry]
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+list.where, list.args...).Scan(&page.Total); err != nil {
+		return Page[domain.AuditEntry]{}, err
+	}
+	rows, err := s.db.QueryContext(ctx, auditQuery+list.where+list.orderBy, list.args...)
+	if err != nil {
+		return Page[domain.AuditEntry]{}, err
+	}
+	defer rows.Close()
+
+	page.Items = []domain.AuditEntry{}
+	for rows.Next() {
+		var entry domain.AuditEntry
+		var actorID sql.NullInt64
+		var changes []byte
+		if err := rows.Scan(&entry.Id, &actorID, &entry.Actor, &entry.At, &entry.Entity, &entry.EntityId, &entry.Operation, &changes); err != nil {
+			return Page[domain.AuditEntry]{}, err
+		}
+		entry.ActorId = int(actorID.Int64)
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return Page[domain.AuditEntry]{}, err
+		}
+		page.Items = append(page.Items, entry)
+	}
+	return page, rows.Err()
+}
@@ -0,0 +1,274 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// AuditedStore - a Store whose dentists, patients and appointments record every
+// change in the audit log
+type AuditedStore interface {
+	Store
+	Appointments() ApStore
+}
+
+// NewAuditedStore - decorates s and appointments, the appointments of s reached
+// through its units of work. Each change and its audit entry are written in the
+// same transaction, so neither is kept without the other.
+func NewAuditedStore(s Store, appointments ApStore) AuditedStore {
+	return &auditedStore{Store: s, appointments: appointments}
+}
+
+type auditedStore struct {
+	Store
+	appointments ApStore
+}
+
+func (s *auditedStore) Dentists() DentistStore {
+	return &auditedDentists{s.Store.Dentists(), dentistsAuditor(s.Store.WithTx)}
+}
+
+func (s *auditedStore) Patients() Repository[domain.Patient] {
+	return &auditedPatients{s.Store.Patients(), patientsAuditor(s.Store.WithTx)}
+}
+
+func (s *auditedStore) Appointments() ApStore {
+	return &auditedAppointments{s.appointments, appointmentsAuditor(s.Store.WithTx)}
+}
+
+// WithTx - fn receives the stores of the transaction, decorated too
+func (s *auditedStore) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return s.Store.WithTx(ctx, func(tx Tx) error {
+		return fn(&auditedTx{tx})
+	})
+}
+
+// auditedTx - the stores of a unit of work, their changes are recorded in its
+// transaction
+type auditedTx struct {
+	Tx
+}
+
+// within - runs fn with the undecorated stores of the transaction tx is bound to
+func (tx *auditedTx) within(ctx context.Context, fn func(tx Tx) error) error {
+	return fn(tx.Tx)
+}
+
+func (tx *auditedTx) Dentists() DentistStore {
+	return &auditedDentists{tx.Tx.Dentists(), dentistsAuditor(tx.within)}
+}
+
+func (tx *auditedTx) Patients() Repository[domain.Patient] {
+	return &auditedPatients{tx.Tx.Patients(), patientsAuditor(tx.within)}
+}
+
+func (tx *auditedTx) Appointments() ApStore {
+	return &auditedAppointments{tx.Tx.Appointments(), appointmentsAuditor(tx.within)}
+}
+
+func dentistsAuditor(unit unitOfWork) *auditor[domain.Dentist] {
+	return &auditor[domain.Dentist]{
+		entity: domain.AuditDentists,
+		unit:   unit,
+		store:  func(tx Tx) Repository[domain.Dentist] { return tx.Dentists() },
+		id:     func(d domain.Dentist) int { return d.Id },
+	}
+}
+
+func patientsAuditor(unit unitOfWork) *auditor[domain.Patient] {
+	return &auditor[domain.Patient]{
+		entity: domain.AuditPatients,
+		unit:   unit,
+		store:  func(tx Tx) Repository[domain.Patient] { return tx.Patients() },
+		id:     func(p domain.Patient) int { return p.Id },
+	}
+}
+
+// appointmentsAuditor - the dentist and the patient joined to an appointment
+// aren't part of its changes
+func appointmentsAuditor(unit unitOfWork) *auditor[domain.AppointmentDTO] {
+	return &auditor[domain.AppointmentDTO]{
+		entity:   domain.AuditAppointments,
+		unit:     unit,
+		store:    func(tx Tx) Repository[domain.AppointmentDTO] { return tx.Appointments() },
+		id:       func(a domain.AppointmentDTO) int { return a.Id },
+		snapshot: func(a domain.AppointmentDTO) interface{} { return a.Appointment },
+	}
+}
+
+type auditedDentists struct {
+	DentistStore
+	audit *auditor[domain.Dentist]
+}
+
+func (s *auditedDentists) Save(ctx context.Context, dentist domain.Dentist) (domain.Dentist, error) {
+	return s.audit.save(ctx, dentist)
+}
+
+func (s *auditedDentists) Update(ctx context.Context, entityID int, dentist domain.Dentist) (domain.Dentist, error) {
+	return s.audit.update(ctx, entityID, dentist)
+}
+
+func (s *auditedDentists) Delete(ctx context.Context, entityID int) error {
+	return s.audit.delete(ctx, entityID)
+}
+
+func (s *auditedDentists) Restore(ctx context.Context, entityID int) (domain.Dentist, error) {
+	return s.audit.restore(ctx, entityID)
+}
+
+func (s *auditedDentists) SetDeactivated(ctx context.Context, entityID int, at time.Time) (domain.Dentist, error) {
+	operation := domain.AuditDeactivate
+	if at.IsZero() {
+		operation = domain.AuditReactivate
+	}
+	return s.audit.change(ctx, entityID, operation, func(tx Tx) (domain.Dentist, error) {
+		return tx.Dentists().SetDeactivated(ctx, entityID, at)
+	})
+}
+
+type auditedPatients struct {
+	Repository[domain.Patient]
+	audit *auditor[domain.Patient]
+}
+
+func (s *auditedPatients) Save(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	return s.audit.save(ctx, patient)
+}
+
+func (s *auditedPatients) Update(ctx context.Context, entityID int, patient domain.Patient) (domain.Patient, error) {
+	return s.audit.update(ctx, entityID, patient)
+}
+
+func (s *auditedPatients) Delete(ctx context.Context, entityID int) error {
+	return s.audit.delete(ctx, entityID)
+}
+
+func (s *auditedPatients) Restore(ctx context.Context, entityID int) (domain.Patient, error) {
+	return s.audit.restore(ctx, entityID)
+}
+
+type auditedAppointments struct {
+	ApStore
+	audit *auditor[domain.AppointmentDTO]
+}
+
+func (s *auditedAppointments) Save(ctx context.Context, appointment domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+	return s.audit.save(ctx, appointment)
+}
+
+func (s *auditedAppointments) Update(ctx context.Context, entityID int, appointment domain.AppointmentDTO) (domain.AppointmentDTO, error) {
+	return s.audit.update(ctx, entityID, appointment)
+}
+
+func (s *auditedAppointments) Delete(ctx context.Context, entityID int) error {
+	return s.audit.delete(ctx, entityID)
+}
+
+func (s *auditedAppointments) Restore(ctx context.Context, entityID int) (domain.AppointmentDTO, error) {
+	return s.audit.restore(ctx, entityID)
+}
+
+func (s *auditedAppointments) ChangeStatus(ctx context.Context, change domain.StatusChange) (domain.AppointmentDTO, error) {
+	return s.audit.change(ctx, change.AppointmentId, domain.AuditChangeStatus, func(tx Tx) (domain.AppointmentDTO, error) {
+		return tx.Appointments().ChangeStatus(ctx, change)
+	})
+}
+
+// unitOfWork - runs fn with the stores of a transaction
+type unitOfWork func(ctx context.Context, fn func(tx Tx) error) error
+
+// auditor - records the changes of the rows of one table. Purge isn't recorded,
+// it removes rows whose deletion already was.
+type auditor[T any] struct {
+	entity string
+	unit   unitOfWork
+	// store - the undecorated store of the table in a transaction
+	store func(tx Tx) Repository[T]
+	id    func(T) int
+	// snapshot - what of a row is compared, the row itself when nil
+	snapshot func(T) interface{}
+}
+
+func (a *auditor[T]) save(ctx context.Context, entity T) (T, error) {
+	var saved T
+	err := a.unit(ctx, func(tx Tx) error {
+		var err error
+		if saved, err = a.store(tx).Save(ctx, entity); err != nil {
+			return err
+		}
+		return a.record(ctx, tx, a.id(saved), domain.AuditCreate, nil, &saved)
+	})
+	return saved, err
+}
+
+func (a *auditor[T]) update(ctx context.Context, entityID int, entity T) (T, error) {
+	return a.change(ctx, entityID, domain.AuditUpdate, func(tx Tx) (T, error) {
+		return a.store(tx).Update(ctx, entityID, entity)
+	})
+}
+
+func (a *auditor[T]) delete(ctx context.Context, entityID int) error {
+	return a.unit(ctx, func(tx Tx) error {
+		before, err := a.store(tx).GetByID(ctx, entityID)
+		if err != nil {
+			return err
+		}
+		if err := a.store(tx).Delete(ctx, entityID); err != nil {
+			return err
+		}
+		return a.record(ctx, tx, entityID, domain.AuditDelete, &before, nil)
+	})
+}
+
+func (a *auditor[T]) restore(ctx context.Context, entityID int) (T, error) {
+	var restored T
+	err := a.unit(ctx, func(tx Tx) error {
+		var err error
+		if restored, err = a.store(tx).Restore(ctx, entityID); err != nil {
+			return err
+		}
+		return a.record(ctx, tx, entityID, domain.AuditRestore, nil, &restored)
+	})
+	return restored, err
+}
+
+// change - runs fn, which changes the row entityID, and records the row before
+// and after
+func (a *auditor[T]) change(ctx context.Context, entityID int, operation domain.AuditOperation, fn func(tx Tx) (T, error)) (T, error) {
+	var after T
+	err := a.unit(ctx, func(tx Tx) error {
+		before, err := a.store(tx).GetByID(ctx, entityID)
+		if err != nil {
+			return err
+		}
+		if after, err = fn(tx); err != nil {
+			return err
+		}
+		return a.record(ctx, tx, entityID, operation, &before, &after)
+	})
+	return after, err
+}
+
+// record - saves the audit entry of an operation, a nil before or after is a row
+// which didn't exist or doesn't anymore
+func (a *auditor[T]) record(ctx context.Context, tx Tx, entityID int, operation domain.AuditOperation, before, after *T) error {
+	entry, err := domain.NewAuditEntry(ctx, a.entity, entityID, operation, a.compared(before), a.compared(after))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Audit().SaveAuditEntry(ctx, entry)
+	return err
+}
+
+func (a *auditor[T]) compared(row *T) interface{} {
+	if row == nil {
+		return nil
+	}
+	if a.snapshot != nil {
+		return a.snapshot(*row)
+	}
+	return *row
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+type calendarTokenKey struct {
+	owner   domain.CalendarOwner
+	ownerID int
+}
+
+type calendarTokenMemoryStore struct {
+	*memoryStore
+}
+
+func (m *calendarTokenMemoryStore) GetCalendarToken(ctx context.Context, owner domain.CalendarOwner, ownerID int) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	token, ok := m.calendarTokens[calendarTokenKey{owner, ownerID}]
+	if !ok {
+		return "", fmt.Errorf("%w: calendar token", domain.ErrNotFound)
+	}
+	return token, nil
+}
+
+func (m *calendarTokenMemoryStore) SaveCalendarToken(ctx context.Context, owner domain.CalendarOwner, ownerID int, token string) error {
+	m.lock()
+	defer m.unlock()
+
+	m.calendarTokens[calendarTokenKey{owner, ownerID}] = token
+	return nil
+}
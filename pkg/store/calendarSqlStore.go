@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+type calendarTokenSQLStore struct {
+	db *conn
+}
+
+func (s *calendarTokenSQLStore) GetCalendarToken(ctx context.Context, owner domain.CalendarOwner, ownerID int) (string, error) {
+	var token string
+	err := s.db.QueryRowContext(ctx, "SELECT token FROM calendar_tokens WHERE owner = ? AND owner_id = ?", owner, ownerID).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: calendar token", domain.ErrNotFound)
+	}
+	return token, err
+}
+
+func (s *calendarTokenSQLStore) SaveCalendarToken(ctx context.Context, owner domain.CalendarOwner, ownerID int, token string) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO calendar_tokens(owner, owner_id, token, created_at) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE token = VALUES(token), created_at = VALUES(created_at)",
+		owner,
+		ownerID,
+		token,
+		time.Now())
+	return err
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// MySQL error numbers translated to domain errors
+const (
+	mysqlDuplicateEntry   = 1062
+	mysqlRowIsReferenced  = 1451
+	mysqlNoReferencedRow  = 1452
+	mysqlRowIsReferenced2 = 1217
+	mysqlNoReferencedRow2 = 1216
+)
+
+// errOverlap - returned by Save and Update when the appointment overlaps another
+// active appointment of the dentist or the patient
+var errOverlap = fmt.Errorf("%w: overlaps another appointment of the dentist or the patient", domain.ErrSlotUnavailable)
+
+// duplicateErrors - the domain error of a duplicate entry, by the unique column
+var duplicateErrors = map[string]error{
+	"license_number":  domain.ErrDuplicateLicense,
+	"identity_number": domain.ErrDuplicateIdentity,
+	"email":           domain.ErrDuplicateEmail,
+}
+
+// sqlError - translates the constraint violations reported by MySQL to the domain
+// errors the memory store returns, the other errors are returned as they are
+func sqlError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+	switch mysqlErr.Number {
+	case mysqlDuplicateEntry:
+		for column, duplicate := range duplicateErrors {
+			if strings.Contains(mysqlErr.Message, column) {
+				return duplicate
+			}
+		}
+		return &domain.ConflictError{Code: "duplicate_entry", Message: mysqlErr.Message}
+	case mysqlRowIsReferenced, mysqlRowIsReferenced2:
+		return fmt.Errorf("%w: the entity is referenced by other records", domain.ErrInUse)
+	case mysqlNoReferencedRow, mysqlNoReferencedRow2:
+		column := foreignKeyColumn(mysqlErr.Message)
+		return domain.NewValidationError(column, "exists", "doesn't reference an existing record")
+	}
+	return err
+}
+
+// foreignKeyColumn - reads the column of the constraint from the message of MySQL:
+// ... CONSTRAINT `fk` FOREIGN KEY (`dentist_license`) REFERENCES ...
+func foreignKeyColumn(message string) string {
+	_, rest, found := strings.Cut(message, "FOREIGN KEY (`")
+	if !found {
+		return ""
+	}
+	column, _, _ := strings.Cut(rest, "`")
+	return column
+}
@@ -0,0 +1,273 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// ListOptions - pagination, sorting and filters applied by Repository.List
+type ListOptions struct {
+	Limit  int
+	Offset int
+	// Sort is the name of a sortable column, prefixed with "-" for descending order
+	Sort string
+	// Filters maps filterable columns to the value searched
+	Filters map[string]string
+	// From and To bound appointments date_and_time, zero values are ignored
+	From time.Time
+	To   time.Time
+	// IncludeDeleted - lists the deleted rows too
+	IncludeDeleted bool
+}
+
+// Page - a page of rows and the total of rows matching the filters
+type Page[T any] struct {
+	Items []T
+	Total int
+}
+
+type filterMode int
+
+const (
+	noFilter filterMode = iota
+	exactFilter
+	partialFilter
+	// subqueryFilter - matches rows related to the value through another table, the
+	// memory stores return every related value of a row as a []string
+	subqueryFilter
+)
+
+// listColumn - describes how a column can be used by sort and filter parameters
+type listColumn struct {
+	sortable bool
+	filter   filterMode
+	// condition - the SQL condition of a subqueryFilter, %s is replaced by the alias
+	condition string
+}
+
+var dentistColumns = map[string]listColumn{
+	"id":             {sortable: true},
+	"surname":        {sortable: true, filter: partialFilter},
+	"name":           {sortable: true, filter: partialFilter},
+	"license_number": {sortable: true, filter: exactFilter},
+}
+
+var patientColumns = map[string]listColumn{
+	"id":              {sortable: true},
+	"surname":         {sortable: true, filter: partialFilter},
+	"name":            {sortable: true, filter: partialFilter},
+	"identity_number": {sortable: true, filter: exactFilter},
+	"created_at":      {sortable: true},
+	// dentist_license - the patients with appointments with the dentist
+	"dentist_license": {filter: subqueryFilter, condition: "%sidentity_number IN (SELECT patient_identity FROM appointments WHERE dentist_license = ? AND deleted_at IS NULL)"},
+}
+
+var appointmentColumns = map[string]listColumn{
+	"id":               {sortable: true},
+	"description":      {filter: partialFilter},
+	"date_and_time":    {sortable: true},
+	"dentist_license":  {sortable: true, filter: exactFilter},
+	"patient_identity": {sortable: true, filter: exactFilter},
+}
+
+var auditColumns = map[string]listColumn{
+	"id":        {sortable: true},
+	"at":        {sortable: true},
+	"entity":    {filter: exactFilter},
+	"entity_id": {filter: exactFilter},
+	"actor_id":  {filter: exactFilter},
+	"operation": {filter: exactFilter},
+}
+
+// sortColumn - validates the sort and filter columns and returns the sort column
+// and direction, falling back to defaultSort
+func (o ListOptions) sortColumn(columns map[string]listColumn, defaultSort string) (string, bool, error) {
+	for column := range o.Filters {
+		if columns[column].filter == noFilter {
+			return "", false, domain.NewValidationError(column, "filter", fmt.Sprintf("can't filter by %q", column))
+		}
+	}
+
+	column, desc := strings.TrimPrefix(o.Sort, "-"), strings.HasPrefix(o.Sort, "-")
+	if column == "" {
+		return defaultSort, desc, nil
+	}
+	if !columns[column].sortable {
+		return "", false, domain.NewValidationError("sort", "oneof", fmt.Sprintf("can't sort by %q", column))
+	}
+	return column, desc, nil
+}
+
+// sqlList - the WHERE clause (with its arguments) and the ORDER BY and LIMIT
+// clauses of a list query
+type sqlList struct {
+	where   string
+	args    []interface{}
+	orderBy string
+}
+
+// likeEscaper - escapes the wildcards of a LIKE pattern, so the partial filters
+// search the value as typed
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// sqlListClauses - builds the clauses of a list query, prefixing every column with
+// alias, dateColumn is the column bounded by From and To
+func (o ListOptions) sqlListClauses(columns map[string]listColumn, defaultSort, alias, dateColumn string) (sqlList, error) {
+	sortBy, desc, err := o.sortColumn(columns, defaultSort)
+	if err != nil {
+		return sqlList{}, err
+	}
+
+	var list sqlList
+	var conditions []string
+	if !o.IncludeDeleted {
+		conditions = append(conditions, alias+"deleted_at IS NULL")
+	}
+	for _, column := range sortedKeys(o.Filters) {
+		if columns[column].filter == subqueryFilter {
+			conditions = append(conditions, fmt.Sprintf(columns[column].condition, alias))
+			list.args = append(list.args, o.Filters[column])
+			continue
+		}
+		if columns[column].filter == partialFilter {
+			conditions = append(conditions, alias+column+` LIKE ? ESCAPE '\\'`)
+			list.args = append(list.args, "%"+likeEscaper.Replace(o.Filters[column])+"%")
+			continue
+		}
+		conditions = append(conditions, alias+column+" = ?")
+		list.args = append(list.args, o.Filters[column])
+	}
+	if !o.From.IsZero() {
+		conditions = append(conditions, alias+dateColumn+" >= ?")
+		list.args = append(list.args, o.From)
+	}
+	if !o.To.IsZero() {
+		conditions = append(conditions, alias+dateColumn+" <= ?")
+		list.args = append(list.args, o.To)
+	}
+	if len(conditions) > 0 {
+		list.where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	list.orderBy = fmt.Sprintf(" ORDER BY %s%s %s, %sid %s", alias, sortBy, direction, alias, direction)
+	if o.Limit > 0 {
+		list.orderBy += fmt.Sprintf(" LIMIT %d OFFSET %d", o.Limit, o.Offset)
+	}
+	return list, nil
+}
+
+// memList - filters, sorts and paginates rows the same way the SQL stores do,
+// value returns the content of a column of a row, deleted_at included
+func memList[T any](rows []T, o ListOptions, columns map[string]listColumn, defaultSort string, value func(T, string) interface{}) (Page[T], error) {
+	sortBy, desc, err := o.sortColumn(columns, defaultSort)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	filtered := make([]T, 0, len(rows))
+	for _, row := range rows {
+		if memMatches(row, o, columns, value) {
+			filtered = append(filtered, row)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		c := compareValues(value(filtered[i], sortBy), value(filtered[j], sortBy))
+		if c == 0 {
+			c = compareValues(value(filtered[i], "id"), value(filtered[j], "id"))
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	page := Page[T]{Items: []T{}, Total: len(filtered)}
+	if o.Offset < len(filtered) {
+		end := len(filtered)
+		if o.Limit > 0 && o.Offset+o.Limit < end {
+			end = o.Offset + o.Limit
+		}
+		page.Items = filtered[o.Offset:end]
+	}
+	return page, nil
+}
+
+func memMatches[T any](row T, o ListOptions, columns map[string]listColumn, value func(T, string) interface{}) bool {
+	if deletedAt, _ := value(row, "deleted_at").(time.Time); !o.IncludeDeleted && !deletedAt.IsZero() {
+		return false
+	}
+	for column, filter := range o.Filters {
+		if columns[column].filter == subqueryFilter {
+			related, _ := value(row, column).([]string)
+			if !containsString(related, filter) {
+				return false
+			}
+			continue
+		}
+		content := fmt.Sprint(value(row, column))
+		if columns[column].filter == partialFilter {
+			if !strings.Contains(strings.ToLower(content), strings.ToLower(filter)) {
+				return false
+			}
+			continue
+		}
+		if content != filter {
+			return false
+		}
+	}
+	if !o.From.IsZero() || !o.To.IsZero() {
+		date, _ := value(row, "date_and_time").(time.Time)
+		if !o.From.IsZero() && date.Before(o.From) {
+			return false
+		}
+		if !o.To.IsZero() && date.After(o.To) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		b, _ := b.(int)
+		return a - b
+	case time.Time:
+		b, _ := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+		return 0
+	default:
+		return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+	}
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
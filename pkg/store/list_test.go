@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// TestPartialFilterEscapesWildcards - % and _ typed in a partial filter are searched
+// as they are, not as the wildcards of LIKE
+func TestPartialFilterEscapesWildcards(t *testing.T) {
+	opts := ListOptions{Filters: map[string]string{"name": `50%_off\`}}
+	list, err := opts.sqlListClauses(dentistColumns, "id", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(list.where, `name LIKE ? ESCAPE '\\'`) {
+		t.Errorf("where %s doesn't declare the escape character", list.where)
+	}
+	if len(list.args) != 1 || list.args[0] != `%50\%\_off\\%` {
+		t.Errorf("args %q, want the wildcards escaped", list.args)
+	}
+
+	ctx := context.Background()
+	dentists := NewMemoryStore().Dentists()
+	for _, name := range []string{"Ana", "An_a", "100%"} {
+		if _, err := dentists.Save(ctx, domain.Dentist{Surname: "Silva", Name: name, LicenseNumber: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for filter, want := range map[string]int{"%": 1, "_": 1, "an": 2} {
+		page, err := dentists.List(ctx, ListOptions{Filters: map[string]string{"name": filter}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != want {
+			t.Errorf("name %q: %d dentists, want %d", filter, page.Total, want)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// NewMemoryStore - returns a thread-safe in-memory backend, which can be used
+// in place of the MySQL stores when there's no database. The appointments
+// table is reached through Appointments so it can join dentists and patients.
+func NewMemoryStore() *memoryStore {
+	return &memoryStore{memoryTables: &memoryTables{
+		lastID:         map[string]int{},
+		dentists:       map[int]domain.Dentist{},
+		patients:       map[int]domain.Patient{},
+		appointments:   map[int]domain.Appointment{},
+		schedules:      map[int]domain.Schedule{},
+		timeOff:        map[int]domain.TimeOff{},
+		statusChanges:  map[int]domain.StatusChange{},
+		series:         map[int]domain.AppointmentSeries{},
+		calendarTokens: map[calendarTokenKey]string{},
+		users:          map[int]domain.User{},
+		auditLog:       map[int]domain.AuditEntry{},
+	}}
+}
+
+type memoryStore struct {
+	*memoryTables
+	// unit - the stores of a unit of work, which holds txMu
+	unit bool
+}
+
+// memoryTables - the maps shared by the memory stores and their units of work
+type memoryTables struct {
+	mu sync.RWMutex
+	// txMu - runs the units of work one at a time, the writes made outside them
+	// wait for them too
+	txMu           sync.Mutex
+	lastID         map[string]int
+	dentists       map[int]domain.Dentist
+	patients       map[int]domain.Patient
+	appointments   map[int]domain.Appointment
+	schedules      map[int]domain.Schedule
+	timeOff        map[int]domain.TimeOff
+	statusChanges  map[int]domain.StatusChange
+	series         map[int]domain.AppointmentSeries
+	calendarTokens map[calendarTokenKey]string
+	users          map[int]domain.User
+	auditLog       map[int]domain.AuditEntry
+}
+
+func (m *memoryStore) Dentists() DentistStore {
+	return &dentistMemoryStore{m}
+}
+
+func (m *memoryStore) Patients() Repository[domain.Patient] {
+	return &patientMemoryStore{m}
+}
+
+func (m *memoryStore) Schedules() ScheduleStore {
+	return &scheduleMemoryStore{m}
+}
+
+func (m *memoryStore) CalendarTokens() CalendarTokenStore {
+	return &calendarTokenMemoryStore{m}
+}
+
+func (m *memoryStore) Users() UserStore {
+	return &userMemoryStore{m}
+}
+
+func (m *memoryStore) Audit() AuditStore {
+	return &auditMemoryStore{m}
+}
+
+func (m *memoryStore) Appointments() ApStore {
+	return &appointmentMemoryStore{m}
+}
+
+// WithTx - the units of work run one at a time over the same maps, the maps are
+// restored as they were before a failing one. The writes made outside a unit of
+// work wait for it, so only its own are undone. A unit of work started in another
+// one is part of it.
+func (m *memoryStore) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	if m.unit {
+		return fn(m)
+	}
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	unit := &memoryStore{memoryTables: m.memoryTables, unit: true}
+	rollback := unit.snapshot()
+	if err := fn(unit); err != nil {
+		rollback()
+		return err
+	}
+	return nil
+}
+
+// lock - locks the maps for a write, outside a unit of work once the running one
+// is done
+func (m *memoryStore) lock() {
+	if !m.unit {
+		m.txMu.Lock()
+	}
+	m.mu.Lock()
+}
+
+func (m *memoryStore) unlock() {
+	m.mu.Unlock()
+	if !m.unit {
+		m.txMu.Unlock()
+	}
+}
+
+// snapshot - copies the maps, the function returned puts the copies back
+func (m *memoryStore) snapshot() func() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lastID := maps.Clone(m.lastID)
+	dentists := maps.Clone(m.dentists)
+	patients := maps.Clone(m.patients)
+	appointments := maps.Clone(m.appointments)
+	schedules := maps.Clone(m.schedules)
+	timeOff := maps.Clone(m.timeOff)
+	statusChanges := maps.Clone(m.statusChanges)
+	series := maps.Clone(m.series)
+	calendarTokens := maps.Clone(m.calendarTokens)
+	users := maps.Clone(m.users)
+	auditLog := maps.Clone(m.auditLog)
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		m.lastID = lastID
+		m.dentists = dentists
+		m.patients = patients
+		m.appointments = appointments
+		m.schedules = schedules
+		m.timeOff = timeOff
+		m.statusChanges = statusChanges
+		m.series = series
+		m.calendarTokens = calendarTokens
+		m.users = users
+		m.auditLog = auditLog
+	}
+}
+
+type dentistMemoryStore struct {
+	*memoryStore
+}
+
+// GetAll - returns all dentists ordered by ID
+func (m *dentistMemoryStore) GetAll(ctx context.Context) ([]domain.Dentist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var dentists []domain.Dentist
+	for _, id := range sortedIDs(m.dentists) {
+		if m.dentists[id].DeletedAt.IsZero() {
+			dentists = append(dentists, m.dentists[id])
+		}
+	}
+	return dentists, nil
+}
+
+// List - returns a page of dentists
+func (m *dentistMemoryStore) List(ctx context.Context, opts ListOptions) (Page[domain.Dentist], error) {
+	m.mu.RLock()
+	dentists := make([]domain.Dentist, 0, len(m.dentists))
+	for _, id := range sortedIDs(m.dentists) {
+		dentists = append(dentists, m.dentists[id])
+	}
+	m.mu.RUnlock()
+
+	return memList(dentists, opts, dentistColumns, "id", func(d domain.Dentist, column string) interface{} {
+		switch column {
+		case "id":
+			return d.Id
+		case "surname":
+			return d.Surname
+		case "name":
+			return d.Name
+		case "license_number":
+			return d.LicenseNumber
+		case "deleted_at":
+			return d.DeletedAt
+		}
+		return nil
+	})
+}
+
+// GetByID - returns a dentist by ID
+func (m *dentistMemoryStore) GetByID(ctx context.Context, entityID int) (domain.Dentist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dentist, ok := m.dentists[entityID]
+	if !ok || !dentist.DeletedAt.IsZero() {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %d", domain.ErrNotFound, entityID)
+	}
+	return dentist, nil
+}
+
+// Save
+func (m *dentistMemoryStore) Save(ctx context.Context, dentist domain.Dentist) (domain.Dentist, error) {
+	m.lock()
+	defer m.unlock()
+
+	if m.dentistByLicense(dentist.LicenseNumber) != nil {
+		return domain.Dentist{}, domain.ErrDuplicateLicense
+	}
+	dentist.Id = m.nextID("dentists")
+	dentist.Version = 1
+	m.dentists[dentist.Id] = dentist
+	return dentist, nil
+}
+
+// Update - updates the dentist at dentist.Version
+func (m *dentistMemoryStore) Update(ctx context.Context, entityID int, dentist domain.Dentist) (domain.Dentist, error) {
+	m.lock()
+	defer m.unlock()
+
+	current, ok := m.dentists[entityID]
+	if !ok || !current.DeletedAt.IsZero() {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %d", domain.ErrNotFound, entityID)
+	}
+	if current.Version != dentist.Version {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %d is at version %d", domain.ErrVersionMismatch, entityID, current.Version)
+	}
+	if other := m.dentistByLicense(dentist.LicenseNumber); other != nil && other.Id != entityID {
+		return domain.Dentist{}, domain.ErrDuplicateLicense
+	}
+	if dentist.LicenseNumber != current.LicenseNumber && m.hasAppointments(func(a domain.Appointment) bool {
+		return a.DentistLicense == current.LicenseNumber
+	}) {
+		return domain.Dentist{}, fmt.Errorf("%w: license_number is referenced by appointments", domain.ErrInUse)
+	}
+	dentist.Id = entityID
+	// the deactivation only changes through SetDeactivated
+	dentist.DeactivatedAt = current.DeactivatedAt
+	dentist.Version = current.Version + 1
+	m.dentists[entityID] = dentist
+	return dentist, nil
+}
+
+// Delete - a dentist with open appointments can't be deleted
+func (m *dentistMemoryStore) Delete(ctx context.Context, entityID int) error {
+	m.lock()
+	defer m.unlock()
+
+	dentist, ok := m.dentists[entityID]
+	if !ok || !dentist.DeletedAt.IsZero() {
+		return fmt.Errorf("%w: dentist %d", domain.ErrNotFound, entityID)
+	}
+	if m.hasAppointments(func(a domain.Appointment) bool { return a.DentistLicense == dentist.LicenseNumber && isOpen(a) }) {
+		return fmt.Errorf("%w: the dentist has open appointments", domain.ErrInUse)
+	}
+	dentist.DeletedAt = time.Now()
+	dentist.Version++
+	m.dentists[entityID] = dentist
+	return nil
+}
+
+// Restore
+func (m *dentistMemoryStore) Restore(ctx context.Context, entityID int) (domain.Dentist, error) {
+	m.lock()
+	defer m.unlock()
+
+	dentist, ok := m.dentists[entityID]
+	if !ok || dentist.DeletedAt.IsZero() {
+		return domain.Dentist{}, fmt.Errorf("%w: no deleted dentist %d", domain.ErrNotFound, entityID)
+	}
+	dentist.DeletedAt = time.Time{}
+	dentist.Version++
+	m.dentists[entityID] = dentist
+	return dentist, nil
+}
+
+// SetDeactivated - the zero time reactivates the dentist
+func (m *dentistMemoryStore) SetDeactivated(ctx context.Context, entityID int, at time.Time) (domain.Dentist, error) {
+	m.lock()
+	defer m.unlock()
+
+	dentist, ok := m.dentists[entityID]
+	if !ok || !dentist.DeletedAt.IsZero() {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %d", domain.ErrNotFound, entityID)
+	}
+	dentist.DeactivatedAt = at
+	dentist.Version++
+	m.dentists[entityID] = dentist
+	return dentist, nil
+}
+
+// Purge - the schedule, time off and users of the dentists are removed along
+func (m *dentistMemoryStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	purged := 0
+	for id, dentist := range m.dentists {
+		if !isPurgeable(dentist.DeletedAt, before) || m.hasAppointments(func(a domain.Appointment) bool { return a.DentistLicense == dentist.LicenseNumber }) {
+			continue
+		}
+		delete(m.dentists, id)
+		delete(m.schedules, id)
+		for timeOffID, timeOff := range m.timeOff {
+			if timeOff.DentistId == id {
+				delete(m.timeOff, timeOffID)
+			}
+		}
+		for userID, user := range m.users {
+			if user.DentistId == id {
+				delete(m.users, userID)
+			}
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+type patientMemoryStore struct {
+	*memoryStore
+}
+
+// GetAll - returns all patients ordered by ID
+func (m *patientMemoryStore) GetAll(ctx context.Context) ([]domain.Patient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var patients []domain.Patient
+	for _, id := range sortedIDs(m.patients) {
+		if m.patients[id].DeletedAt.IsZero() {
+			patients = append(patients, m.patients[id])
+		}
+	}
+	return patients, nil
+}
+
+// List - returns a page of patients
+func (m *patientMemoryStore) List(ctx context.Context, opts ListOptions) (Page[domain.Patient], error) {
+	m.mu.RLock()
+	patients := make([]domain.Patient, 0, len(m.patients))
+	for _, id := range sortedIDs(m.patients) {
+		patients = append(patients, m.patients[id])
+	}
+	dentists := map[string][]string{}
+	for _, a := range m.appointments {
+		if a.DeletedAt.IsZero() {
+			dentists[a.PatientIdentity] = append(dentists[a.PatientIdentity], a.DentistLicense)
+		}
+	}
+	m.mu.RUnlock()
+
+	return memList(patients, opts, patientColumns, "id", func(p domain.Patient, column string) interface{} {
+		switch column {
+		case "id":
+			return p.Id
+		case "surname":
+			return p.Surname
+		case "name":
+			return p.Name
+		case "identity_number":
+			return p.IdentityNumber
+		case "created_at":
+			return p.CreatedAt
+		case "dentist_license":
+			return dentists[p.IdentityNumber]
+		case "deleted_at":
+			return p.DeletedAt
+		}
+		return nil
+	})
+}
+
+// GetByID - returns a patient by ID
+func (m *patientMemoryStore) GetByID(ctx context.Context, entityID int) (domain.Patient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	patient, ok := m.patients[entityID]
+	if !ok || !patient.DeletedAt.IsZero() {
+		return domain.Patient{}, fmt.Errorf("%w: patient %d", domain.ErrNotFound, entityID)
+	}
+	return patient, nil
+}
+
+// Save
+func (m *patientMemoryStore) Save(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	m.lock()
+	defer m.unlock()
+
+	if m.patientByIdentity(patient.IdentityNumber) != nil {
+		return domain.Patient{}, domain.ErrDuplicateIdentity
+	}
+	patient.Id = m.nextID("patients")
+	patient.Version = 1
+	m.patients[patient.Id] = patient
+	return patient, nil
+}
+
+// Update - updates the patient at patient.Version
+func (m *patientMemoryStore) Update(ctx context.Context, entityID int, patient domain.Patient) (domain.Patient, error) {
+	m.lock()
+	defer m.unlock()
+
+	current, ok := m.patients[entityID]
+	if !ok || !current.DeletedAt.IsZero() {
+		return domain.Patient{}, fmt.Errorf("%w: patient %d", domain.ErrNotFound, entityID)
+	}
+	if current.Version != patient.Version {
+		return domain.Patient{}, fmt.Errorf("%w: patient %d is at version %d", domain.ErrVersionMismatch, entityID, current.Version)
+	}
+	if other := m.patientByIdentity(patient.IdentityNumber); other != nil && other.Id != entityID {
+		return domain.Patient{}, domain.ErrDuplicateIdentity
+	}
+	if patient.IdentityNumber != current.IdentityNumber && m.hasAppointments(func(a domain.Appointment) bool {
+		return a.PatientIdentity == current.IdentityNumber
+	}) {
+		return domain.Patient{}, fmt.Errorf("%w: identity_number is referenced by appointments", domain.ErrInUse)
+	}
+	patient.Id = entityID
+	patient.Version = current.Version + 1
+	m.patients[entityID] = patient
+	return patient, nil
+}
+
+// Delete - a patient with open appointments can't be deleted
+func (m *patientMemoryStore) Delete(ctx context.Context, entityID int) error {
+	m.lock()
+	defer m.unlock()
+
+	patient, ok := m.patients[entityID]
+	if !ok || !patient.DeletedAt.IsZero() {
+		return fmt.Errorf("%w: patient %d", domain.ErrNotFound, entityID)
+	}
+	if m.hasAppointments(func(a domain.Appointment) bool { return a.PatientIdentity == patient.IdentityNumber && isOpen(a) }) {
+		return fmt.Errorf("%w: the patient has open appointments", domain.ErrInUse)
+	}
+	patient.DeletedAt = time.Now()
+	patient.Version++
+	m.patients[entityID] = patient
+	return nil
+}
+
+// Restore
+func (m *patientMemoryStore) Restore(ctx context.Context, entityID int) (domain.Patient, error) {
+	m.lock()
+	defer m.unlock()
+
+	patient, ok := m.patients[entityID]
+	if !ok || patient.DeletedAt.IsZero() {
+		return domain.Patient{}, fmt.Errorf("%w: no deleted patient %d", domain.ErrNotFound, entityID)
+	}
+	patient.DeletedAt = time.Time{}
+	patient.Version++
+	m.patients[entityID] = patient
+	return patient, nil
+}
+
+// Purge - the users of the patients are removed along
+func (m *patientMemoryStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	purged := 0
+	for id, patient := range m.patients {
+		if !isPurgeable(patient.DeletedAt, before) || m.hasAppointments(func(a domain.Appointment) bool { return a.PatientIdentity == patient.IdentityNumber }) {
+			continue
+		}
+		delete(m.patients, id)
+		for userID, user := range m.users {
+			if user.PatientId == id {
+				delete(m.users, userID)
+			}
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (m *memoryStore) dentistByLicense(licenseNumber string) *domain.Dentist {
+	for _, dentist := range m.dentists {
+		if dentist.LicenseNumber == licenseNumber {
+			return &dentist
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) patientByIdentity(identityNumber string) *domain.Patient {
+	for _, patient := range m.patients {
+		if patient.IdentityNumber == identityNumber {
+			return &patient
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) hasAppointments(filter func(domain.Appointment) bool) bool {
+	for _, appointment := range m.appointments {
+		if filter(appointment) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpen - tells if an appointment isn't deleted nor at a final status
+func isOpen(a domain.Appointment) bool {
+	return a.DeletedAt.IsZero() && !a.Status.IsFinal()
+}
+
+// isPurgeable - tells if a row deleted at deletedAt is past the retention ending at before
+func isPurgeable(deletedAt, before time.Time) bool {
+	return !deletedAt.IsZero() && deletedAt.Before(before)
+}
+
+// nextID - emulates an auto_increment column. Callers must hold the lock.
+func (m *memoryStore) nextID(tableName string) int {
+	m.lastID[tableName]++
+	return m.lastID[tableName]
+}
+
+func sortedIDs[T any](rows map[int]T) []int {
+	ids := make([]int, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// TestMemoryWithTxRollsBack - the writes of a failing unit of work are undone, the
+// ones of a committed unit of work are kept
+func TestMemoryWithTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	failure := errors.New("failure")
+
+	err := m.WithTx(ctx, func(tx Tx) error {
+		if _, err := tx.Dentists().Save(ctx, domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: "L1"}); err != nil {
+			t.Fatal(err)
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTx: expected the error of fn, got %v", err)
+	}
+	if dentists, _ := m.Dentists().GetAll(ctx); len(dentists) != 0 {
+		t.Fatalf("the dentist of the failing unit of work was kept: %v", dentists)
+	}
+
+	err = m.WithTx(ctx, func(tx Tx) error {
+		_, err := tx.Dentists().Save(ctx, domain.Dentist{Surname: "Silva", Name: "Ana", LicenseNumber: "L1"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dentists, _ := m.Dentists().GetAll(ctx)
+	if len(dentists) != 1 || dentists[0].Id != 1 {
+		t.Fatalf("expected the committed dentist with id 1, got %v", dentists)
+	}
+}
+
+// TestMemoryWithTxKeepsOtherWrites - a write made outside a failing unit of work,
+// while it runs, isn't undone by its rollback
+func TestMemoryWithTxKeepsOtherWrites(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	saved := make(chan error)
+
+	err := m.WithTx(ctx, func(tx Tx) error {
+		go func() {
+			_, err := m.Patients().Save(ctx, domain.Patient{Surname: "Lima", Name: "João", IdentityNumber: "P1", CreatedAt: time.Now()})
+			saved <- err
+		}()
+		// gives the write the time to run meanwhile if it doesn't wait
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("failure")
+	})
+	if err == nil {
+		t.Fatal("WithTx: expected the error of fn")
+	}
+	if err := <-saved; err != nil {
+		t.Fatal(err)
+	}
+	if patients, _ := m.Patients().GetAll(ctx); len(patients) != 1 {
+		t.Errorf("the patient saved outside the unit of work was lost: %v", patients)
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/pkg/metrics"
+)
+
+// queryTable - the first table a statement reads or writes
+var queryTable = regexp.MustCompile("(?i)\\b(?:from|into|update|join)\\s+`?(\\w+)")
+
+// queryLabels - the operation and table of the queries, parsed once per query
+var queryLabels sync.Map
+
+type queryLabel struct {
+	operation string
+	table     string
+}
+
+// observeQuery - records in m the time a query started at took
+func observeQuery(m *metrics.Metrics, query string, start time.Time) {
+	if m == nil {
+		return
+	}
+	label := labelQuery(query)
+	m.ObserveQuery(label.operation, label.table, time.Since(start))
+}
+
+// labelQuery - the operation is the first keyword of the query, such as select or
+// savepoint, the table is none for the statements without one
+func labelQuery(query string) queryLabel {
+	if label, ok := queryLabels.Load(query); ok {
+		return label.(queryLabel)
+	}
+	label := queryLabel{operation: "unknown", table: "none"}
+	if fields := strings.Fields(query); len(fields) > 0 {
+		label.operation = strings.ToLower(fields[0])
+	}
+	if match := queryTable.FindStringSubmatch(query); match != nil {
+		label.table = strings.ToLower(match[1])
+	}
+	queryLabels.Store(query, label)
+	return label
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+type scheduleMemoryStore struct {
+	*memoryStore
+}
+
+// GetSchedule - returns the weekly template of a dentist, ordered by weekday and start
+func (m *scheduleMemoryStore) GetSchedule(ctx context.Context, dentistID int) (domain.Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	schedule := domain.Schedule{
+		DentistId:    dentistID,
+		WorkingHours: []domain.WeeklyInterval{},
+		Breaks:       []domain.WeeklyInterval{},
+	}
+	if stored, ok := m.schedules[dentistID]; ok {
+		schedule.WorkingHours = append(schedule.WorkingHours, stored.WorkingHours...)
+		schedule.Breaks = append(schedule.Breaks, stored.Breaks...)
+	}
+	return schedule, nil
+}
+
+// SaveSchedule - replaces the whole weekly template of a dentist
+func (m *scheduleMemoryStore) SaveSchedule(ctx context.Context, schedule domain.Schedule) (domain.Schedule, error) {
+	m.lock()
+	if _, ok := m.dentists[schedule.DentistId]; !ok {
+		m.unlock()
+		return domain.Schedule{}, domain.NewValidationError("dentist_id", "exists", "doesn't reference a dentist")
+	}
+	stored := domain.Schedule{
+		DentistId:    schedule.DentistId,
+		WorkingHours: sortedIntervals(schedule.WorkingHours),
+		Breaks:       sortedIntervals(schedule.Breaks),
+	}
+	m.schedules[schedule.DentistId] = stored
+	m.unlock()
+
+	return m.GetSchedule(ctx, schedule.DentistId)
+}
+
+// GetAllTimeOff - returns the time off of a dentist ordered by start
+func (m *scheduleMemoryStore) GetAllTimeOff(ctx context.Context, dentistID int) ([]domain.TimeOff, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	allTimeOff := []domain.TimeOff{}
+	for _, id := range sortedIDs(m.timeOff) {
+		if m.timeOff[id].DentistId == dentistID {
+			allTimeOff = append(allTimeOff, m.timeOff[id])
+		}
+	}
+	sort.SliceStable(allTimeOff, func(i, j int) bool {
+		return allTimeOff[i].From.Before(allTimeOff[j].From)
+	})
+	return allTimeOff, nil
+}
+
+// SaveTimeOff
+func (m *scheduleMemoryStore) SaveTimeOff(ctx context.Context, timeOff domain.TimeOff) (domain.TimeOff, error) {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.dentists[timeOff.DentistId]; !ok {
+		return domain.TimeOff{}, domain.NewValidationError("dentist_id", "exists", "doesn't reference a dentist")
+	}
+	timeOff.Id = m.nextID("dentist_time_off")
+	m.timeOff[timeOff.Id] = timeOff
+	return timeOff, nil
+}
+
+// DeleteTimeOff
+func (m *scheduleMemoryStore) DeleteTimeOff(ctx context.Context, dentistID, timeOffID int) error {
+	m.lock()
+	defer m.unlock()
+
+	if timeOff, ok := m.timeOff[timeOffID]; !ok || timeOff.DentistId != dentistID {
+		return fmt.Errorf("%w: time off %d of dentist %d", domain.ErrNotFound, timeOffID, dentistID)
+	}
+	delete(m.timeOff, timeOffID)
+	return nil
+}
+
+func sortedIntervals(intervals []domain.WeeklyInterval) []domain.WeeklyInterval {
+	sorted := append([]domain.WeeklyInterval{}, intervals...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Weekday != sorted[j].Weekday {
+			return sorted[i].Weekday < sorted[j].Weekday
+		}
+		return sorted[i].Start < sorted[j].Start
+	})
+	return sorted
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+const (
+	workingInterval = "working"
+	breakInterval   = "break"
+)
+
+type scheduleSQLStore struct {
+	db *conn
+}
+
+// GetSchedule - returns the weekly template of a dentist, ordered by weekday and start
+func (s *scheduleSQLStore) GetSchedule(ctx context.Context, dentistID int) (domain.Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT kind, weekday, TIME_FORMAT(start_time,'%H:%i'), TIME_FORMAT(end_time,'%H:%i') FROM dentist_weekly_intervals WHERE dentist_id = ? ORDER BY weekday, start_time", dentistID)
+	if err != nil {
+		return domain.Schedule{}, err
+	}
+	defer rows.Close()
+
+	schedule := domain.Schedule{
+		DentistId:    dentistID,
+		WorkingHours: []domain.WeeklyInterval{},
+		Breaks:       []domain.WeeklyInterval{},
+	}
+	for rows.Next() {
+		var kind string
+		var interval domain.WeeklyInterval
+		if err := rows.Scan(&kind, &interval.Weekday, &interval.Start, &interval.End); err != nil {
+			return domain.Schedule{}, err
+		}
+		if kind == breakInterval {
+			schedule.Breaks = append(schedule.Breaks, interval)
+			continue
+		}
+		schedule.WorkingHours = append(schedule.WorkingHours, interval)
+	}
+	return schedule, rows.Err()
+}
+
+// SaveSchedule - replaces the whole weekly template of a dentist
+func (s *scheduleSQLStore) SaveSchedule(ctx context.Context, schedule domain.Schedule) (domain.Schedule, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return domain.Schedule{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM dentist_weekly_intervals WHERE dentist_id = ?", schedule.DentistId); err != nil {
+		return domain.Schedule{}, err
+	}
+	for kind, intervals := range map[string][]domain.WeeklyInterval{
+		workingInterval: schedule.WorkingHours,
+		breakInterval:   schedule.Breaks,
+	} {
+		for _, interval := range intervals {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO dentist_weekly_intervals(dentist_id, kind, weekday, start_time, end_time) VALUES (?,?,?,?,?)",
+				schedule.DentistId,
+				kind,
+				interval.Weekday,
+				interval.Start,
+				interval.End); err != nil {
+				return domain.Schedule{}, sqlError(err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Schedule{}, err
+	}
+	return s.GetSchedule(ctx, schedule.DentistId)
+}
+
+// GetAllTimeOff - returns the time off of a dentist ordered by start
+func (s *scheduleSQLStore) GetAllTimeOff(ctx context.Context, dentistID int) ([]domain.TimeOff, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, dentist_id, starts_at, ends_at, reason FROM dentist_time_off WHERE dentist_id = ? ORDER BY starts_at", dentistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timeOff domain.TimeOff
+	allTimeOff := []domain.TimeOff{}
+	for rows.Next() {
+		if err := rows.Scan(
+			&timeOff.Id,
+			&timeOff.DentistId,
+			&timeOff.From,
+			&timeOff.To,
+			&timeOff.Reason); err != nil {
+			return allTimeOff, err
+		}
+		allTimeOff = append(allTimeOff, timeOff)
+	}
+	return allTimeOff, rows.Err()
+}
+
+// SaveTimeOff
+func (s *scheduleSQLStore) SaveTimeOff(ctx context.Context, timeOff domain.TimeOff) (domain.TimeOff, error) {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO dentist_time_off(dentist_id, starts_at, ends_at, reason) VALUES (?,?,?,?)",
+		timeOff.DentistId,
+		timeOff.From,
+		timeOff.To,
+		timeOff.Reason)
+	if err != nil {
+		return domain.TimeOff{}, sqlError(err)
+	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return domain.TimeOff{}, err
+	}
+	timeOff.Id = int(lastInsertedID)
+	return timeOff, nil
+}
+
+// DeleteTimeOff
+func (s *scheduleSQLStore) DeleteTimeOff(ctx context.Context, dentistID, timeOffID int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM dentist_time_off WHERE id = ? AND dentist_id = ?", timeOffID, dentistID)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: time off %d of dentist %d", domain.ErrNotFound, timeOffID, dentistID)
+	}
+	return nil
+}
@@ -1,459 +1,490 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/mauriciogregory/esp_backIII_go/config"
 	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/metrics"
 )
 
-var (
-	AP = "appointments"
-	DE = "dentists"
-	PE = "patients"
-)
+const patientQuery = "SELECT p.id, p.surname,p.name,p.identity_number, p.created_at, p.version, p.deleted_at FROM patients p"
+
+const dentistQuery = "SELECT id, surname, name, license_number, version, deleted_at, deactivated_at FROM dentists"
+
+// NewSQLStore - the stores of the tables in db, whose pool is shared with the
+// appointments store. logger receives the writes of the stores at debug level, m
+// the duration of their queries.
+func NewSQLStore(db *sql.DB, logger *slog.Logger, m *metrics.Metrics) Store {
+	return &sqlStore{
+		db:     &conn{db: db, metrics: m},
+		logger: logger,
+	}
+}
+
+type sqlStore struct {
+	db     *conn
+	logger *slog.Logger
+}
+
+func (s *sqlStore) Dentists() DentistStore {
+	return &dentistSQLStore{db: s.db, logger: s.logger}
+}
 
-func init() {
-	config.LoadConfig()
+func (s *sqlStore) Patients() Repository[domain.Patient] {
+	return &patientSQLStore{db: s.db, logger: s.logger}
 }
 
-// NewSQLStore 
-func NewSQLStore() Store {
-	database, err := config.ConnectDatabase()
+func (s *sqlStore) Schedules() ScheduleStore {
+	return &scheduleSQLStore{db: s.db}
+}
+
+func (s *sqlStore) CalendarTokens() CalendarTokenStore {
+	return &calendarTokenSQLStore{db: s.db}
+}
+
+func (s *sqlStore) Users() UserStore {
+	return &userSQLStore{db: s.db}
+}
+
+func (s *sqlStore) Audit() AuditStore {
+	return &auditSQLStore{db: s.db}
+}
+
+func (s *sqlStore) Appointments() ApStore {
+	return &appointmentStore{db: s.db, logger: s.logger}
+}
+
+// WithTx - fn receives the stores bound to a new transaction
+func (s *sqlStore) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return s.db.withTx(ctx, func(c *conn) error {
+		return fn(&sqlStore{db: c, logger: s.logger})
+	})
+}
+
+type dentistSQLStore struct {
+	db     *conn
+	logger *slog.Logger
+}
+
+// GetAll - returns all rows of dentists table
+func (s *dentistSQLStore) GetAll(ctx context.Context) ([]domain.Dentist, error) {
+	rows, err := s.db.QueryContext(ctx, dentistQuery+" WHERE deleted_at IS NULL")
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return scanDentists(rows)
+}
 
-	return &sqlStore{
-		db: database,
+// List - returns a page of dentists
+func (s *dentistSQLStore) List(ctx context.Context, opts ListOptions) (Page[domain.Dentist], error) {
+	list, err := opts.sqlListClauses(dentistColumns, "id", "", "")
+	if err != nil {
+		return Page[domain.Dentist]{}, err
+	}
+
+	var page Page[domain.Dentist]
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM dentists"+list.where, list.args...).Scan(&page.Total); err != nil {
+		return Page[domain.Dentist]{}, err
+	}
+	rows, err := s.db.QueryContext(ctx, dentistQuery+list.where+list.orderBy, list.args...)
+	if err != nil {
+		return Page[domain.Dentist]{}, err
 	}
+	if page.Items, err = scanDentists(rows); err != nil {
+		return Page[domain.Dentist]{}, err
+	}
+	return page, nil
 }
 
-type sqlStore struct {
-	db *sql.DB
+// GetByID - returns a dentist by ID
+func (s *dentistSQLStore) GetByID(ctx context.Context, entityID int) (domain.Dentist, error) {
+	var dentist domain.Dentist
+	err := s.db.QueryRowContext(ctx, dentistQuery+" WHERE id = ? AND deleted_at IS NULL", entityID).Scan(
+		&dentist.Id,
+		&dentist.Surname,
+		&dentist.Name,
+		&dentist.LicenseNumber,
+		&dentist.Version,
+		nullTime{&dentist.DeletedAt},
+		nullTime{&dentist.DeactivatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %d", domain.ErrNotFound, entityID)
+	}
+	return dentist, err
 }
 
-// GetAll 
-func (s *sqlStore) GetAll(tableName string) (interface{}, error) {
-	switch tableName {
-	case AP:
-		return auxGetAllByTable(tableName, s)
-	case DE:
-		return auxGetAllByTable(tableName, s)
-	case PE:
-		return auxGetAllByTable(tableName, s)
-	default:
-		return nil, errors.New("an error occurred while trying to get data from db")
+// Save
+func (s *dentistSQLStore) Save(ctx context.Context, dentist domain.Dentist) (domain.Dentist, error) {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO dentists(surname, name, license_number) VALUES (?,?,?)",
+		dentist.Surname,
+		dentist.Name,
+		dentist.LicenseNumber)
+	if err != nil {
+		return domain.Dentist{}, sqlError(err)
 	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return domain.Dentist{}, err
+	}
+	dentist.Id = int(lastInsertedID)
+	dentist.Version = 1
+	s.logger.DebugContext(ctx, "dentist inserted", "id", dentist.Id, "license_number", dentist.LicenseNumber)
+	return dentist, nil
 }
 
-// GetByID - Return a row from selected table by ID
-func (s *sqlStore) GetByID(id int, tableName string) (interface{}, error) {
-	switch tableName {
-	case AP:
-		return auxGetByIDByTable(tableName, id, s)
-	case DE:
-		return auxGetByIDByTable(tableName, id, s)
-	case PE:
-		return auxGetByIDByTable(tableName, id, s)
-	default:
-		return nil, errors.New("an error occurred while trying to get data from db")
+// Update - updates the dentist at dentist.Version
+func (s *dentistSQLStore) Update(ctx context.Context, entityID int, dentist domain.Dentist) (domain.Dentist, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE dentists SET surname = ?, name = ?, license_number = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL",
+		dentist.Surname,
+		dentist.Name,
+		dentist.LicenseNumber,
+		entityID,
+		dentist.Version)
+	if err != nil {
+		return domain.Dentist{}, sqlError(err)
 	}
+	if err := checkVersion(ctx, s.db, result, "SELECT version FROM dentists WHERE id = ? AND deleted_at IS NULL", entityID); err != nil {
+		return domain.Dentist{}, err
+	}
+	dentist.Id = entityID
+	dentist.Version++
+	return dentist, nil
 }
 
-// Save
-func (s *sqlStore) Save(entity interface{}, tableName string) (interface{}, error) {
-	switch tableName {
-	case AP:
-		return auxSave(tableName, s, entity)
-	case DE:
-		return auxSave(tableName, s, entity)
-	case PE:
-		return auxSave(tableName, s, entity)
-	default:
-		return nil, errors.New("failed to start inserting your data")
-	}
-}
-
-// Update 
-func (s *sqlStore) Update(entityID int, entity interface{}, tableName string) (interface{}, error) {
-	switch tableName {
-	case AP:
-		return auxUpdate(tableName, s, entity, entityID)
-	case DE:
-		return auxUpdate(tableName, s, entity, entityID)
-	case PE:
-		return auxUpdate(tableName, s, entity, entityID)
-	default:
-		return nil, errors.New("failed to update entity data")
-	}
-}
-
-// save
-func (s *sqlStore) Delete(entityID int, tableName string) error {
-	switch tableName {
-	case AP:
-		return auxDelete(tableName, s, entityID)
-	case DE:
-		return auxDelete(tableName, s, entityID)
-	case PE:
-		return auxDelete(tableName, s, entityID)
-	default:
-		return errors.New("failed to delete")
-	}
-}
-
-func auxGetAllByTable(tableName string, s *sqlStore) (interface{}, error) {
-	var entities []struct{}
-
-	switch tableName {
-	case AP:
-		Query := "SELECT a.id, a.description, DATE_FORMAT(a.date_and_time,'%d/%m/%Y %H:%i') date_and_time,a.dentist_license,a.patient_identity,d.id,d.surname,d.name,d.license_number,p.id,p.surname,p.name,p.identity_number,DATE_FORMAT(p.created_at,'%d/%m/%Y %H:%i') created_at FROM appointments a INNER JOIN dentists d on a.dentist_license = d.license_number INNER JOIN patients p on a.patient_identity = p.identity_number ORDER BY a.date_and_time"
-		rows, err := s.db.Query(Query)
-		if err != nil {
-			return entities, err
-		}
+// Delete - a dentist with open appointments can't be deleted, the dentist row is
+// locked so none is booked meanwhile
+func (s *dentistSQLStore) Delete(ctx context.Context, entityID int) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-		var appointment domain.AppointmentDTO
-		var appointments []domain.AppointmentDTO
-
-		for rows.Next() {
-			if err := rows.Scan(
-				&appointment.Id,
-				&appointment.Description,
-				&appointment.DateAndTime,
-				&appointment.DentistLicense,
-				&appointment.PatientIdentity, 
-				&appointment.Dentist.Id,
-				&appointment.Dentist.Surname,
-				&appointment.Dentist.Name,
-				&appointment.Dentist.LicenseNumber,
-				&appointment.Patient.Id,
-				&appointment.Patient.Surname,
-				&appointment.Patient.Name,
-				&appointment.Patient.IdentityNumber,
-				&appointment.Patient.CreatedAt); err != nil {
-				return appointments, err
-			}
-			appointments = append(appointments, appointment)
-		}
-		return appointments, nil
-	case DE:
-		rows, err := s.db.Query("SELECT * FROM dentists")
-		if err != nil {
-			return entities, err
-		}
+	var licenseNumber string
+	err = tx.QueryRowContext(ctx, "SELECT license_number FROM dentists WHERE id = ? AND deleted_at IS NULL FOR UPDATE", entityID).Scan(&licenseNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: dentist %d", domain.ErrNotFound, entityID)
+	}
+	if err != nil {
+		return err
+	}
+	open, err := hasOpenAppointments(ctx, tx, "dentist_license", licenseNumber)
+	if err != nil {
+		return err
+	}
+	if open {
+		return fmt.Errorf("%w: the dentist has open appointments", domain.ErrInUse)
+	}
+	if err := softDelete(ctx, tx, "UPDATE dentists SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL", entityID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-		var dentist domain.Dentist
-		var dentists []domain.Dentist
-
-		for rows.Next() {
-			if err := rows.Scan(
-				&dentist.Id,
-				&dentist.Surname,
-				&dentist.Name,
-				&dentist.LicenseNumber); err != nil {
-				return dentists, err
-			}
-			dentists = append(dentists, dentist)
-		}
-		return dentists, nil
-	case PE:
-		rows, err := s.db.Query("SELECT p.id, p.surname,p.name,p.identity_number, DATE_FORMAT(p.created_at,'%d/%m/%Y %H:%i') FROM patients p")
-		if err != nil {
-			return entities, err
-		}
+// Restore
+func (s *dentistSQLStore) Restore(ctx context.Context, entityID int) (domain.Dentist, error) {
+	if err := restore(ctx, s.db, "UPDATE dentists SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", entityID); err != nil {
+		return domain.Dentist{}, err
+	}
+	return s.GetByID(ctx, entityID)
+}
 
-		var patient domain.Patient
-		var patients []domain.Patient
-		for rows.Next() {
-			if err := rows.Scan(
-				&patient.Id,
-				&patient.Surname,
-				&patient.Name,
-				&patient.IdentityNumber,
-				&patient.CreatedAt); err != nil {
-				return patients, err
-			}
-			patients = append(patients, patient)
-		}
-		return patients, nil
-	default:
-		return nil, errors.New("failed to load data from db at sqlStore.go file")
+// SetDeactivated - the zero time is stored as NULL
+func (s *dentistSQLStore) SetDeactivated(ctx context.Context, entityID int, at time.Time) (domain.Dentist, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE dentists SET deactivated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL",
+		sql.NullTime{Time: at, Valid: !at.IsZero()},
+		entityID)
+	if err != nil {
+		return domain.Dentist{}, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return domain.Dentist{}, err
+	}
+	if count == 0 {
+		return domain.Dentist{}, fmt.Errorf("%w: dentist %d", domain.ErrNotFound, entityID)
 	}
+	return s.GetByID(ctx, entityID)
 }
 
-func auxGetByIDByTable(tableName string, entityID int, s *sqlStore) (interface{}, error) {
-	var entity struct{}
+// Purge - the schedule, time off and users of the dentists are removed along
+func (s *dentistSQLStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	return purge(ctx, s.db, "DELETE FROM dentists WHERE deleted_at < ? AND license_number NOT IN (SELECT dentist_license FROM appointments)", before)
+}
 
-	switch tableName {
-	case AP:
-		query := "SELECT a.id, a.description, DATE_FORMAT(a.date_and_time,'%d/%m/%Y %H:%i') date_and_time,a.dentist_license,a.patient_identity,d.id,d.surname,d.name,d.license_number,p.id,p.surname,p.name,p.identity_number,DATE_FORMAT(p.created_at,'%d/%m/%Y %H:%i') created_at FROM appointments a INNER JOIN dentists d on a.dentist_license = d.license_number INNER JOIN patients p on a.patient_identity = p.identity_number WHERE a.id = ? ORDER BY a.date_and_time"
-		rows, err := s.db.Query(query, entityID)
-		if err != nil {
-			return entity, err
-		}
-		defer rows.Close()
-
-		var appointment domain.AppointmentDTO
-		for rows.Next() {
-			if err := rows.Scan(
-				&appointment.Id,
-				&appointment.Description,
-				&appointment.DateAndTime,
-				&appointment.DentistLicense,
-				&appointment.PatientIdentity,
-				&appointment.Dentist.Id,
-				&appointment.Dentist.Surname,
-				&appointment.Dentist.Name,
-				&appointment.Dentist.LicenseNumber,
-				&appointment.Patient.Id,
-				&appointment.Patient.Surname,
-				&appointment.Patient.Name,
-				&appointment.Patient.IdentityNumber,
-				&appointment.Patient.CreatedAt); err != nil {
-				return appointment, err
-			}
-			return appointment, nil
-		}
-		if rows.Next() {
-			return appointment, nil
-		}
-		return nil, err
-	case DE:
-		rows, err := s.db.Query("SELECT * FROM dentists WHERE id = ?", entityID)
-		if err != nil {
-			return entity, err
-		}
-		defer rows.Close()
-
-		var dentist domain.Dentist
-		for rows.Next() {
-			if err = rows.Scan(
-				&dentist.Id,
-				&dentist.Surname,
-				&dentist.Name,
-				&dentist.LicenseNumber); err != nil {
-				return dentist, err
-			}
-			return dentist, nil
-		}
-		if rows.Next() {
-			return dentist, nil
-		}
-		return nil, err
-	case PE:
-		rows, err := s.db.Query("SELECT p.id, p.surname,p.name,p.identity_number, DATE_FORMAT(p.created_at,'%d/%m/%Y %H:%i') FROM patients p WHERE id = ?", entityID)
-		if err != nil {
-			return entity, err
-		}
-		defer rows.Close()
-
-		var patient domain.Patient
-		for rows.Next() {
-			if err = rows.Scan(
-				&patient.Id,
-				&patient.Surname,
-				&patient.Name,
-				&patient.IdentityNumber,
-				&patient.CreatedAt); err != nil {
-				return nil, err
-			}
-			return patient, nil
-		}
-		if rows.Next() {
-			return patient, nil
-		}
+type patientSQLStore struct {
+	db     *conn
+	logger *slog.Logger
+}
+
+// GetAll - returns all rows of patients table
+func (s *patientSQLStore) GetAll(ctx context.Context) ([]domain.Patient, error) {
+	rows, err := s.db.QueryContext(ctx, patientQuery+" WHERE p.deleted_at IS NULL")
+	if err != nil {
 		return nil, err
-	default:
-		return nil, errors.New("failed to get by id from db")
-	}
-}
-
-func auxSave(tableName string, s *sqlStore, entity interface{}) (interface{}, error) {
-	switch tableName {
-	case AP:
-		log.Println("... inserting data into appointments table.")
-		var appointment domain.Appointment
-		appointment, ok := entity.(domain.Appointment)
-		if ok {
-			apDateAndTimeParsed, err := time.Parse("10/10/2023 20:00", appointment.DateAndTime)
-			if err != nil {
-				log.Println("failed to convert datetimee")
-				return nil, errors.New("failed to convert datetimee")
-			}
-			//
-			log.Println(apDateAndTimeParsed.String())
-			result, err := s.db.Exec("INSERT INTO appointments(DESCRIPTION, DATE_AND_TIME, dentist_license, patient_identity) VALUES(?,?,?,?)",
-				appointment.Description,
-				apDateAndTimeParsed,
-				appointment.DentistLicense,
-				appointment.PatientIdentity) 
-			if err != nil {
-				fmt.Println("inserting data failed :", err.Error())
-				return nil, err
-			}
-			lastInsertedID, err := result.LastInsertId()
-			if err != nil {
-				fmt.Println("error trying to get id inserted:", err.Error())
-				return nil, err
-			}
-			appointment.Id = int(lastInsertedID)
-			log.Println("... INSERT operation was successfully")
-			return s.GetByID(appointment.Id, AP)
-		}
-	case DE:
-		var dentist domain.Dentist
-		dentist, ok := entity.(domain.Dentist)
-		if ok {
-			result, err := s.db.Exec("INSERT INTO dentists(surname, name, license_number) VALUES (?,?,?)",
-				dentist.Surname,
-				dentist.Name,
-				dentist.LicenseNumber)
-			if err != nil {
-				fmt.Println("inserting data failed :", err.Error())
-				return nil, err
-			}
-			lastInsertedID, err := result.LastInsertId()
-			if err != nil {
-				fmt.Println("error trying to get id inserted:", err.Error())
-				return nil, err
-			}
-			dentist.Id = int(lastInsertedID)
-			fmt.Println("dentist inserted at db:", dentist)
-			return dentist, nil
-		}
-	case PE:
-		var patient domain.Patient
-		patient, ok := entity.(domain.Patient)
-		if ok {
-			patCreatedAtParsed, err := time.Parse("10/10/2023 20:00", patient.CreatedAt)
-			if err != nil {
-				return nil, errors.New("failed to convert patient created_at field")
-			}
-			result, err := s.db.Exec("INSERT INTO patients(surname, name, identity_number, created_at) VALUES (?,?,?,?)",
-				patient.Surname,
-				patient.Name,
-				patient.IdentityNumber,
-				patCreatedAtParsed)
-			if err != nil {
-				fmt.Println("inserting data failed :", err.Error())
-				return nil, err
-			}
-			lastInsertedID, err := result.LastInsertId()
-			if err != nil {
-				fmt.Println("error trying to get id inserted:", err.Error())
-				return nil, err
-			}
-			patient.Id = int(lastInsertedID)
-			return patient, nil
-		}
-	default:
-		return nil, errors.New("failed to insert data at database")
-	}
-	return nil, errors.New("failed to insert data at database")
-}
-
-func auxUpdate(tableName string, s *sqlStore, entity interface{}, entityId int) (interface{}, error) {
-	switch tableName {
-	case AP:
-		var appointment domain.Appointment
-		appointment, ok := entity.(domain.Appointment)
-		if ok {
-			apDateAndTimeParsed, err := time.Parse("10/10/2023 20:00", appointment.DateAndTime)
-			if err != nil {
-				log.Println(err.Error(), "\nDate parsed: ", apDateAndTimeParsed)
-				return nil, errors.New("failed to convert datetime")
-			}
-			_, err = s.db.Exec("UPDATE appointments SET description = ?, date_and_time = ?, dentist_license = ?, patient_identity = ? WHERE id = ?",
-				appointment.Description,
-				apDateAndTimeParsed,
-				appointment.DentistLicense,
-				appointment.PatientIdentity,
-				entityId)
-			if err != nil {
-				return nil, err
-			}
-			return s.GetByID(entityId, AP)
-		}
-	case DE:
-		var dentist domain.Dentist
-		dentist, ok := entity.(domain.Dentist)
-		if ok {
-			_, err := s.db.Exec("UPDATE dentists SET surname = ?, name = ?, license_number = ? WHERE id = ?",
-				dentist.Surname,
-				dentist.Name,
-				dentist.LicenseNumber,
-				entityId)
-			if err != nil {
-				return nil, err
-			}
-			return dentist, nil
-		}
-	case PE:
-		var patient domain.Patient
-		patient, ok := entity.(domain.Patient)
-		if ok {
-			paCreatedAtParsed, err := time.Parse("10/10/2023 20:00", patient.CreatedAt)
-			if err != nil {
-				return nil, errors.New("failed to convert patient created_at field: " + patient.CreatedAt)
-			}
-
-			_, err = s.db.Exec("UPDATE patients SET surname = ?, name = ?, identity_number = ?, created_at = ? WHERE id = ?",
-				patient.Surname,
-				patient.Name,
-				patient.IdentityNumber,
-				paCreatedAtParsed,
-				entityId)
-			if err != nil {
-				return nil, err
-			}
-			return patient, nil
-		}
-	default:
-		return nil, errors.New("failed to update data into database")
 	}
-	return nil, errors.New("failed to update data into database")
+	return scanPatients(rows)
 }
 
-func auxDelete(tableName string, s *sqlStore, entityID int) error {
-	switch tableName {
-	case AP:
-		result, err := s.db.Exec("DELETE FROM appointments WHERE id =?", entityID)
-		if err != nil {
-			return err
-		}
-		count, err := result.RowsAffected()
-		if count == 0 {
-			return errors.New("entity not found at database")
-		}
-		return nil
-	case DE:
-		result, err := s.db.Exec("DELETE FROM dentists WHERE id =?", entityID)
-		if err != nil {
-			return err
-		}
-		count, err := result.RowsAffected()
-		if count == 0 {
-			return errors.New("entity not found at database")
-		}
-		return nil
-	case PE:
-		result, err := s.db.Exec("DELETE FROM patients WHERE id =?", entityID)
-		if err != nil {
-			return err
+// List - returns a page of patients
+func (s *patientSQLStore) List(ctx context.Context, opts ListOptions) (Page[domain.Patient], error) {
+	list, err := opts.sqlListClauses(patientColumns, "id", "p.", "")
+	if err != nil {
+		return Page[domain.Patient]{}, err
+	}
+
+	var page Page[domain.Patient]
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM patients p"+list.where, list.args...).Scan(&page.Total); err != nil {
+		return Page[domain.Patient]{}, err
+	}
+	rows, err := s.db.QueryContext(ctx, patientQuery+list.where+list.orderBy, list.args...)
+	if err != nil {
+		return Page[domain.Patient]{}, err
+	}
+	if page.Items, err = scanPatients(rows); err != nil {
+		return Page[domain.Patient]{}, err
+	}
+	return page, nil
+}
+
+// GetByID - returns a patient by ID
+func (s *patientSQLStore) GetByID(ctx context.Context, entityID int) (domain.Patient, error) {
+	var patient domain.Patient
+	err := s.db.QueryRowContext(ctx, patientQuery+" WHERE id = ? AND deleted_at IS NULL", entityID).Scan(
+		&patient.Id,
+		&patient.Surname,
+		&patient.Name,
+		&patient.IdentityNumber,
+		&patient.CreatedAt,
+		&patient.Version,
+		nullTime{&patient.DeletedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Patient{}, fmt.Errorf("%w: patient %d", domain.ErrNotFound, entityID)
+	}
+	return patient, err
+}
+
+// Save
+func (s *patientSQLStore) Save(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO patients(surname, name, identity_number, created_at) VALUES (?,?,?,?)",
+		patient.Surname,
+		patient.Name,
+		patient.IdentityNumber,
+		patient.CreatedAt)
+	if err != nil {
+		return domain.Patient{}, sqlError(err)
+	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return domain.Patient{}, err
+	}
+	patient.Id = int(lastInsertedID)
+	patient.Version = 1
+	s.logger.DebugContext(ctx, "patient inserted", "id", patient.Id)
+	return patient, nil
+}
+
+// Update - updates the patient at patient.Version
+func (s *patientSQLStore) Update(ctx context.Context, entityID int, patient domain.Patient) (domain.Patient, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE patients SET surname = ?, name = ?, identity_number = ?, created_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL",
+		patient.Surname,
+		patient.Name,
+		patient.IdentityNumber,
+		patient.CreatedAt,
+		entityID,
+		patient.Version)
+	if err != nil {
+		return domain.Patient{}, sqlError(err)
+	}
+	if err := checkVersion(ctx, s.db, result, "SELECT version FROM patients WHERE id = ? AND deleted_at IS NULL", entityID); err != nil {
+		return domain.Patient{}, err
+	}
+	patient.Id = entityID
+	patient.Version++
+	return patient, nil
+}
+
+// Delete - a patient with open appointments can't be deleted, the patient row is
+// locked so none is booked meanwhile
+func (s *patientSQLStore) Delete(ctx context.Context, entityID int) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var identityNumber string
+	err = tx.QueryRowContext(ctx, "SELECT identity_number FROM patients WHERE id = ? AND deleted_at IS NULL FOR UPDATE", entityID).Scan(&identityNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: patient %d", domain.ErrNotFound, entityID)
+	}
+	if err != nil {
+		return err
+	}
+	open, err := hasOpenAppointments(ctx, tx, "patient_identity", identityNumber)
+	if err != nil {
+		return err
+	}
+	if open {
+		return fmt.Errorf("%w: the patient has open appointments", domain.ErrInUse)
+	}
+	if err := softDelete(ctx, tx, "UPDATE patients SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL", entityID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Restore
+func (s *patientSQLStore) Restore(ctx context.Context, entityID int) (domain.Patient, error) {
+	if err := restore(ctx, s.db, "UPDATE patients SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", entityID); err != nil {
+		return domain.Patient{}, err
+	}
+	return s.GetByID(ctx, entityID)
+}
+
+// Purge - the users of the patients are removed along
+func (s *patientSQLStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	return purge(ctx, s.db, "DELETE FROM patients WHERE deleted_at < ? AND identity_number NOT IN (SELECT patient_identity FROM appointments)", before)
+}
+
+// scanDentists - reads and closes rows selected from dentists table
+func scanDentists(rows *sql.Rows) ([]domain.Dentist, error) {
+	defer rows.Close()
+
+	var dentist domain.Dentist
+	dentists := []domain.Dentist{}
+	for rows.Next() {
+		if err := rows.Scan(
+			&dentist.Id,
+			&dentist.Surname,
+			&dentist.Name,
+			&dentist.LicenseNumber,
+			&dentist.Version,
+			nullTime{&dentist.DeletedAt},
+			nullTime{&dentist.DeactivatedAt}); err != nil {
+			return dentists, err
 		}
-		count, err := result.RowsAffected()
-		if count == 0 {
-			return errors.New("entity not found at database")
+		dentists = append(dentists, dentist)
+	}
+	return dentists, rows.Err()
+}
+
+// scanPatients - reads and closes rows selected with patientQuery
+func scanPatients(rows *sql.Rows) ([]domain.Patient, error) {
+	defer rows.Close()
+
+	var patient domain.Patient
+	patients := []domain.Patient{}
+	for rows.Next() {
+		if err := rows.Scan(
+			&patient.Id,
+			&patient.Surname,
+			&patient.Name,
+			&patient.IdentityNumber,
+			&patient.CreatedAt,
+			&patient.Version,
+			nullTime{&patient.DeletedAt}); err != nil {
+			return patients, err
 		}
+		patients = append(patients, patient)
+	}
+	return patients, rows.Err()
+}
+
+// softDelete - runs query, which sets the deleted_at of the row entityID to the
+// current time
+func softDelete(ctx context.Context, db querier, query string, entityID int) error {
+	result, err := db.ExecContext(ctx, query, time.Now(), entityID)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: id %d", domain.ErrNotFound, entityID)
+	}
+	return nil
+}
+
+// restore - runs query, which clears the deleted_at of the row entityID, a row
+// which isn't deleted isn't found
+func restore(ctx context.Context, db querier, query string, entityID int) error {
+	result, err := db.ExecContext(ctx, query, entityID)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: no deleted row with id %d", domain.ErrNotFound, entityID)
+	}
+	return nil
+}
+
+// purge - runs query, which removes the rows deleted before before
+func purge(ctx context.Context, db querier, query string, before time.Time) (int, error) {
+	result, err := db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, sqlError(err)
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// hasOpenAppointments - tells if an appointment which isn't deleted nor at a final
+// status has value at column
+func hasOpenAppointments(ctx context.Context, db querier, column, value string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM appointments WHERE "+column+" = ? AND deleted_at IS NULL AND status NOT IN (?,?,?)",
+		value,
+		domain.StatusCompleted,
+		domain.StatusCancelled,
+		domain.StatusNoShow).Scan(&count)
+	return count > 0, err
+}
+
+// nullTime - scans a nullable datetime, NULL being the zero time
+type nullTime struct {
+	t *time.Time
+}
+
+func (n nullTime) Scan(value interface{}) error {
+	var scanned sql.NullTime
+	if err := scanned.Scan(value); err != nil {
+		return err
+	}
+	*n.t = scanned.Time
+	return nil
+}
+
+// checkVersion - an update naming the version it's based on changes no row when the
+// version isn't the current one or the entity doesn't exist, versionQuery tells them
+// apart
+func checkVersion(ctx context.Context, db querier, result sql.Result, versionQuery string, entityID int) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
 		return nil
-	default:
-		return errors.New("failed to delete row")
 	}
+	var version int
+	err = db.QueryRowContext(ctx, versionQuery, entityID).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: id %d", domain.ErrNotFound, entityID)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: id %d is at version %d", domain.ErrVersionMismatch, entityID, version)
 }
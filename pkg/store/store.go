@@ -1,9 +1,82 @@
 package store
 
+import (
+	"context"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// Repository - typed CRUD operations over the rows of a single table. Deleted rows
+// are kept until they are purged, the reads leave them out.
+type Repository[T any] interface {
+	GetAll(ctx context.Context) ([]T, error)
+	List(ctx context.Context, opts ListOptions) (Page[T], error)
+	GetByID(ctx context.Context, entityID int) (T, error)
+	Save(ctx context.Context, entity T) (T, error)
+	Update(ctx context.Context, entityID int, entity T) (T, error)
+	// Delete - sets the deleted_at of the row
+	Delete(ctx context.Context, entityID int) error
+	// Restore - clears the deleted_at of a deleted row
+	Restore(ctx context.Context, entityID int) (T, error)
+	// Purge - removes the rows deleted before before and returns how many, the ones
+	// still referenced by appointments are kept
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+// Store - gives access to the dentists and patients tables
 type Store interface {
-	GetAll(tableName string) (interface{}, error)
-	GetByID(entityID int, tableName string) (interface{}, error)
-	Save(entity interface{}, tableName string) (interface{}, error)
-	Update(entityID int, entity interface{}, tableName string) (interface{}, error)
-	Delete(entityID int, tableName string) error
+	Transactor
+	Dentists() DentistStore
+	Patients() Repository[domain.Patient]
+	Schedules() ScheduleStore
+	CalendarTokens() CalendarTokenStore
+	Users() UserStore
+	Audit() AuditStore
+}
+
+// Database - the pool of connections the SQL stores share, a *sql.DB
+type Database interface {
+	// PingContext - checks the database is reachable
+	PingContext(ctx context.Context) error
+	// Close - closes the connections, the stores can't be used afterwards
+	Close() error
+}
+
+// DentistStore - the dentists table
+type DentistStore interface {
+	Repository[domain.Dentist]
+	// SetDeactivated - sets when the dentist stopped taking new bookings, the zero
+	// time reactivates it
+	SetDeactivated(ctx context.Context, entityID int, at time.Time) (domain.Dentist, error)
+}
+
+// ScheduleStore - the weekly templates and time off of the dentists
+type ScheduleStore interface {
+	GetSchedule(ctx context.Context, dentistID int) (domain.Schedule, error)
+	SaveSchedule(ctx context.Context, schedule domain.Schedule) (domain.Schedule, error)
+	GetAllTimeOff(ctx context.Context, dentistID int) ([]domain.TimeOff, error)
+	SaveTimeOff(ctx context.Context, timeOff domain.TimeOff) (domain.TimeOff, error)
+	DeleteTimeOff(ctx context.Context, dentistID, timeOffID int) error
+}
+
+// CalendarTokenStore - the secret tokens protecting the calendar feeds, one per owner
+type CalendarTokenStore interface {
+	GetCalendarToken(ctx context.Context, owner domain.CalendarOwner, ownerID int) (string, error)
+	// SaveCalendarToken - sets the token of the owner, replacing the previous one
+	SaveCalendarToken(ctx context.Context, owner domain.CalendarOwner, ownerID int, token string) error
+}
+
+// UserStore - the accounts of the API, emails are unique
+type UserStore interface {
+	GetUserByID(ctx context.Context, userID int) (domain.User, error)
+	GetUserByEmail(ctx context.Context, email string) (domain.User, error)
+	SaveUser(ctx context.Context, user domain.User) (domain.User, error)
+}
+
+// AuditStore - the audit log, its entries are never changed nor removed
+type AuditStore interface {
+	SaveAuditEntry(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error)
+	// ListAuditEntries - returns a page of entries, by default the newest first
+	ListAuditEntries(ctx context.Context, opts ListOptions) (Page[domain.AuditEntry], error)
 }
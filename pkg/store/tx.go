@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/metrics"
+)
+
+// Tx - the stores of a unit of work, their reads and writes happen in its transaction
+type Tx interface {
+	Dentists() DentistStore
+	Patients() Repository[domain.Patient]
+	Schedules() ScheduleStore
+	Appointments() ApStore
+	Audit() AuditStore
+}
+
+// Transactor - runs units of work over several stores
+type Transactor interface {
+	// WithTx - runs fn in a transaction which is committed when fn returns nil and
+	// rolled back otherwise
+	WithTx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// querier - the queries shared by the database, its transactions and conn
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn - the database of the SQL stores, or the transaction of the unit of work
+// they are bound to. The duration of every query is recorded in metrics.
+type conn struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	metrics *metrics.Metrics
+}
+
+func (c *conn) querier() querier {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.db
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery(c.metrics, query, time.Now())
+	return c.querier().ExecContext(ctx, query, args...)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery(c.metrics, query, time.Now())
+	return c.querier().QueryContext(ctx, query, args...)
+}
+
+func (c *conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery(c.metrics, query, time.Now())
+	return c.querier().QueryRowContext(ctx, query, args...)
+}
+
+// withTx - runs fn with a conn bound to a new transaction, a conn already bound
+// runs fn in its own
+func (c *conn) withTx(ctx context.Context, fn func(c *conn) error) error {
+	if c.tx != nil {
+		return fn(c)
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&conn{db: c.db, tx: tx, metrics: c.metrics}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// begin - starts the transaction of a store method. When the conn is bound to a
+// unit of work it's a savepoint of its transaction, so a failed method only undoes
+// its own statements and WithTx decides about the whole.
+func (c *conn) begin(ctx context.Context) (*txScope, error) {
+	if c.tx == nil {
+		tx, err := c.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &txScope{Tx: tx, metrics: c.metrics}, nil
+	}
+	if _, err := c.tx.ExecContext(ctx, "SAVEPOINT store_method"); err != nil {
+		return nil, err
+	}
+	return &txScope{Tx: c.tx, metrics: c.metrics, ctx: ctx, savepoint: true}, nil
+}
+
+// txScope - a transaction begun by begin, Rollback after Commit does nothing
+type txScope struct {
+	*sql.Tx
+	metrics   *metrics.Metrics
+	ctx       context.Context
+	savepoint bool
+	done      bool
+}
+
+func (t *txScope) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery(t.metrics, query, time.Now())
+	return t.Tx.ExecContext(ctx, query, args...)
+}
+
+func (t *txScope) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery(t.metrics, query, time.Now())
+	return t.Tx.QueryContext(ctx, query, args...)
+}
+
+func (t *txScope) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery(t.metrics, query, time.Now())
+	return t.Tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t *txScope) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	if t.done {
+		return nil
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, "RELEASE SAVEPOINT store_method")
+	return err
+}
+
+func (t *txScope) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return nil
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT store_method")
+	return err
+}
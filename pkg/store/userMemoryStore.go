@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+type userMemoryStore struct {
+	*memoryStore
+}
+
+func (m *userMemoryStore) GetUserByID(ctx context.Context, userID int) (domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return domain.User{}, fmt.Errorf("%w: user %d", domain.ErrNotFound, userID)
+	}
+	return user, nil
+}
+
+func (m *userMemoryStore) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, id := range sortedIDs(m.users) {
+		// the column collation of MySQL compares emails ignoring case
+		if strings.EqualFold(m.users[id].Email, email) {
+			return m.users[id], nil
+		}
+	}
+	return domain.User{}, fmt.Errorf("%w: user %s", domain.ErrNotFound, email)
+}
+
+// SaveUser - checks the unique email and the references as the constraints would do
+func (m *userMemoryStore) SaveUser(ctx context.Context, user domain.User) (domain.User, error) {
+	m.lock()
+	defer m.unlock()
+
+	for _, other := range m.users {
+		if strings.EqualFold(other.Email, user.Email) {
+			return domain.User{}, domain.ErrDuplicateEmail
+		}
+	}
+	if _, ok := m.dentists[user.DentistId]; user.DentistId != 0 && !ok {
+		return domain.User{}, domain.NewValidationError("dentist_id", "exists", "doesn't reference a dentist")
+	}
+	if _, ok := m.patients[user.PatientId]; user.PatientId != 0 && !ok {
+		return domain.User{}, domain.NewValidationError("patient_id", "exists", "doesn't reference a patient")
+	}
+	user.Id = m.nextID("users")
+	m.users[user.Id] = user
+	return user, nil
+}
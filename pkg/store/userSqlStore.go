@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+const userQuery = "SELECT id, email, password_hash, role, COALESCE(dentist_id,0), COALESCE(patient_id,0) FROM users"
+
+type userSQLStore struct {
+	db *conn
+}
+
+func (s *userSQLStore) GetUserByID(ctx context.Context, userID int) (domain.User, error) {
+	return s.getUser(ctx, userQuery+" WHERE id = ?", userID)
+}
+
+func (s *userSQLStore) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	return s.getUser(ctx, userQuery+" WHERE email = ?", email)
+}
+
+// SaveUser - a dentist_id or patient_id of 0 is stored as NULL
+func (s *userSQLStore) SaveUser(ctx context.Context, user domain.User) (domain.User, error) {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO users(email, password_hash, role, dentist_id, patient_id, created_at) VALUES (?,?,?,?,?,?)",
+		user.Email,
+		user.PasswordHash,
+		user.Role,
+		nullableID(user.DentistId),
+		nullableID(user.PatientId),
+		time.Now())
+	if err != nil {
+		return domain.User{}, sqlError(err)
+	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.Id = int(lastInsertedID)
+	return user, nil
+}
+
+func (s *userSQLStore) getUser(ctx context.Context, query string, args ...interface{}) (domain.User, error) {
+	var user domain.User
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&user.Id,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Role,
+		&user.DentistId,
+		&user.PatientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.User{}, fmt.Errorf("%w: user", domain.ErrNotFound)
+	}
+	return user, err
+}
+
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
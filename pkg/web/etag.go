@@ -0,0 +1,38 @@
+package web
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// SetETag - sends the version of the entity answered as its entity tag
+func SetETag(ctx *gin.Context, version int) {
+	ctx.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// IfMatch - the version named by the If-Match header of an update, zero for * which
+// matches any version. A missing header is domain.ErrVersionRequired and a weak or
+// unknown tag never matches, it's domain.ErrVersionMismatch.
+func IfMatch(ctx *gin.Context) (int, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		return 0, domain.ErrVersionRequired
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	// only the first tag of a list is compared, a client sends the one it read
+	tag := strings.TrimSpace(strings.SplitN(header, ",", 2)[0])
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, domain.ErrVersionMismatch
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, domain.ErrVersionMismatch
+	}
+	return version, nil
+}
@@ -0,0 +1,27 @@
+package web
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loggerKey - the key of the logger of the request in the gin context
+const loggerKey = "logger"
+
+// SetLogger - stores the logger of the request, called by the request logging
+// middleware
+func SetLogger(ctx *gin.Context, logger *slog.Logger) {
+	ctx.Set(loggerKey, logger)
+}
+
+// Logger - returns the logger of the request, the default one when none was set.
+// Records logged with the context of the request hold its id.
+func Logger(ctx *gin.Context) *slog.Logger {
+	if value, ok := ctx.Get(loggerKey); ok {
+		if logger, ok := value.(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
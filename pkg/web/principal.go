@@ -0,0 +1,27 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+)
+
+// principalKey - the key of the authenticated principal in the gin context
+const principalKey = "principal"
+
+// SetPrincipal - stores the authenticated user of the request, in its context too,
+// called by the authentication middleware
+func SetPrincipal(ctx *gin.Context, p domain.Principal) {
+	ctx.Set(principalKey, p)
+	ctx.Request = ctx.Request.WithContext(domain.ContextWithPrincipal(ctx.Request.Context(), p))
+}
+
+// GetPrincipal - returns the authenticated user of the request, false on routes
+// which aren't authenticated
+func GetPrincipal(ctx *gin.Context) (domain.Principal, bool) {
+	value, ok := ctx.Get(principalKey)
+	if !ok {
+		return domain.Principal{}, false
+	}
+	p, ok := value.(domain.Principal)
+	return p, ok
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/logging"
+)
+
+// problemContentType - the media type of problem details, RFC 7807 section 3
+const problemContentType = "application/problem+json"
+
+// Stable codes of the problems, clients should rely on them instead of the detail
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeInternal         = "internal_error"
+	CodeTimeout          = "timeout"
+)
+
+// statusClientClosedRequest - logged when the client went away before the answer,
+// no answer is read anyway
+const statusClientClosedRequest = 499
+
+// problem - an RFC 7807 problem details document, code and violations are extension
+// members. The type is about:blank so the title is the one of the status.
+type problem struct {
+	Type       string             `json:"type"`
+	Title      string             `json:"title"`
+	Status     int                `json:"status"`
+	Detail     string             `json:"detail,omitempty"`
+	Instance   string             `json:"instance,omitempty"`
+	Code       string             `json:"code"`
+	Violations []domain.Violation `json:"violations,omitempty"`
+	// RequestId - the X-Request-ID of the request, its log lines hold it
+	RequestId string `json:"request_id,omitempty"`
+	// CorrelationId - identifies the logged cause of an unexpected error, the request
+	// id when there's one
+	CorrelationId string `json:"correlation_id,omitempty"`
+}
+
+func init() {
+	// violations name the fields as they are sent, by their json name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// ProblemResponse - writes a problem document with code and violations
+func ProblemResponse(ctx *gin.Context, statusCode int, code, detail string, violations []domain.Violation) {
+	ctx.Render(statusCode, problemRender{problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(statusCode),
+		Status:     statusCode,
+		Detail:     detail,
+		Instance:   ctx.Request.URL.Path,
+		Code:       code,
+		Violations: violations,
+		RequestId:  logging.RequestID(ctx.Request.Context()),
+	}})
+}
+
+// BadResponse - writes a problem with the default code of the status
+func BadResponse(ctx *gin.Context, statusCode int, message string) {
+	ProblemResponse(ctx, statusCode, statusCode2Code(statusCode), message, nil)
+}
+
+// ErrorResponse - writes err as a problem, the status and code come from the type of
+// the domain error, a missing version being 428 and a stale one 412. A request past
+// its deadline is answered as 503 and one whose client went away isn't answered. Any
+// other error is unexpected, it's logged and answered as 500 without its detail, the
+// correlation id of the answer is found in the log.
+func ErrorResponse(ctx *gin.Context, err error) {
+	var validation *domain.ValidationError
+	var unauthorized *domain.UnauthorizedError
+	var notFound *domain.NotFoundError
+	var conflict *domain.ConflictError
+	var precondition *domain.PreconditionError
+	switch {
+	case errors.As(err, &validation):
+		ProblemResponse(ctx, http.StatusBadRequest, CodeValidationFailed, err.Error(), validation.Violations)
+	case errors.Is(err, domain.ErrInvalidDate):
+		ProblemResponse(ctx, http.StatusBadRequest, CodeValidationFailed, err.Error(), nil)
+	case errors.As(err, &unauthorized):
+		ProblemResponse(ctx, http.StatusUnauthorized, unauthorized.Code, err.Error(), nil)
+	case errors.As(err, &notFound):
+		ProblemResponse(ctx, http.StatusNotFound, notFound.Code, err.Error(), nil)
+	case errors.As(err, &conflict):
+		ProblemResponse(ctx, http.StatusConflict, conflict.Code, err.Error(), nil)
+	case errors.As(err, &precondition):
+		statusCode := http.StatusPreconditionFailed
+		if errors.Is(err, domain.ErrVersionRequired) {
+			statusCode = http.StatusPreconditionRequired
+		}
+		ProblemResponse(ctx, statusCode, precondition.Code, err.Error(), nil)
+	case errors.Is(err, context.DeadlineExceeded):
+		Logger(ctx).WarnContext(ctx.Request.Context(), "request timed out", "method", ctx.Request.Method, "path", ctx.Request.URL.Path, "error", err)
+		ProblemResponse(ctx, http.StatusServiceUnavailable, CodeTimeout, "the request took longer than allowed, please try again later", nil)
+	case errors.Is(err, context.Canceled):
+		Logger(ctx).InfoContext(ctx.Request.Context(), "the client closed the request", "method", ctx.Request.Method, "path", ctx.Request.URL.Path)
+		ctx.AbortWithStatus(statusClientClosedRequest)
+	default:
+		id := logging.RequestID(ctx.Request.Context())
+		if id == "" {
+			id = logging.NewRequestID()
+		}
+		Logger(ctx).ErrorContext(ctx.Request.Context(), "unexpected error", "method", ctx.Request.Method, "path", ctx.Request.URL.Path, "correlation_id", id, "error", err)
+		ctx.Render(http.StatusInternalServerError, problemRender{problem{
+			Type:          "about:blank",
+			Title:         http.StatusText(http.StatusInternalServerError),
+			Status:        http.StatusInternalServerError,
+			Detail:        "an unexpected error happened, please try again later",
+			Instance:      ctx.Request.URL.Path,
+			Code:          CodeInternal,
+			RequestId:     logging.RequestID(ctx.Request.Context()),
+			CorrelationId: id,
+		}})
+	}
+}
+
+// BindingResponse - writes the error of binding a request body, a violation is
+// reported for every field failing its binding rules or holding a wrong type. The
+// validation errors of the domain decoders, e.g. an invalid date, are written as such.
+func BindingResponse(ctx *gin.Context, message string, err error) {
+	var fieldErrors validator.ValidationErrors
+	var validation *domain.ValidationError
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &fieldErrors):
+		violations := make([]domain.Violation, 0, len(fieldErrors))
+		for _, fe := range fieldErrors {
+			violations = append(violations, domain.Violation{
+				Field:   fieldPath(fe.Namespace()),
+				Rule:    fe.Tag(),
+				Message: ruleMessage(fe),
+			})
+		}
+		ProblemResponse(ctx, http.StatusBadRequest, CodeValidationFailed, message, violations)
+	case errors.As(err, &typeError):
+		ProblemResponse(ctx, http.StatusBadRequest, CodeValidationFailed, message, []domain.Violation{{
+			Field:   typeError.Field,
+			Rule:    "type",
+			Message: "must be a " + typeError.Type.String(),
+		}})
+	case errors.As(err, &syntaxError) || errors.Is(err, io.ErrUnexpectedEOF):
+		BadResponse(ctx, http.StatusBadRequest, message+": malformed JSON body")
+	case errors.Is(err, io.EOF):
+		BadResponse(ctx, http.StatusBadRequest, message+": the body is empty")
+	case errors.As(err, &validation):
+		ErrorResponse(ctx, err)
+	default:
+		BadResponse(ctx, http.StatusBadRequest, message+": "+err.Error())
+	}
+}
+
+// problemRender - renders a problem as JSON keeping the problem media type, which
+// gin's JSON render would replace
+type problemRender struct {
+	p problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.p)
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", problemContentType)
+}
+
+func statusCode2Code(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(statusCode)), " ", "_")
+}
+
+// fieldPath - the dotted json path of a field, the namespace also holds the names
+// of the structs and of the embedded ones, which start in upper case
+func fieldPath(namespace string) string {
+	var path []string
+	for _, name := range strings.Split(namespace, ".") {
+		if name != "" && !unicode.IsUpper(rune(name[0])) {
+			path = append(path, name)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	}
+	return fmt.Sprintf("doesn't satisfy the %s rule", fe.Tag())
+}
+
+// jsonFieldName - the name of a field in the JSON body, empty for embedded structs
+// and for fields which aren't serialized
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauriciogregory/esp_backIII_go/internal/domain"
+	"github.com/mauriciogregory/esp_backIII_go/pkg/logging"
+)
+
+// answerError - the answer of ErrorResponse to a request with the id abc
+func answerError(t *testing.T, err error) (*httptest.ResponseRecorder, problem) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	req := httptest.NewRequest(http.MethodGet, "/appointments/1", nil)
+	ctx.Request = req.WithContext(logging.WithRequestID(req.Context(), "abc"))
+	SetLogger(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ErrorResponse(ctx, err)
+
+	var p problem
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w, p
+}
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"validation", domain.NewValidationError("reason", "required", "is required"), http.StatusBadRequest, CodeValidationFailed},
+		{"invalid date", domain.InvalidDate("date_and_time", domain.DateTimeFormats), http.StatusBadRequest, CodeValidationFailed},
+		{"invalid token", domain.ErrInvalidToken, http.StatusUnauthorized, "invalid_token"},
+		{"not found, wrapped", fmt.Errorf("%w: appointment 1", domain.ErrNotFound), http.StatusNotFound, "not_found"},
+		{"slot unavailable, wrapped", fmt.Errorf("%w: during a break", domain.ErrSlotUnavailable), http.StatusConflict, "slot_unavailable"},
+		{"duplicate", domain.ErrDuplicateLicense, http.StatusConflict, "duplicate_license"},
+		{"dentist inactive", domain.ErrDentistInactive, http.StatusConflict, "dentist_inactive"},
+		{"stale version", fmt.Errorf("%w: appointment 1 is at version 2", domain.ErrVersionMismatch), http.StatusPreconditionFailed, "precondition_failed"},
+		{"missing version", domain.ErrVersionRequired, http.StatusPreconditionRequired, "precondition_required"},
+		{"deadline", fmt.Errorf("querying: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, CodeTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, p := answerError(t, tt.err)
+			if w.Code != tt.status || p.Status != tt.status {
+				t.Errorf("status %d, document status %d, want %d", w.Code, p.Status, tt.status)
+			}
+			if p.Code != tt.code {
+				t.Errorf("code %s, want %s", p.Code, tt.code)
+			}
+			if got := w.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("content type %s", got)
+			}
+			if p.Type != "about:blank" || p.Title != http.StatusText(tt.status) || p.Instance != "/appointments/1" || p.RequestId != "abc" {
+				t.Errorf("unexpected document %+v", p)
+			}
+		})
+	}
+}
+
+func TestErrorResponseViolations(t *testing.T) {
+	invalid := &domain.ValidationError{}
+	invalid.Add("procedure_type", "oneof", "unknown procedure type: massage")
+	invalid.Add("duration_minutes", "range", "the duration must be between 1 and 480 minutes")
+
+	_, p := answerError(t, invalid)
+	if len(p.Violations) != 2 || p.Violations[0].Field != "procedure_type" || p.Violations[1].Rule != "range" {
+		t.Errorf("violations %+v", p.Violations)
+	}
+}
+
+// TestErrorResponseUnexpected - the detail isn't answered, the correlation id
+// finds it in the log
+func TestErrorResponseUnexpected(t *testing.T) {
+	w, p := answerError(t, errors.New("dial tcp 10.0.0.1:3306: connection refused"))
+	if w.Code != http.StatusInternalServerError || p.Code != CodeInternal {
+		t.Errorf("status %d and code %s, want 500 and %s", w.Code, p.Code, CodeInternal)
+	}
+	if p.Detail != "an unexpected error happened, please try again later" {
+		t.Errorf("the detail of the cause was answered: %s", p.Detail)
+	}
+	if p.CorrelationId != "abc" {
+		t.Errorf("correlation id %q, want the request id", p.CorrelationId)
+	}
+}
+
+func TestErrorResponseCanceled(t *testing.T) {
+	w, _ := answerError(t, context.Canceled)
+	if w.Code != statusClientClosedRequest || w.Body.Len() != 0 {
+		t.Errorf("status %d with body %q, want %d without body", w.Code, w.Body, statusClientClosedRequest)
+	}
+}
@@ -1,33 +1,38 @@
 package web
 
 import (
-	"time"
+	"net/url"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-type errorResponse struct {
+type messageResponse struct {
 	StatusCode int    `json:"status_code"`
 	Status     string `json:"status"`
 	Message    string `json:"message"`
-	TimeStamp  string `json:"time_stamp"`
 }
 
 type response struct {
-	Data interface{} `json:"data"`
+	Data  interface{} `json:"data"`
+	Meta  *pageMeta   `json:"meta,omitempty"`
+	Links *pageLinks  `json:"links,omitempty"`
 }
 
-func BadResponse(ctx *gin.Context, statusCode int, status, message string) {
-	ctx.JSON(statusCode, errorResponse{
-		StatusCode: statusCode,
-		Status:     status,
-		Message:    message,
-		TimeStamp:  time.Now().String(),
-	})
+type pageMeta struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type pageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
 }
 
 func DeleteResponse(ctx *gin.Context, statusCode int, message string) {
-	ctx.JSON(statusCode, errorResponse{
+	ctx.JSON(statusCode, messageResponse{
 		StatusCode: statusCode,
 		Status:     "success",
 		Message:    message,
@@ -35,5 +40,33 @@ func DeleteResponse(ctx *gin.Context, statusCode int, message string) {
 }
 
 func ResponseOK(ctx *gin.Context, statusCode int, data interface{}) {
-	ctx.JSON(statusCode, response{data})
+	ctx.JSON(statusCode, response{Data: data})
+}
+
+// ResponsePage - writes a page of a list with its total and the links to the
+// next and previous pages, which keep every other query parameter of the request
+func ResponsePage(ctx *gin.Context, statusCode int, data interface{}, total, limit, offset int) {
+	links := &pageLinks{Self: pageURL(ctx.Request.URL, limit, offset)}
+	if offset+limit < total {
+		links.Next = pageURL(ctx.Request.URL, limit, offset+limit)
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev = pageURL(ctx.Request.URL, limit, prev)
+	}
+	ctx.JSON(statusCode, response{
+		Data:  data,
+		Meta:  &pageMeta{Total: total, Limit: limit, Offset: offset},
+		Links: links,
+	})
+}
+
+func pageURL(requestURL *url.URL, limit, offset int) string {
+	query := requestURL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	return requestURL.Path + "?" + query.Encode()
 }